@@ -0,0 +1,15 @@
+// Package client is a typed Go client for the Refine API, generated from
+// internal/openapi/openapi.yaml. Regenerate it after editing the document:
+//
+//	go generate ./client
+//
+// Scripts authenticate with a personal access token:
+//
+//	c, err := client.NewClientWithResponses("https://api.refine.run",
+//		client.WithRequestEditorFn(func(ctx context.Context, req *http.Request) error {
+//			req.Header.Set("Authorization", "Bearer "+token)
+//			return nil
+//		}))
+package client
+
+//go:generate go tool oapi-codegen -config oapi-codegen.yaml ../internal/openapi/openapi.yaml
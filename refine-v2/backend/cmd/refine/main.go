@@ -0,0 +1,76 @@
+// Command refine is a terminal client for timed arithmetic practice. Problems
+// come from the same generator as the API, so sessions work offline. Once
+// logged in, each session is played on a seed the server issues and is
+// uploaded afterwards; the server scores it again from the seed before
+// saving. Sessions played without an issued seed, offline or while the
+// server is unreachable, stay local: the server would not accept them.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const usageText = `usage: refine [command] [flags]
+
+commands:
+  (none)    play a timed session
+  login     sign in and save an API token for uploads
+  logout    forget the saved API token
+  upload    retry sessions that could not be uploaded
+
+Run "refine [command] -h" to list the flags.`
+
+const defaultServer = "https://api.refine.run"
+
+func main() {
+	cmd, args := "", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+
+	var err error
+	switch cmd {
+	case "", "play":
+		err = runPlay(args)
+	case "login":
+		err = runLogin(args)
+	case "logout":
+		err = runLogout(args)
+	case "upload":
+		err = runUpload(args)
+	default:
+		fmt.Fprintln(os.Stderr, usageText)
+		os.Exit(2)
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "refine:", err)
+		os.Exit(1)
+	}
+}
+
+// newFlagSet returns a flag set that reports errors instead of exiting, so
+// every command exits through main.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	return fs
+}
+
+// parseFlags parses args and rejects positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"refine-v2/backend/client"
+	"refine-v2/backend/internal/generator"
+	"refine-v2/backend/internal/models"
+)
+
+var modes = map[string]bool{
+	"addition": true, "subtraction": true, "multiplication": true, "division": true, "mixed": true,
+}
+
+// customDifficulty uses the -min/-max range. The server saves custom sessions
+// as practice, so they count towards the player's own stats but not the
+// global leaderboards.
+const customDifficulty = 4
+
+const playUsage = `usage: refine [play] [flags]
+
+Plays a timed session. When logged in, the session is played on a seed the
+server issues and uploaded when the time runs out. The server only accepts
+sessions played on a seed it issued, so sessions played with -offline,
+before "refine login", or while the server could not be reached are never
+uploaded, not even by "refine upload". Sessions stopped before the time runs
+out are not uploaded either.
+
+flags:
+`
+
+// session is one timed run. Problems are generated from the seed in order,
+// so the server can regenerate them from the seed and the answer count.
+type session struct {
+	seed       string
+	mode       string
+	difficulty int
+	config     *models.CustomConfig
+	timeLimit  time.Duration
+
+	problems []models.Problem
+	answers  []int
+	finished bool // the time limit ran out, rather than the player quitting
+	issued   bool // the server issued the seed, so the session can be uploaded
+}
+
+// problem returns problem i, generating more as needed.
+func (s *session) problem(i int) models.Problem {
+	if i >= len(s.problems) {
+		n := max(2*len(s.problems), 50)
+		s.problems = generator.GenerateWithSeed(s.seed, s.mode, s.difficulty, n, s.config)
+	}
+	return s.problems[i]
+}
+
+func (s *session) correct() int {
+	n := 0
+	for i, a := range s.answers {
+		if s.problems[i].Answer == a {
+			n++
+		}
+	}
+	return n
+}
+
+func runPlay(args []string) error {
+	flags := newFlagSet("refine")
+	mode := flags.String("mode", "mixed", "addition, subtraction, multiplication, division or mixed")
+	difficulty := flags.Int("difficulty", 1, "1 (easy) to 3 (hard), or 4 for a custom -min/-max range")
+	minNum := flags.Int("min", 0, "smallest operand at difficulty 4")
+	maxNum := flags.Int("max", 0, "largest operand at difficulty 4")
+	limit := flags.Duration("time", time.Minute, "session length; leaderboards use 30s, 1m, 1m30s and 2m")
+	offline := flags.Bool("offline", false, "play on a local seed; the session cannot be uploaded later")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), playUsage)
+		flags.PrintDefaults()
+	}
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	s := &session{
+		seed:       generator.CreateSeed(),
+		mode:       strings.ToLower(*mode),
+		difficulty: *difficulty,
+		timeLimit:  *limit,
+	}
+	if !modes[s.mode] {
+		return fmt.Errorf("invalid -mode %q", *mode)
+	}
+	if s.difficulty < 1 || s.difficulty > customDifficulty {
+		return fmt.Errorf("-difficulty must be 1 to %d", customDifficulty)
+	}
+	if s.difficulty == customDifficulty {
+		if *minNum < 1 || *maxNum < *minNum {
+			return fmt.Errorf("-difficulty %d needs 1 <= -min <= -max", customDifficulty)
+		}
+		s.config = &models.CustomConfig{Min: *minNum, Max: *maxNum}
+	}
+	if s.timeLimit < time.Second {
+		return fmt.Errorf("-time must be at least 1s")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// The server only accepts sessions played on a seed it issued
+	var creds *credentials
+	if !*offline {
+		var err error
+		if creds, err = loadCredentials(); err != nil {
+			return err
+		}
+	}
+	if creds != nil {
+		seed, err := issueSeed(ctx, creds, s.mode, s.difficulty, s.clientConfig())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Could not get a seed from the server, so this session is played offline and cannot be uploaded:", err)
+		} else {
+			s.seed, s.issued = seed, true
+		}
+	}
+
+	play(ctx, s, lines(os.Stdin), os.Stdout, isTerminal(os.Stdin))
+	stop()
+	review(s, os.Stdout)
+
+	if !s.issued || !s.finished || len(s.answers) == 0 {
+		return nil
+	}
+	return uploadOrQueue(creds, s.request())
+}
+
+// play runs the session until the time limit, end of input, "q" or an
+// interrupt. The remaining time is shown with each problem.
+func play(ctx context.Context, s *session, input <-chan string, out io.Writer, interactive bool) {
+	fmt.Fprintf(out, "%s, %s, %s. Type q to stop.\n", s.mode, difficultyName(s), s.timeLimit)
+	if interactive {
+		for i := 3; i > 0; i-- {
+			fmt.Fprintf(out, "%d... ", i)
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				fmt.Fprintln(out)
+				return
+			}
+		}
+		fmt.Fprintln(out, "go!")
+	}
+
+	deadline := time.Now().Add(s.timeLimit)
+	timer := time.NewTimer(s.timeLimit)
+	defer timer.Stop()
+
+	for i := 0; ; {
+		p := s.problem(i)
+		fmt.Fprintf(out, "[%s] %d %s %d = ", clock(time.Until(deadline)), p.Num1, p.Operator, p.Num2)
+
+		select {
+		case <-timer.C:
+			fmt.Fprintln(out, "\nTime's up!")
+			s.finished = true
+			return
+		case <-ctx.Done():
+			fmt.Fprintln(out)
+			return
+		case line, ok := <-input:
+			if !ok {
+				fmt.Fprintln(out)
+				return
+			}
+			line = strings.TrimSpace(line)
+			if line == "q" {
+				return
+			}
+			n, err := strconv.Atoi(line)
+			if err != nil {
+				fmt.Fprintln(out, "  enter a number, or q to stop")
+				continue
+			}
+			s.answers = append(s.answers, n)
+			if n != p.Answer {
+				fmt.Fprintln(out, "  ✗")
+			}
+			i++
+		}
+	}
+}
+
+// review prints the score and every mistake with its answer.
+func review(s *session, out io.Writer) {
+	total := len(s.answers)
+	correct := s.correct()
+	fmt.Fprintln(out)
+	if total == 0 {
+		fmt.Fprintln(out, "No answers.")
+		return
+	}
+	fmt.Fprintf(out, "%d / %d correct (%d%%), %.1f correct per minute\n",
+		correct, total, correct*100/total, float64(correct)/s.timeLimit.Minutes())
+
+	if correct == total {
+		return
+	}
+	fmt.Fprintln(out, "\nMistakes:")
+	for i, a := range s.answers {
+		p := s.problems[i]
+		if p.Answer != a {
+			fmt.Fprintf(out, "  %d %s %d = %d, you answered %d\n", p.Num1, p.Operator, p.Num2, p.Answer, a)
+		}
+	}
+}
+
+// request is the upload for a finished session.
+func (s *session) request() client.SaveSessionRequestV2 {
+	return client.SaveSessionRequestV2{
+		Seed:       s.seed,
+		Mode:       client.Mode(s.mode),
+		Difficulty: s.difficulty,
+		TimeLimit:  int(s.timeLimit.Seconds()),
+		Answers:    s.answers,
+		Config:     s.clientConfig(),
+	}
+}
+
+func (s *session) clientConfig() *client.CustomConfig {
+	if s.config == nil {
+		return nil
+	}
+	return &client.CustomConfig{Min: s.config.Min, Max: s.config.Max}
+}
+
+func difficultyName(s *session) string {
+	if s.config != nil {
+		return fmt.Sprintf("%d to %d", s.config.Min, s.config.Max)
+	}
+	return [...]string{1: "easy", 2: "medium", 3: "hard"}[s.difficulty]
+}
+
+// clock formats a remaining duration as m:ss, rounding up.
+func clock(d time.Duration) string {
+	secs := int((d + time.Second - 1) / time.Second)
+	if secs < 0 {
+		secs = 0
+	}
+	return fmt.Sprintf("%d:%02d", secs/60, secs%60)
+}
+
+// lines delivers r line by line, closing the channel at end of input. Reading
+// happens in the background so play can stop at the deadline mid-answer.
+func lines(r io.Reader) <-chan string {
+	c := make(chan string)
+	go func() {
+		defer close(c)
+		sc := bufio.NewScanner(r)
+		for sc.Scan() {
+			c <- sc.Text()
+		}
+	}()
+	return c
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
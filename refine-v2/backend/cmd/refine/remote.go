@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"refine-v2/backend/client"
+)
+
+// credentials are saved by "refine login". The token is a personal access
+// token limited to uploading sessions and reading stats.
+type credentials struct {
+	Server    string    `json:"server"`
+	Username  string    `json:"username"`
+	TokenID   int64     `json:"token_id"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// configDir holds the credentials and the upload queue. It follows
+// XDG_CONFIG_HOME on Linux.
+func configDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "refine"), nil
+}
+
+// loadCredentials returns nil when not logged in.
+func loadCredentials() (*credentials, error) {
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "credentials.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var c credentials
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("read credentials: %w", err)
+	}
+	return &c, nil
+}
+
+func saveCredentials(c *credentials) error {
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "credentials.json"), data, 0o600)
+}
+
+// newClient returns an API client that authenticates with token, if set.
+func newClient(server, token string) (*client.ClientWithResponses, error) {
+	opts := []client.ClientOption{client.WithHTTPClient(&http.Client{Timeout: 15 * time.Second})}
+	if token != "" {
+		opts = append(opts, client.WithRequestEditorFn(func(ctx context.Context, req *http.Request) error {
+			req.Header.Set("Authorization", "Bearer "+token)
+			return nil
+		}))
+	}
+	return client.NewClientWithResponses(server, opts...)
+}
+
+// apiError turns an error response into an error carrying the server's
+// message.
+func apiError(res *http.Response, body []byte) error {
+	var e client.Error
+	if json.Unmarshal(body, &e) == nil && e.Message != "" {
+		return fmt.Errorf("%s (%s)", e.Message, res.Status)
+	}
+	return fmt.Errorf("server returned %s", res.Status)
+}
+
+func runLogin(args []string) error {
+	flags := newFlagSet("refine login")
+	server := flags.String("server", defaultServer, "API base URL")
+	email := flags.String("email", "", "account email; prompted for if empty")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	if *email == "" {
+		*email = prompt(in, "Email: ")
+	}
+	password := promptSecret(in, "Password: ")
+
+	ctx := context.Background()
+	api, err := newClient(*server, "")
+	if err != nil {
+		return err
+	}
+	res, err := api.LoginWithResponse(ctx, client.LoginJSONRequestBody{Email: *email, Password: password})
+	if err != nil {
+		return err
+	}
+	if res.StatusCode() != http.StatusOK {
+		return apiError(res.HTTPResponse, res.Body)
+	}
+	httpRes, body := res.HTTPResponse, res.Body
+
+	var challenge client.LoginChallengeResponse
+	if json.Unmarshal(body, &challenge) == nil && challenge.TwoFactorRequired {
+		code := prompt(in, "Authentication code (or a recovery code): ")
+		req := client.LoginTwoFactorJSONRequestBody{ChallengeToken: challenge.ChallengeToken}
+		if len(code) == 6 {
+			req.Code = &code
+		} else {
+			req.RecoveryCode = &code
+		}
+		res, err := api.LoginTwoFactorWithResponse(ctx, req)
+		if err != nil {
+			return err
+		}
+		if res.StatusCode() != http.StatusOK {
+			return apiError(res.HTTPResponse, res.Body)
+		}
+		httpRes, body = res.HTTPResponse, res.Body
+	}
+
+	var user client.AuthResponse
+	if err := json.Unmarshal(body, &user); err != nil {
+		return fmt.Errorf("decode login response: %w", err)
+	}
+	var session string
+	for _, c := range httpRes.Cookies() {
+		if c.Name == "token" {
+			session = c.Value
+		}
+	}
+	if session == "" {
+		return errors.New("server did not return a session")
+	}
+
+	// Trade the short-lived session for a scoped token, so the password is
+	// not needed again until it expires
+	api, err = newClient(*server, session)
+	if err != nil {
+		return err
+	}
+	name := "refine CLI"
+	if host, err := os.Hostname(); err == nil {
+		name += " on " + host
+	}
+	tokenRes, err := api.CreateAPITokenWithResponse(ctx, client.CreateAPITokenJSONRequestBody{
+		Name:   name,
+		Scopes: []client.Scope{client.SessionsWrite, client.StatsRead},
+	})
+	if err != nil {
+		return err
+	}
+	if tokenRes.JSON201 == nil {
+		return apiError(tokenRes.HTTPResponse, tokenRes.Body)
+	}
+
+	creds := &credentials{
+		Server:    *server,
+		Username:  user.User.Username,
+		TokenID:   tokenRes.JSON201.ApiToken.Id,
+		Token:     tokenRes.JSON201.Token,
+		ExpiresAt: tokenRes.JSON201.ApiToken.ExpiresAt,
+	}
+	if err := saveCredentials(creds); err != nil {
+		return fmt.Errorf("save credentials: %w", err)
+	}
+	fmt.Printf("Logged in as %s. Finished sessions will be uploaded to %s.\n", creds.Username, creds.Server)
+	return nil
+}
+
+func runLogout(args []string) error {
+	if err := parseFlags(newFlagSet("refine logout"), args); err != nil {
+		return err
+	}
+	creds, err := loadCredentials()
+	if err != nil {
+		return err
+	}
+	if creds == nil {
+		fmt.Println("Not logged in.")
+		return nil
+	}
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(dir, "credentials.json")); err != nil {
+		return err
+	}
+	// Tokens can only be revoked from a logged-in session, not by themselves
+	fmt.Printf("Logged out. Token %d stays valid until %s unless revoked from your account.\n",
+		creds.TokenID, creds.ExpiresAt.Local().Format("2 Jan 2006"))
+	return nil
+}
+
+func runUpload(args []string) error {
+	if err := parseFlags(newFlagSet("refine upload"), args); err != nil {
+		return err
+	}
+	creds, err := loadCredentials()
+	if err != nil {
+		return err
+	}
+	if creds == nil {
+		return errors.New(`not logged in; run "refine login" first`)
+	}
+
+	queued, err := loadQueue()
+	if err != nil {
+		return err
+	}
+	if len(queued) == 0 {
+		fmt.Println("Nothing to upload.")
+		return nil
+	}
+
+	var failed []client.SaveSessionRequestV2
+	var lastErr error
+	for _, req := range queued {
+		err := upload(creds, req)
+		var rejected *rejectedError
+		switch {
+		case errors.As(err, &rejected):
+			fmt.Fprintln(os.Stderr, "Dropping session the server rejected:", err)
+		case err != nil:
+			failed, lastErr = append(failed, req), err
+		}
+	}
+	if err := saveQueue(failed); err != nil {
+		return err
+	}
+	if lastErr != nil {
+		return fmt.Errorf("%d of %d sessions still queued: %w", len(failed), len(queued), lastErr)
+	}
+	return nil
+}
+
+// issueSeed asks the server for the seed of a new problem set, which is only
+// valid for mode, difficulty and config. Problems are still generated locally
+// from it.
+func issueSeed(ctx context.Context, creds *credentials, mode string, difficulty int, config *client.CustomConfig) (string, error) {
+	api, err := newClient(creds.Server, creds.Token)
+	if err != nil {
+		return "", err
+	}
+	count := 1
+	res, err := api.GenerateProblemsV2WithResponse(ctx, client.GenerateProblemsV2JSONRequestBody{
+		Mode:       &mode,
+		Difficulty: &difficulty,
+		Count:      &count,
+		Config:     config,
+	})
+	if err != nil {
+		return "", err
+	}
+	if res.JSON200 == nil {
+		return "", apiError(res.HTTPResponse, res.Body)
+	}
+	return res.JSON200.Seed, nil
+}
+
+// rejectedError is a session the server refused, which retrying will not fix.
+type rejectedError struct{ err error }
+
+func (e *rejectedError) Error() string { return e.err.Error() }
+
+// upload saves a session and prints the server's score.
+func upload(creds *credentials, req client.SaveSessionRequestV2) error {
+	api, err := newClient(creds.Server, creds.Token)
+	if err != nil {
+		return err
+	}
+	res, err := api.SaveGameSessionV2WithResponse(context.Background(), req)
+	if err != nil {
+		return err
+	}
+	switch {
+	case res.JSON201 != nil:
+		fmt.Printf("Uploaded: %d / %d correct, score %d.\n", res.JSON201.Correct, res.JSON201.Total, res.JSON201.Score)
+		return nil
+	case res.StatusCode() == http.StatusUnauthorized || res.StatusCode() == http.StatusForbidden:
+		return fmt.Errorf(`%w; run "refine login" again`, apiError(res.HTTPResponse, res.Body))
+	case res.StatusCode() == http.StatusBadRequest || res.StatusCode() == http.StatusConflict:
+		return &rejectedError{apiError(res.HTTPResponse, res.Body)}
+	}
+	return apiError(res.HTTPResponse, res.Body)
+}
+
+// uploadOrQueue uploads a finished session, queueing it for "refine upload"
+// if that fails, e.g. while offline.
+func uploadOrQueue(creds *credentials, req client.SaveSessionRequestV2) error {
+	err := upload(creds, req)
+	if err == nil {
+		return nil
+	}
+	var rejected *rejectedError
+	if errors.As(err, &rejected) {
+		return err
+	}
+	queued, qerr := loadQueue()
+	if qerr != nil {
+		return qerr
+	}
+	if qerr := saveQueue(append(queued, req)); qerr != nil {
+		return qerr
+	}
+	fmt.Fprintln(os.Stderr, "Upload failed:", err)
+	fmt.Fprintln(os.Stderr, `The session is saved; run "refine upload" to retry.`)
+	return nil
+}
+
+func queuePath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "queue.json"), nil
+}
+
+func loadQueue() ([]client.SaveSessionRequestV2, error) {
+	path, err := queuePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var queued []client.SaveSessionRequestV2
+	if err := json.Unmarshal(data, &queued); err != nil {
+		return nil, fmt.Errorf("read upload queue: %w", err)
+	}
+	return queued, nil
+}
+
+func saveQueue(queued []client.SaveSessionRequestV2) error {
+	path, err := queuePath()
+	if err != nil {
+		return err
+	}
+	if len(queued) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(queued, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+func prompt(in *bufio.Reader, label string) string {
+	fmt.Print(label)
+	line, _ := in.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// promptSecret reads a line without echoing it when stdin is a terminal that
+// stty can configure.
+func promptSecret(in *bufio.Reader, label string) string {
+	if isTerminal(os.Stdin) {
+		if stty("-echo") == nil {
+			defer func() {
+				stty("echo")
+				fmt.Println()
+			}()
+		}
+	}
+	return prompt(in, label)
+}
+
+func stty(arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
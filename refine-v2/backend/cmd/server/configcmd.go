@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"refine-v2/backend/internal/config"
+)
+
+const configUsage = `usage: refine-api config [flags] <command>
+
+commands:
+  print     print the effective configuration as YAML, with secrets redacted,
+            then report any invalid settings`
+
+// runConfig implements the "config" subcommand.
+func runConfig(cfg *config.Config, args []string) {
+	if len(args) != 1 || args[0] != "print" {
+		usage(configUsage)
+	}
+
+	if err := cfg.Redacted().WriteYAML(os.Stdout); err != nil {
+		fatal("Failed to print configuration", "error", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr)
+		invalidConfig(err)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"refine-v2/backend/internal/apigateway"
+)
+
+// onLambda reports whether the process was started by the Lambda runtime.
+func onLambda() bool {
+	return os.Getenv("AWS_LAMBDA_RUNTIME_API") != ""
+}
+
+// startLambda serves API Gateway events from the Lambda runtime. It does not
+// return. Background work such as imports only progresses while the runtime
+// has the environment thawed, i.e. while requests are being served.
+func startLambda(h http.Handler) {
+	slog.Info("Serving API Gateway events")
+	lambda.Start(apigateway.Handler(h))
+}
+
+// runInvoke implements the "invoke" subcommand. It replays recorded API
+// Gateway proxy events, from the files in args or else stdin, through the
+// Lambda handler and prints each response as JSON. Sample events are in
+// deploy/lambda/events.
+func runInvoke(h http.Handler, args []string) {
+	handle := apigateway.Handler(h)
+	invoke := func(name string, r io.Reader) error {
+		var event events.APIGatewayProxyRequest
+		if err := json.NewDecoder(r).Decode(&event); err != nil {
+			return fmt.Errorf("%s: decode event: %w", name, err)
+		}
+		resp, err := handle(context.Background(), event)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(resp)
+	}
+
+	if len(args) == 0 {
+		if err := invoke("stdin", os.Stdin); err != nil {
+			fatal("Invoke failed", "error", err)
+		}
+		return
+	}
+	for _, path := range args {
+		f, err := os.Open(path)
+		if err != nil {
+			fatal("Invoke failed", "error", err)
+		}
+		err = invoke(path, f)
+		f.Close()
+		if err != nil {
+			fatal("Invoke failed", "error", err)
+		}
+	}
+}
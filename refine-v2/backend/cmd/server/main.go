@@ -1,10 +1,16 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -12,93 +18,415 @@ import (
 	"github.com/go-chi/cors"
 
 	"refine-v2/backend/internal/auth"
+	"refine-v2/backend/internal/cache"
+	"refine-v2/backend/internal/config"
 	"refine-v2/backend/internal/database"
+	"refine-v2/backend/internal/database/cached"
+	"refine-v2/backend/internal/database/memory"
+	"refine-v2/backend/internal/database/migrate"
+	"refine-v2/backend/internal/database/postgres"
+	"refine-v2/backend/internal/database/sqlite"
+	"refine-v2/backend/internal/database/sqlstore"
 	"refine-v2/backend/internal/handlers"
+	"refine-v2/backend/internal/logging"
+	"refine-v2/backend/internal/metrics"
+	"refine-v2/backend/internal/openapi"
+	"refine-v2/backend/internal/ratelimit"
+	"refine-v2/backend/internal/tracing"
 )
 
+const usageText = `usage: refine-api [command] [flags]
+
+commands:
+  (none)    run the API server
+  migrate   manage the database schema
+  stats     maintain precomputed stats
+  config    print the effective configuration
+  invoke    replay recorded API Gateway events through the Lambda handler
+
+Run "refine-api [command] -h" to list the flags. Every flag can also be set
+in a YAML or TOML file passed with -config, and most by environment variable.
+
+When started by the AWS Lambda runtime the server serves API Gateway proxy
+events instead of listening on a port.`
+
 func main() {
-	// Required env vars
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		log.Fatal("DATABASE_URL is required")
+	cmd, args := "", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
+	}
+	switch cmd {
+	case "", "migrate", "stats", "config", "invoke":
+	default:
+		usage(usageText)
 	}
 
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Fatal("JWT_SECRET is required")
+	cfg, args, err := config.Load(strings.TrimSpace("refine-api "+cmd), args, os.Getenv, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
-	auth.SetSecret(jwtSecret)
 
-	frontendURL := os.Getenv("FRONTEND_URL")
-	if frontendURL == "" {
-		frontendURL = "http://localhost:5173"
+	// config print reports invalid settings itself, so it runs before the
+	// logger is built from them
+	if cmd == "config" {
+		runConfig(cfg, args)
+		return
 	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	if cmd == "" || cmd == "invoke" {
+		if err := cfg.Validate(); err != nil {
+			invalidConfig(err)
+		}
+	}
+
+	// Subcommands are run by hand, so they default to readable text logs
+	defaultFormat := "json"
+	if cmd != "" {
+		defaultFormat = "text"
+	}
+	if err := setupLogging(cfg.Log, defaultFormat); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	switch cmd {
+	case "migrate":
+		runMigrate(cfg, args)
+		return
+	case "stats":
+		runStats(cfg, args)
+		return
+	}
+	if cmd == "" && len(args) > 0 {
+		usage(usageText)
+	}
+
+	keyring, err := loadKeyring(cfg.Auth)
+	if err != nil {
+		fatal("Failed to load JWT keys", "error", err)
+	}
+	auth.SetKeyring(keyring)
+	auth.SetSessionTTL(cfg.Auth.SessionTTL)
+
+	// Tracing. The "otlp" exporter sends spans to the collector at
+	// OTEL_EXPORTER_OTLP_ENDPOINT (default localhost:4318); "none" disables
+	// export but still propagates trace context.
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		ServiceName: cfg.Tracing.ServiceName,
+		Version:     version,
+	})
+	if err != nil {
+		fatal("Failed to set up tracing", "error", err)
 	}
+	defer shutdownTracing(context.Background())
 
 	// Secure cookies only over HTTPS (disable for local dev)
-	handlers.SetSecureCookies(strings.HasPrefix(frontendURL, "https"))
+	handlers.SetSecureCookies(strings.HasPrefix(cfg.Server.FrontendURL, "https"))
+	handlers.SetProblemLimits(cfg.Problems.DefaultCount, cfg.Problems.MaxCount)
+	handlers.SetSeedKey(cfg.SeedKey())
 
 	// Database
-	db, err := database.Connect(dbURL)
+	storeOpts := storeOptions(cfg.Database)
+	storeOpts.Hooks = append(storeOpts.Hooks, metrics.QueryHook{}, tracing.QueryHook{})
+	store, migrator, err := openStore(cfg.Database, storeOpts)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		fatal("Failed to open database", "error", err)
+	}
+	defer store.Close()
+	if s, ok := store.(*sqlstore.Store); ok {
+		metrics.RegisterDB(s.DB, "refine")
 	}
-	defer db.Close()
 
-	if err := database.Migrate(db); err != nil {
-		log.Fatalf("Failed to run migrations: %v", err)
+	// Disable auto_migrate to run "migrate up" as a separate deploy step
+	if migrator != nil && cfg.Database.AutoMigrate {
+		applied, err := migrator.Up(context.Background())
+		if err != nil {
+			fatal("Failed to run migrations", "error", err)
+		}
+		for _, m := range applied {
+			slog.Info("Applied migration", "version", m.Version, "name", m.Name)
+		}
 	}
-	log.Println("Database connected and migrated")
+	slog.Info("Database connected")
+
+	// Caching
+	if cfg.Cache.TTL > 0 {
+		c := cache.NewMemory(cfg.Cache.MaxMB<<20, time.Minute)
+		defer c.Close()
+		store = cached.New(store, c, cfg.Cache.TTL)
+		if cfg.Cache.ProblemTTL > 0 {
+			handlers.SetProblemCache(c, cfg.Cache.ProblemTTL)
+		}
+	}
+
+	// Rate limiting
+	limitBackend := ratelimit.NewMemory(time.Minute)
+	defer limitBackend.Close()
+	limiter := ratelimit.NewLimiter(limitBackend)
+	loginLockout := ratelimit.NewLockout(limitBackend, "login", 5, 30*time.Second, 30*time.Minute)
 
-	store := database.NewStore(db)
+	spec, err := openapi.Load()
+	if err != nil {
+		fatal("Failed to load OpenAPI document", "error", err)
+	}
 
 	// Router
 	r := chi.NewRouter()
 
 	// Middleware
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(30 * time.Second))
+	r.Use(middleware.RequestID)
+	r.Use(handlers.TrustedProxies(cfg.ProxyPrefixes())) // Caddy sets X-Forwarded-For
+	r.Use(handlers.RequestTracing)
+	r.Use(handlers.RequestLogger)
+	r.Use(handlers.RequestMetrics)
+	r.Use(handlers.Recoverer)
 	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{frontendURL},
+		AllowedOrigins:   cfg.Origins(),
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Content-Type", "Authorization", "Origin", "Accept"},
+		ExposedHeaders:   []string{"Deprecation", "Link", "X-Worksheet-Seed"},
 		AllowCredentials: true,
-		MaxAge:           300,
+		MaxAge:           cfg.CORS.MaxAge,
 	}))
+	r.NotFound(handlers.NotFound)
+	r.MethodNotAllowed(handlers.MethodNotAllowed)
 
-	// Public routes
-	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(`{"status":"healthy"}`))
-	})
+	// Every route is bounded by the handler timeout except the exports, which
+	// the API routes mount outside it
+	timeout := middleware.Timeout(cfg.Server.HandlerTimeout)
+
+	// Public routes. /health is kept for existing monitors; new probes should
+	// use /livez and /readyz.
+	var servers []*http.Server
+	r.Group(func(r chi.Router) {
+		r.Use(timeout)
 
-	r.Post("/api/problems", handlers.GenerateProblems)
-	r.Post("/api/validate", handlers.ValidateAnswers)
-	r.Post("/emails", handlers.EmailSignup(store))
+		r.Get("/health", handlers.Readyz(store, migrator))
+		r.Get("/livez", handlers.Livez)
+		r.Get("/readyz", handlers.Readyz(store, migrator))
+		r.Get("/version", handlers.Version(buildInfo()))
 
-	// Auth routes (public)
-	r.Post("/api/auth/signup", handlers.Signup(store))
-	r.Post("/api/auth/login", handlers.Login(store))
-	r.Post("/api/auth/logout", handlers.Logout)
+		r.Get("/.well-known/jwks.json", handlers.JWKS)
+		r.Get("/api/openapi.json", handlers.OpenAPI(spec))
 
-	// Protected routes
+		// Metrics are served here unless metrics.addr moves them to a separate
+		// listener, e.g. 127.0.0.1:9090, that is not exposed through the proxy
+		metricsHandler := metrics.Handler(cfg.Metrics.Token)
+		if cfg.Metrics.Addr != "" {
+			mux := http.NewServeMux()
+			mux.Handle("GET /metrics", metricsHandler)
+			servers = append(servers, newServer(cfg.Metrics.Addr, mux, cfg.Server))
+		} else {
+			r.Method(http.MethodGet, "/metrics", metricsHandler)
+		}
+	})
+
+	a := &api{
+		store:        store,
+		limiter:      limiter,
+		loginLockout: loginLockout,
+		background:   handlers.NewBackground(),
+		validate:     handlers.ValidateRequestBodies(spec),
+		timeout:      timeout,
+	}
+	r.Route("/api/v1", a.routes(1))
+	r.Route("/api/v2", a.routes(2))
+
+	// The unversioned routes predate /api/v1 and behave the same; they stay
+	// for existing clients and mark every response deprecated
 	r.Group(func(r chi.Router) {
-		r.Use(handlers.AuthMiddleware)
-
-		r.Get("/api/auth/me", handlers.GetCurrentUser(store))
-		r.Put("/api/auth/password", handlers.ChangePassword(store))
-		r.Put("/api/auth/username", handlers.ChangeUsername(store))
-		r.Delete("/api/auth/account", handlers.DeleteAccount(store))
-		r.Post("/api/sessions", handlers.SaveGameSession(store))
-		r.Get("/api/stats", handlers.GetUserStats(store))
-		r.Get("/api/leaderboard", handlers.GetLeaderboard(store))
+		r.Use(handlers.Deprecated)
+		r.Route("/api", a.routes(1))
+		r.With(timeout, handlers.RateLimit(limiter, emailsPerIP, handlers.KeyByIP), a.validate).
+			Post("/emails", handlers.EmailSignup(store))
 	})
 
-	log.Printf("Server starting on :%s", port)
-	log.Fatal(http.ListenAndServe(":"+port, r))
+	if cmd == "invoke" {
+		runInvoke(r, args)
+		a.background.Shutdown(context.Background())
+		return
+	}
+	if onLambda() {
+		startLambda(r)
+	}
+
+	// shutdown_delay keeps serving after SIGTERM while /readyz fails, giving
+	// a load balancer time to stop sending new requests
+	servers = append(servers, newServer(fmt.Sprintf(":%d", cfg.Server.Port), r, cfg.Server))
+	if err := serve(servers, a.background, cfg.Server.ShutdownDelay, cfg.Server.ShutdownTimeout); err != nil {
+		fatal("Server stopped", "error", err)
+	}
+	slog.Info("Server stopped")
+}
+
+func newServer(addr string, h http.Handler, cfg config.Server) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+}
+
+// serve runs servers until SIGINT or SIGTERM. It then marks the process as
+// draining, keeps serving for drainDelay, stops accepting connections and
+// waits up to timeout for in-flight requests and background work. It returns
+// early if any server fails to start.
+func serve(servers []*http.Server, background *handlers.Background, drainDelay, timeout time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, len(servers))
+	for _, srv := range servers {
+		slog.Info("Server starting", "addr", srv.Addr)
+		go func() {
+			if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				errc <- err
+			}
+		}()
+	}
+
+	var serveErr error
+	select {
+	case serveErr = <-errc:
+	case <-ctx.Done():
+		slog.Info("Shutting down", "delay", drainDelay, "timeout", timeout)
+		handlers.SetDraining(true)
+		time.Sleep(drainDelay)
+	}
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Graceful shutdown failed", "addr", srv.Addr, "error", err)
+		}
+	}
+	if err := background.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Background work cancelled", "error", err)
+	}
+	return serveErr
+}
+
+// loadKeyring builds the JWT keyring.
+//
+// signing_key_file is a PEM RSA or Ed25519 private key used to sign new
+// tokens. verify_key_files lists older keys still accepted during rotation.
+// jwt_secret is the legacy HMAC secret: it signs tokens when no signing key
+// file is set, and otherwise only verifies them so existing sessions survive
+// the switch to asymmetric keys.
+func loadKeyring(cfg config.Auth) (*auth.Keyring, error) {
+	var hmacKey *auth.Key
+	if cfg.JWTSecret != "" {
+		hmacKey = auth.NewHMACKey(cfg.JWTKeyID, []byte(cfg.JWTSecret))
+	}
+
+	var verify []*auth.Key
+	for _, path := range cfg.VerifyKeyFiles {
+		key, err := auth.LoadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		verify = append(verify, key)
+	}
+
+	if cfg.SigningKeyFile == "" {
+		if hmacKey == nil {
+			return nil, errors.New("jwt_secret or signing_key_file is required")
+		}
+		return auth.NewKeyring(hmacKey, verify...)
+	}
+
+	signing, err := auth.LoadKeyFile(cfg.SigningKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if hmacKey != nil {
+		verify = append(verify, hmacKey)
+	}
+	return auth.NewKeyring(signing, verify...)
+}
+
+// openStore picks a Store implementation from the database URL scheme:
+// postgres:// or postgresql:// for Postgres, sqlite:<path> for an embedded
+// SQLite file, and memory: for a throwaway in-process store. The returned
+// migrator is nil for the memory store, which has no schema.
+func openStore(cfg config.Database, opts sqlstore.Options) (database.Store, *migrate.Migrator, error) {
+	dbURL := cfg.URL
+	switch {
+	case strings.HasPrefix(dbURL, "postgres://"), strings.HasPrefix(dbURL, "postgresql://"):
+		db, err := postgres.Connect(dbURL, postgres.Pool{
+			MaxOpenConns:    cfg.MaxOpenConns,
+			MaxIdleConns:    cfg.MaxIdleConns,
+			ConnMaxIdleTime: cfg.ConnMaxIdleTime,
+			ConnMaxLifetime: cfg.ConnMaxLifetime,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		migrator, err := postgres.NewMigrator(db)
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		return postgres.New(db, opts), migrator, nil
+
+	case strings.HasPrefix(dbURL, "sqlite:"):
+		path := strings.TrimPrefix(strings.TrimPrefix(dbURL, "sqlite:"), "//")
+		db, err := sqlite.Connect(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		migrator, err := sqlite.NewMigrator(db)
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		return sqlite.New(db, opts), migrator, nil
+
+	case dbURL == "memory:":
+		slog.Warn("Using in-memory store; all data is lost on exit")
+		return memory.New(), nil, nil
+	}
+
+	return nil, nil, fmt.Errorf("unsupported database URL scheme in %q", config.RedactURL(dbURL))
+}
+
+// setupLogging installs the default slog logger. An empty format falls back
+// to defaultFormat.
+func setupLogging(cfg config.Log, defaultFormat string) error {
+	format := cfg.Format
+	if format == "" {
+		format = defaultFormat
+	}
+
+	logger, err := logging.New(os.Stderr, cfg.Level, format)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
+
+// invalidConfig lists the settings that failed validation and exits. It
+// writes plain text so each problem is on its own line whatever the log
+// format.
+func invalidConfig(err error) {
+	fmt.Fprintf(os.Stderr, "invalid configuration:\n%s\n", err)
+	os.Exit(2)
+}
+
+// fatal logs msg at error level and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
 }
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"refine-v2/backend/internal/config"
+	"refine-v2/backend/internal/database/sqlstore"
+)
+
+const migrateUsage = `usage: refine-api migrate [flags] <command>
+
+commands:
+  up        apply all pending migrations
+  down [n]  revert the last n applied migrations (default 1)
+  status    list migrations and whether each is applied`
+
+// usage prints a subcommand's usage and exits.
+func usage(text string) {
+	fmt.Fprintln(os.Stderr, text)
+	os.Exit(2)
+}
+
+// runMigrate implements the "migrate" subcommand.
+func runMigrate(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		usage(migrateUsage)
+	}
+
+	if err := cfg.Database.Validate(); err != nil {
+		invalidConfig(err)
+	}
+
+	store, migrator, err := openStore(cfg.Database, sqlstore.Options{})
+	if err != nil {
+		fatal("Failed to open database", "error", err)
+	}
+	defer store.Close()
+	if migrator == nil {
+		fatal("This store has no schema to migrate")
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			slog.Info("Applied migration", "version", m.Version, "name", m.Name)
+		}
+		if err != nil {
+			fatal("Migration failed", "error", err)
+		}
+		if len(applied) == 0 {
+			slog.Info("Already up to date")
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				fatal("Invalid step count", "steps", args[1])
+			}
+		}
+		reverted, err := migrator.Down(ctx, steps)
+		for _, m := range reverted {
+			slog.Info("Reverted migration", "version", m.Version, "name", m.Name)
+		}
+		if err != nil {
+			fatal("Migration failed", "error", err)
+		}
+		if len(reverted) == 0 {
+			slog.Info("Nothing to revert")
+		}
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			fatal("Failed to read migration status", "error", err)
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-20s %s\n", s.Version, s.Name, applied)
+		}
+
+	default:
+		usage(migrateUsage)
+	}
+}
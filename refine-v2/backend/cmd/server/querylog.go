@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"refine-v2/backend/internal/config"
+	"refine-v2/backend/internal/database/sqlstore"
+)
+
+// storeOptions maps the query timeout, where 0 disables the per-call
+// deadline, and the slow query threshold, where 0 disables logging.
+func storeOptions(cfg config.Database) sqlstore.Options {
+	opts := sqlstore.Options{QueryTimeout: cfg.QueryTimeout}
+	if cfg.QueryTimeout == 0 {
+		opts.QueryTimeout = -1
+	}
+	if cfg.SlowQuery > 0 {
+		opts.Hooks = append(opts.Hooks, slowQueryLog{threshold: cfg.SlowQuery})
+	}
+	return opts
+}
+
+// slowQueryLog logs statements that fail or exceed threshold. The request ID
+// is added by the logger from ctx.
+type slowQueryLog struct {
+	threshold time.Duration
+}
+
+func (slowQueryLog) BeforeQuery(ctx context.Context, _ string) context.Context {
+	return ctx
+}
+
+func (l slowQueryLog) AfterQuery(ctx context.Context, query string, elapsed time.Duration, err error) {
+	if err == nil && elapsed < l.threshold {
+		return
+	}
+	query = strings.Join(strings.Fields(query), " ")
+	if err != nil {
+		slog.WarnContext(ctx, "query failed", "duration", elapsed, "error", err, "query", query)
+		return
+	}
+	slog.WarnContext(ctx, "slow query", "duration", elapsed, "query", query)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"refine-v2/backend/internal/auth"
+	"refine-v2/backend/internal/database"
+	"refine-v2/backend/internal/handlers"
+	"refine-v2/backend/internal/models"
+	"refine-v2/backend/internal/ratelimit"
+)
+
+// Rate limit policies, shared by every API version so switching versions does
+// not reset a client's budget.
+var (
+	loginPerIP    = ratelimit.Policy{Name: "login-ip", Burst: 20, Per: 10 * time.Minute}
+	loginPerEmail = ratelimit.Policy{Name: "login-email", Burst: 10, Per: 10 * time.Minute}
+	signupPerIP   = ratelimit.Policy{Name: "signup-ip", Burst: 5, Per: time.Hour}
+	emailsPerIP   = ratelimit.Policy{Name: "emails-ip", Burst: 5, Per: time.Hour}
+	importsPerIP  = ratelimit.Policy{Name: "imports-ip", Burst: 10, Per: time.Hour}
+)
+
+// api holds what the API routes need, so the same routes can be mounted under
+// each version prefix.
+type api struct {
+	store        database.Store
+	limiter      *ratelimit.Limiter
+	loginLockout *ratelimit.Lockout
+	// background runs imports after their request has been answered
+	background *handlers.Background
+	// validate checks request bodies against the OpenAPI document. It runs
+	// after auth and rate limiting, so those still answer first.
+	validate func(http.Handler) http.Handler
+	// timeout bounds every route except the exports, which stream for as long
+	// as the user's history takes to write
+	timeout func(http.Handler) http.Handler
+}
+
+// routes registers the API for a major version. Version 1 is the original
+// surface. Version 2 replaces problem generation, validation and session
+// saving with the richer model and serves every other route unchanged.
+func (a *api) routes(version int) func(r chi.Router) {
+	store, validate := a.store, a.validate
+
+	return func(r chi.Router) {
+		// Exports run without the handler timeout and keep extending their
+		// own write deadline, so a long history is not cut short
+		r.With(handlers.AuthMiddleware(store), validate).
+			Get("/export/account", handlers.ExportAccount(store))
+		r.With(handlers.AuthMiddleware(store, auth.ScopeStatsRead)).
+			Get("/export", handlers.ExportSessions(store))
+
+		r.Group(func(r chi.Router) {
+			r.Use(a.timeout)
+			a.boundedRoutes(r, version)
+		})
+	}
+}
+
+// boundedRoutes registers the routes that run under the handler timeout.
+func (a *api) boundedRoutes(r chi.Router, version int) {
+	store, limiter, validate := a.store, a.limiter, a.validate
+
+	if version >= 2 {
+		r.With(validate).Post("/problems", handlers.GenerateProblemsV2)
+		r.With(validate).Post("/validate", handlers.ValidateAnswersV2)
+		r.With(handlers.AuthMiddleware(store, auth.ScopeSessionsWrite), validate).
+			Post("/sessions", handlers.SaveGameSessionV2(store))
+	} else {
+		r.With(validate).Post("/problems", handlers.GenerateProblems)
+		r.With(validate).Post("/validate", handlers.ValidateAnswers)
+		r.With(handlers.AuthMiddleware(store, auth.ScopeSessionsWrite), validate).
+			Post("/sessions", handlers.SaveGameSession(store))
+	}
+	r.With(handlers.RateLimit(limiter, emailsPerIP, handlers.KeyByIP), validate).
+		Post("/emails", handlers.EmailSignup(store))
+	r.Get("/worksheets", handlers.GetWorksheet)
+
+	// Auth routes (public)
+	r.With(handlers.RateLimit(limiter, signupPerIP, handlers.KeyByIP), validate).
+		Post("/auth/signup", handlers.Signup(store))
+	r.With(
+		handlers.RateLimit(limiter, loginPerIP, handlers.KeyByIP),
+		handlers.RateLimit(limiter, loginPerEmail, handlers.KeyByEmail),
+		validate,
+	).Post("/auth/login", handlers.Login(store, a.loginLockout))
+	r.With(handlers.RateLimit(limiter, loginPerIP, handlers.KeyByIP), validate).
+		Post("/auth/login/2fa", handlers.LoginTwoFactor(store, a.loginLockout))
+	r.Post("/auth/logout", handlers.Logout)
+
+	// Protected routes (session only)
+	r.Group(func(r chi.Router) {
+		r.Use(handlers.AuthMiddleware(store))
+		r.Use(validate)
+
+		r.Get("/auth/me", handlers.GetCurrentUser(store))
+		r.Put("/auth/password", handlers.ChangePassword(store))
+		r.Put("/auth/username", handlers.ChangeUsername(store))
+		r.Delete("/auth/account", handlers.DeleteAccount(store))
+		r.Post("/auth/2fa/setup", handlers.SetupTwoFactor(store))
+		r.Post("/auth/2fa/enable", handlers.EnableTwoFactor(store))
+		r.Post("/auth/2fa/disable", handlers.DisableTwoFactor(store))
+		r.Post("/auth/2fa/recovery-codes", handlers.RegenerateRecoveryCodes(store))
+		r.Get("/auth/tokens", handlers.ListAPITokens(store))
+		r.Post("/auth/tokens", handlers.CreateAPIToken(store))
+		r.Delete("/auth/tokens/{id}", handlers.RevokeAPIToken(store))
+	})
+
+	// Protected routes (session or personal access token with scope)
+	r.With(
+		handlers.RateLimit(limiter, importsPerIP, handlers.KeyByIP),
+		handlers.AuthMiddleware(store, auth.ScopeSessionsWrite),
+	).Post("/imports", handlers.CreateImport(store, a.background))
+	r.With(handlers.AuthMiddleware(store, auth.ScopeStatsRead)).
+		Get("/imports/{id}", handlers.GetImport(store))
+	r.With(handlers.AuthMiddleware(store, auth.ScopeStatsRead)).
+		Get("/stats", handlers.GetUserStats(store))
+	r.With(handlers.AuthMiddleware(store, auth.ScopeStatsRead)).
+		Get("/stats/timeseries", handlers.GetTimeseries(store))
+	r.With(handlers.AuthMiddleware(store, auth.ScopeStatsRead)).
+		Get("/stats/weaknesses", handlers.GetWeaknesses(store))
+	r.With(handlers.AuthMiddleware(store, auth.ScopeStatsRead)).
+		Get("/leaderboard", handlers.GetLeaderboard(store))
+	r.With(handlers.AuthMiddleware(store, auth.ScopeStatsRead)).
+		Get("/sessions", handlers.ListGameSessions(store))
+
+	// Admin routes. Grant access with: UPDATE users SET role = 'admin' WHERE email = '...'
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(handlers.AuthMiddleware(store))
+		r.Use(handlers.RequireRole(models.RoleAdmin))
+		r.Use(validate)
+
+		r.Get("/users", handlers.AdminSearchUsers(store))
+		r.Get("/users/{id}/sessions", handlers.AdminGetUserSessions(store))
+		r.Post("/users/{id}/ban", handlers.AdminBanUser(store))
+		r.Post("/users/{id}/unban", handlers.AdminUnbanUser(store))
+		r.Put("/users/{id}/username", handlers.AdminRenameUser(store))
+		r.Post("/sessions/{id}/hide", handlers.AdminHideSession(store))
+		r.Post("/sessions/{id}/restore", handlers.AdminRestoreSession(store))
+		r.Get("/emails", handlers.AdminExportEmails(store))
+		r.Get("/audit", handlers.AdminGetAuditLog(store))
+		r.Get("/cache", handlers.AdminCacheStats)
+	})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"refine-v2/backend/internal/config"
+	"refine-v2/backend/internal/database"
+	"refine-v2/backend/internal/database/sqlstore"
+)
+
+const statsUsage = `usage: refine-api stats [flags] <command>
+
+commands:
+  rebuild   recompute stats and leaderboard aggregates from all game sessions`
+
+// runStats implements the "stats" subcommand.
+func runStats(cfg *config.Config, args []string) {
+	if len(args) != 1 || args[0] != "rebuild" {
+		usage(statsUsage)
+	}
+
+	if err := cfg.Database.Validate(); err != nil {
+		invalidConfig(err)
+	}
+
+	store, _, err := openStore(cfg.Database, sqlstore.Options{})
+	if err != nil {
+		fatal("Failed to open database", "error", err)
+	}
+	defer store.Close()
+
+	rebuilder, ok := store.(database.AggregateRebuilder)
+	if !ok {
+		fatal("This store computes stats on the fly; nothing to rebuild")
+	}
+
+	start := time.Now()
+	if err := rebuilder.RebuildAggregates(context.Background()); err != nil {
+		fatal("Rebuild failed", "error", err)
+	}
+	slog.Info("Rebuilt stats and leaderboards", "duration", time.Since(start).Round(time.Millisecond))
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"refine-v2/backend/internal/models"
+)
+
+// version is set at build time:
+//
+//	go build -ldflags "-X main.version=v1.2.3" ./cmd/server
+var version = "dev"
+
+// buildInfo combines the release version with the VCS details the Go
+// toolchain stamps into the binary.
+func buildInfo() models.BuildInfo {
+	info := models.BuildInfo{Version: version, GoVersion: runtime.Version()}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	modified := false
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Commit = s.Value
+		case "vcs.time":
+			info.BuiltAt = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if modified && info.Commit != "" {
+		info.Commit += "-dirty"
+	}
+	return info
+}
@@ -0,0 +1,157 @@
+// Package analytics summarises a user's session history for progress charts.
+package analytics
+
+import (
+	"math"
+	"time"
+
+	"refine-v2/backend/internal/database"
+	"refine-v2/backend/internal/models"
+)
+
+// Range is a run of whole days or weeks (models.PeriodDay or PeriodWeek), in
+// UTC like the leaderboard periods.
+type Range struct {
+	Interval string
+	From     time.Time
+	To       time.Time // exclusive
+}
+
+// NewRange returns the range of whole intervals from the one containing
+// first to the one containing last.
+func NewRange(interval string, first, last time.Time) Range {
+	from := database.PeriodStart(interval, first)
+	to := database.PeriodEnd(interval, database.PeriodStart(interval, last))
+	return Range{Interval: interval, From: from, To: to}
+}
+
+// Len is the number of intervals in the range.
+func (r Range) Len() int {
+	return int(r.To.Sub(r.From).Hours()/24) / r.days()
+}
+
+// Back returns the start of the interval n before the first one.
+func (r Range) Back(n int) time.Time {
+	return r.From.AddDate(0, 0, -n*r.days())
+}
+
+// Previous is the range of the same length just before r.
+func (r Range) Previous() Range {
+	return Range{Interval: r.Interval, From: r.Back(r.Len()), To: r.From}
+}
+
+func (r Range) days() int {
+	if r.Interval == models.PeriodWeek {
+		return 7
+	}
+	return 1
+}
+
+// HistoryStart is the earliest time Timeseries needs sessions from: the
+// previous range, or the rolling window of the first point if that is longer.
+func (r Range) HistoryStart(window int) time.Time {
+	return r.Back(max(r.Len(), window-1))
+}
+
+// Timeseries returns a point for every interval of r and the comparison with
+// the previous range. sessions must be oldest first and start at
+// r.HistoryStart(window). best is the personal best before that, if any.
+func Timeseries(sessions []models.GameSessionRecord, r Range, window int, best *int) ([]models.TimeseriesPoint, models.ProgressComparison) {
+	byInterval := map[int64][]models.GameSessionRecord{}
+	var current, previous []models.GameSessionRecord
+	prev := r.Previous()
+	for _, g := range sessions {
+		start := database.PeriodStart(r.Interval, g.PlayedAt).Unix()
+		byInterval[start] = append(byInterval[start], g)
+		switch {
+		case !g.PlayedAt.Before(r.From):
+			current = append(current, g)
+		case !g.PlayedAt.Before(prev.From):
+			previous = append(previous, g)
+		}
+		// Bests before the charted range are the starting personal best
+		if g.PlayedAt.Before(r.From) && (best == nil || g.Score > *best) {
+			best = &g.Score
+		}
+	}
+
+	points := make([]models.TimeseriesPoint, r.Len())
+	for i := range points {
+		start := database.PeriodStart(r.Interval, r.From.AddDate(0, 0, i*r.days()))
+		games := byInterval[start.Unix()]
+
+		var rolling []models.GameSessionRecord
+		for j := window - 1; j >= 0; j-- {
+			rolling = append(rolling, byInterval[start.AddDate(0, 0, -j*r.days()).Unix()]...)
+		}
+
+		p := models.TimeseriesPoint{
+			Start:           start,
+			ProgressSummary: Summarise(games),
+			Rolling:         Summarise(rolling),
+		}
+		if top := p.BestScore; top != nil && (best == nil || *top > *best) {
+			best = top
+			p.NewPersonalBest = true
+		}
+		p.PersonalBest = best
+		points[i] = p
+	}
+
+	cmp := models.ProgressComparison{
+		Current:  models.PeriodSummary{From: r.From, To: r.To, ProgressSummary: Summarise(current)},
+		Previous: models.PeriodSummary{From: prev.From, To: prev.To, ProgressSummary: Summarise(previous)},
+	}
+	cmp.ScoreChange = change(cmp.Current.AvgScore, cmp.Previous.AvgScore)
+	cmp.AccuracyChange = change(cmp.Current.AvgAccuracy, cmp.Previous.AvgAccuracy)
+	cmp.ProblemsPerMinuteChange = change(cmp.Current.ProblemsPerMinute, cmp.Previous.ProblemsPerMinute)
+	return points, cmp
+}
+
+// Summarise aggregates sessions. Values are rounded to one decimal place like
+// the per-mode stats.
+func Summarise(sessions []models.GameSessionRecord) models.ProgressSummary {
+	sum := models.ProgressSummary{Games: len(sessions)}
+	if len(sessions) == 0 {
+		return sum
+	}
+
+	best := sessions[0].Score
+	var scoreSum, problems, seconds, accGames int
+	var accSum float64
+	for _, g := range sessions {
+		best = max(best, g.Score)
+		scoreSum += g.Score
+		problems += g.Total
+		seconds += g.TimeLimit
+		if g.Total > 0 {
+			accSum += float64(g.Correct) * 100 / float64(g.Total)
+			accGames++
+		}
+	}
+
+	sum.BestScore = &best
+	sum.AvgScore = ptr(round1(float64(scoreSum) / float64(len(sessions))))
+	if accGames > 0 {
+		sum.AvgAccuracy = ptr(round1(accSum / float64(accGames)))
+	}
+	if seconds > 0 {
+		sum.ProblemsPerMinute = ptr(round1(float64(problems) * 60 / float64(seconds)))
+	}
+	return sum
+}
+
+func change(current, previous *float64) *float64 {
+	if current == nil || previous == nil {
+		return nil
+	}
+	return ptr(round1(*current - *previous))
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func round1(f float64) float64 {
+	return math.Round(f*10) / 10
+}
@@ -0,0 +1,325 @@
+package analytics
+
+import (
+	"cmp"
+	"fmt"
+	"refine-v2/backend/internal/generator"
+	"refine-v2/backend/internal/models"
+	"slices"
+)
+
+const (
+	weakestFacts = 10
+	// practiceGroups and practiceFacts are how many of the weakest cells and
+	// families, and of the weakest facts, the suggested practice set targets
+	practiceGroups = 3
+	practiceFacts  = 5
+	// minGroupProblems keeps cells and families with only a handful of
+	// problems out of the practice suggestions
+	minGroupProblems = 5
+)
+
+var operators = []string{"+", "-", "×", "÷"}
+
+type bin struct {
+	label string
+	r     generator.Range
+}
+
+// Operand sizes for the matrices: digit counts, and finer ranges within each
+// digit count.
+var axes = []struct {
+	name string
+	bins []bin
+}{
+	{"digits", []bin{
+		{"1 digit", generator.Range{Min: 1, Max: 9}},
+		{"2 digits", generator.Range{Min: 10, Max: 99}},
+		{"3 digits", generator.Range{Min: 100, Max: 999}},
+		{"4 digits", generator.Range{Min: 1000, Max: 9999}},
+		{"5 digits", generator.Range{Min: 10000, Max: generator.MaxOperand}},
+	}},
+	{"magnitude", []bin{
+		{"1-4", generator.Range{Min: 1, Max: 4}},
+		{"5-9", generator.Range{Min: 5, Max: 9}},
+		{"10-19", generator.Range{Min: 10, Max: 19}},
+		{"20-49", generator.Range{Min: 20, Max: 49}},
+		{"50-99", generator.Range{Min: 50, Max: 99}},
+		{"100-199", generator.Range{Min: 100, Max: 199}},
+		{"200-499", generator.Range{Min: 200, Max: 499}},
+		{"500-999", generator.Range{Min: 500, Max: 999}},
+		{"1000-1999", generator.Range{Min: 1000, Max: 1999}},
+		{"2000-4999", generator.Range{Min: 2000, Max: 4999}},
+		{"5000-9999", generator.Range{Min: 5000, Max: 9999}},
+		{"10000+", generator.Range{Min: 10000, Max: generator.MaxOperand}},
+	}},
+}
+
+// families are reported in this order: regrouping, then the times tables
+// and division by each number up to 12.
+var families = func() []generator.Target {
+	var targets []generator.Target
+	for _, key := range []string{"+carry", "-borrow"} {
+		t, _ := generator.ParseTarget(key)
+		targets = append(targets, t)
+	}
+	for _, op := range []string{"x", "/"} {
+		for n := 2; n <= 12; n++ {
+			t, _ := generator.ParseTarget(fmt.Sprintf("%s%d", op, n))
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}()
+
+// tally counts answers to a group of problems, with the summed pace of the
+// games they came from.
+type tally struct {
+	problems, correct int
+	pace              float64
+}
+
+func (t *tally) add(r models.ProblemResult, pace float64) {
+	t.problems++
+	if r.Correct {
+		t.correct++
+	}
+	t.pace += pace
+}
+
+func (t tally) stat(focus string) models.ProblemStat {
+	s := models.ProblemStat{Focus: focus, Problems: t.problems, Correct: t.correct}
+	if t.problems > 0 {
+		s.Accuracy = ptr(round1(float64(t.correct) * 100 / float64(t.problems)))
+		s.GameProblemsPerMinute = ptr(round1(t.pace / float64(t.problems)))
+	}
+	return s
+}
+
+// fact identifies a fact with the operands of + and × in ascending order.
+type fact struct {
+	num1, num2 int
+	operator   string
+	answer     int
+}
+
+// Weaknesses buckets the problems of sessions by operand size, fact family
+// and fact. Sessions without results are skipped; the caller reports them.
+func Weaknesses(sessions []models.SessionExport) models.WeaknessResponse {
+	var total tally
+	// cells[axis][operator][row, column]
+	cells := make([]map[string]map[[2]int]*tally, len(axes))
+	for i := range cells {
+		cells[i] = map[string]map[[2]int]*tally{}
+	}
+	familyTallies := make([]tally, len(families))
+	facts := map[fact]*tally{}
+
+	resp := models.WeaknessResponse{}
+	for _, s := range sessions {
+		if len(s.Results) == 0 || s.TimeLimit <= 0 {
+			continue
+		}
+		resp.Games++
+		pace := float64(s.Total) * 60 / float64(s.TimeLimit)
+
+		for _, r := range s.Results {
+			total.add(r, pace)
+			for i, axis := range axes {
+				byOp := cells[i][r.Operator]
+				if byOp == nil {
+					byOp = map[[2]int]*tally{}
+					cells[i][r.Operator] = byOp
+				}
+				key := [2]int{binOf(axis.bins, r.Num1), binOf(axis.bins, r.Num2)}
+				if byOp[key] == nil {
+					byOp[key] = &tally{}
+				}
+				byOp[key].add(r, pace)
+			}
+			for i, f := range families {
+				if covers(f, r) {
+					familyTallies[i].add(r, pace)
+				}
+			}
+			f := fact{num1: r.Num1, num2: r.Num2, operator: r.Operator, answer: r.Answer}
+			if (f.operator == "+" || f.operator == "×") && f.num1 > f.num2 {
+				f.num1, f.num2 = f.num2, f.num1
+			}
+			if facts[f] == nil {
+				facts[f] = &tally{}
+			}
+			facts[f].add(r, pace)
+		}
+	}
+
+	overall := total.stat("")
+	resp.Problems, resp.Accuracy = overall.Problems, overall.Accuracy
+	resp.Matrices = []models.WeaknessMatrix{}
+	resp.Families = []models.FactFamilyStat{}
+	resp.WeakestFacts = []models.FactStat{}
+	resp.Practice = []string{}
+	if total.problems == 0 {
+		return resp
+	}
+
+	// Cells and families weaker than the user's overall accuracy are
+	// candidates for practice
+	var weakGroups []models.ProblemStat
+	weak := func(s models.ProblemStat) {
+		if s.Problems >= minGroupProblems && *s.Accuracy < *overall.Accuracy {
+			weakGroups = append(weakGroups, s)
+		}
+	}
+
+	for i, axis := range axes {
+		for _, op := range operators {
+			if byOp := cells[i][op]; byOp != nil {
+				m := matrix(op, axis.name, axis.bins, byOp)
+				resp.Matrices = append(resp.Matrices, m)
+				if axis.name == "magnitude" {
+					for _, row := range m.Cells {
+						for _, cell := range row {
+							weak(cell)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	for i, f := range families {
+		if familyTallies[i].problems == 0 {
+			continue
+		}
+		s := familyTallies[i].stat(f.String())
+		resp.Families = append(resp.Families, models.FactFamilyStat{Family: familyLabel(f), ProblemStat: s})
+		weak(s)
+	}
+
+	for f, t := range facts {
+		if t.correct == t.problems {
+			continue
+		}
+		target := generator.Target{
+			Operator: f.operator,
+			Num1:     generator.Range{Min: f.num1, Max: f.num1},
+			Num2:     generator.Range{Min: f.num2, Max: f.num2},
+		}
+		resp.WeakestFacts = append(resp.WeakestFacts, models.FactStat{
+			Num1:        f.num1,
+			Operator:    f.operator,
+			Num2:        f.num2,
+			Answer:      f.answer,
+			ProblemStat: t.stat(target.String()),
+		})
+	}
+	// Most misses first, so a fact missed once in one try doesn't outrank
+	// one missed again and again
+	slices.SortFunc(resp.WeakestFacts, func(a, b models.FactStat) int {
+		return cmp.Or(
+			cmp.Compare(b.Problems-b.Correct, a.Problems-a.Correct),
+			cmp.Compare(*a.Accuracy, *b.Accuracy),
+			cmp.Compare(a.Focus, b.Focus),
+		)
+	})
+	resp.WeakestFacts = resp.WeakestFacts[:min(len(resp.WeakestFacts), weakestFacts)]
+
+	slices.SortStableFunc(weakGroups, func(a, b models.ProblemStat) int {
+		return cmp.Or(cmp.Compare(*a.Accuracy, *b.Accuracy), cmp.Compare(b.Problems, a.Problems))
+	})
+	var chosen []generator.Target
+	for _, s := range weakGroups[:min(len(weakGroups), practiceGroups)] {
+		t, _ := generator.ParseTarget(s.Focus)
+		chosen = append(chosen, t)
+		resp.Practice = append(resp.Practice, s.Focus)
+	}
+	// Facts a chosen cell or family already practises would only repeat it
+	added := 0
+	for _, f := range resp.WeakestFacts {
+		r := models.ProblemResult{Num1: f.Num1, Operator: f.Operator, Num2: f.Num2, Answer: f.Answer}
+		if added == practiceFacts || slices.ContainsFunc(chosen, func(t generator.Target) bool { return covers(t, r) }) {
+			continue
+		}
+		resp.Practice = append(resp.Practice, f.Focus)
+		added++
+	}
+	return resp
+}
+
+// matrix lays out one operator's cells on an axis.
+func matrix(op, axis string, bins []bin, byOp map[[2]int]*tally) models.WeaknessMatrix {
+	var rows, cols []int
+	for key := range byOp {
+		rows = append(rows, key[0])
+		cols = append(cols, key[1])
+	}
+	slices.Sort(rows)
+	slices.Sort(cols)
+	rows, cols = slices.Compact(rows), slices.Compact(cols)
+
+	m := models.WeaknessMatrix{Operator: op, Axis: axis, Cells: make([][]models.ProblemStat, len(rows))}
+	for _, c := range cols {
+		m.Columns = append(m.Columns, bins[c].label)
+	}
+	for i, r := range rows {
+		m.Rows = append(m.Rows, bins[r].label)
+		m.Cells[i] = make([]models.ProblemStat, len(cols))
+		for j, c := range cols {
+			target := generator.Target{Operator: op, Num1: bins[r].r, Num2: bins[c].r}
+			// Divisors are at least 2
+			if op == "÷" {
+				target.Num2.Min = max(target.Num2.Min, 2)
+			}
+			var t tally
+			if cell := byOp[[2]int{r, c}]; cell != nil {
+				t = *cell
+			}
+			m.Cells[i][j] = t.stat(target.String())
+		}
+	}
+	return m
+}
+
+func binOf(bins []bin, n int) int {
+	for i, b := range bins {
+		if n <= b.r.Max {
+			return i
+		}
+	}
+	return len(bins) - 1
+}
+
+// covers reports whether target t practises problems like r: additions
+// that carry, subtractions that borrow, a times table, division by a number,
+// or operands in ranges, either way round for + and ×.
+func covers(t generator.Target, r models.ProblemResult) bool {
+	if t.Operator != r.Operator {
+		return false
+	}
+	in := func(rng generator.Range, n int) bool { return rng.Min <= n && n <= rng.Max }
+	switch {
+	case t.Regroup:
+		return generator.Regroups(models.Problem{Num1: r.Num1, Operator: r.Operator, Num2: r.Num2, Answer: r.Answer})
+	case t.Num2 == generator.Range{}:
+		return r.Num1 == t.Num1.Min || r.Num2 == t.Num1.Min
+	case t.Num1 == generator.Range{}:
+		return r.Num2 == t.Num2.Min
+	}
+	commutes := t.Operator == "+" || t.Operator == "×"
+	return in(t.Num1, r.Num1) && in(t.Num2, r.Num2) ||
+		commutes && in(t.Num1, r.Num2) && in(t.Num2, r.Num1)
+}
+
+func familyLabel(f generator.Target) string {
+	switch {
+	case f.Regroup && f.Operator == "+":
+		return "Additions that carry"
+	case f.Regroup:
+		return "Subtractions that borrow"
+	case f.Operator == "×":
+		return fmt.Sprintf("×%d", f.Num1.Min)
+	default:
+		return fmt.Sprintf("÷%d", f.Num2.Min)
+	}
+}
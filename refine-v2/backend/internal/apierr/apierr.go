@@ -0,0 +1,104 @@
+// Package apierr defines the errors the API reports to clients. Each error
+// carries an HTTP status and a stable machine-readable code, so the store can
+// return an error that handlers send unchanged and clients can branch on the
+// code instead of the English message.
+package apierr
+
+import (
+	"net/http"
+
+	"refine-v2/backend/internal/models"
+)
+
+// Code identifies an error condition. Codes are part of the API contract:
+// add new ones freely, but never rename or reuse one.
+type Code string
+
+const (
+	// Request problems
+	CodeInvalidBody      Code = "invalid_body"      // the body is not valid JSON for the endpoint
+	CodeValidationFailed Code = "validation_failed" // see Details for the fields at fault
+	CodeRateLimited      Code = "rate_limited"
+	CodeTooLarge         Code = "too_large" // the body exceeds the endpoint's size limit
+
+	// Authentication and authorization
+	CodeUnauthenticated    Code = "unauthenticated"
+	CodeInvalidToken       Code = "invalid_token"
+	CodeInvalidCredentials Code = "invalid_credentials"
+	CodeInvalidPassword    Code = "invalid_password"
+	CodeInvalidChallenge   Code = "invalid_challenge"
+	CodeInvalidCode        Code = "invalid_code"
+	CodeForbidden          Code = "forbidden"
+	CodeAccountSuspended   Code = "account_suspended"
+	CodeTokenNotAllowed    Code = "token_not_allowed"
+	CodeMissingScope       Code = "missing_scope"
+
+	// State conflicts
+	CodeNotFound            Code = "not_found"
+	CodeConflict            Code = "conflict"
+	CodeEmailTaken          Code = "email_taken"
+	CodeUsernameTaken       Code = "username_taken"
+	CodeTokenLimitReached   Code = "token_limit_reached"
+	CodeTwoFactorEnabled    Code = "two_factor_enabled"
+	CodeTwoFactorNotEnabled Code = "two_factor_not_enabled"
+	CodeTwoFactorNotStarted Code = "two_factor_not_started"
+	CodeSeedUsed            Code = "seed_used"
+	CodeImportInProgress    Code = "import_in_progress"
+
+	// Routing and server failures
+	CodeMethodNotAllowed   Code = "method_not_allowed"
+	CodeInternal           Code = "internal"
+	CodeServiceUnavailable Code = "service_unavailable"
+)
+
+// Error is an error with everything needed to report it to a client.
+type Error struct {
+	Status  int
+	Code    Code
+	Message string
+	Details []models.FieldError
+}
+
+// New returns an error with the given status, code and client-facing message.
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Invalid reports a single field that failed validation. The message doubles
+// as the top-level message, so older clients that show only that still get
+// the reason.
+func Invalid(field, message string) *Error {
+	return Validation(models.FieldError{Field: field, Message: message})
+}
+
+// Validation reports one or more fields that failed validation.
+func Validation(details ...models.FieldError) *Error {
+	msg := "Validation failed"
+	if len(details) == 1 {
+		msg = details[0].Message
+	}
+	return &Error{
+		Status:  http.StatusBadRequest,
+		Code:    CodeValidationFailed,
+		Message: msg,
+		Details: details,
+	}
+}
+
+// Internal is the error reported for unexpected failures. The cause is
+// logged, never sent.
+func Internal(message string) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, message)
+}
+
+// Errors shared across handlers.
+var (
+	ErrInvalidBody     = New(http.StatusBadRequest, CodeInvalidBody, "Invalid request body")
+	ErrUnauthenticated = New(http.StatusUnauthorized, CodeUnauthenticated, "Not authenticated")
+	ErrForbidden       = New(http.StatusForbidden, CodeForbidden, "Forbidden")
+	ErrRateLimited     = New(http.StatusTooManyRequests, CodeRateLimited, "Too many requests, please try again later")
+)
@@ -0,0 +1,171 @@
+// Package apigateway runs an http.Handler behind AWS API Gateway. It converts
+// REST API proxy integration events (payload format 1.0, which HTTP APIs can
+// also be configured to send) into requests and buffers the handler's
+// response into the proxy response, so the router the standalone server uses
+// can be deployed unchanged as a Lambda function.
+package apigateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Handler adapts h to a Lambda handler for API Gateway proxy events.
+func Handler(h http.Handler) func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return func(ctx context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		req, err := NewRequest(ctx, event)
+		if err != nil {
+			return events.APIGatewayProxyResponse{}, err
+		}
+		w := newResponseWriter()
+		h.ServeHTTP(w, req)
+		return w.response(), nil
+	}
+}
+
+// NewRequest builds the http.Request an API Gateway proxy event describes.
+// The API Gateway request ID is passed as X-Request-Id when the client sent
+// none, so logs can be matched with API Gateway's.
+func NewRequest(ctx context.Context, event events.APIGatewayProxyRequest) (*http.Request, error) {
+	path := event.Path
+	// Without a custom domain the stage can appear as the first segment
+	if stage := event.RequestContext.Stage; stage != "" {
+		if rest, ok := strings.CutPrefix(path, "/"+stage); ok && (rest == "" || strings.HasPrefix(rest, "/")) {
+			path = rest
+		}
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	u := &url.URL{Path: path, RawQuery: query(event).Encode()}
+
+	body := []byte(event.Body)
+	if event.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(event.Body)
+		if err != nil {
+			return nil, fmt.Errorf("decode base64 body: %w", err)
+		}
+		body = decoded
+	}
+
+	req, err := http.NewRequestWithContext(ctx, event.HTTPMethod, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	req.RequestURI = u.RequestURI()
+
+	if len(event.MultiValueHeaders) > 0 {
+		for k, values := range event.MultiValueHeaders {
+			for _, v := range values {
+				req.Header.Add(k, v)
+			}
+		}
+	} else {
+		for k, v := range event.Headers {
+			req.Header.Set(k, v)
+		}
+	}
+	req.Host = req.Header.Get("Host")
+	if req.Header.Get("X-Request-Id") == "" && event.RequestContext.RequestID != "" {
+		req.Header.Set("X-Request-Id", event.RequestContext.RequestID)
+	}
+	if ip := event.RequestContext.Identity.SourceIP; ip != "" {
+		req.RemoteAddr = net.JoinHostPort(ip, "0")
+	}
+	return req, nil
+}
+
+func query(event events.APIGatewayProxyRequest) url.Values {
+	q := url.Values{}
+	if len(event.MultiValueQueryStringParameters) > 0 {
+		for k, values := range event.MultiValueQueryStringParameters {
+			q[k] = append(q[k], values...)
+		}
+		return q
+	}
+	for k, v := range event.QueryStringParameters {
+		q.Set(k, v)
+	}
+	return q
+}
+
+// responseWriter buffers a response for the proxy integration, which cannot
+// stream.
+type responseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newResponseWriter() *responseWriter {
+	return &responseWriter{header: http.Header{}}
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
+
+// Flush is a no-op; the body is sent once the handler returns.
+func (w *responseWriter) Flush() {}
+
+func (w *responseWriter) response() events.APIGatewayProxyResponse {
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if w.header.Get("Content-Type") == "" && w.body.Len() > 0 {
+		w.header.Set("Content-Type", http.DetectContentType(w.body.Bytes()))
+	}
+
+	resp := events.APIGatewayProxyResponse{
+		StatusCode:        status,
+		MultiValueHeaders: map[string][]string(w.header),
+	}
+	if w.body.Len() == 0 || isText(w.header.Get("Content-Type")) {
+		resp.Body = w.body.String()
+	} else {
+		resp.Body = base64.StdEncoding.EncodeToString(w.body.Bytes())
+		resp.IsBase64Encoded = true
+	}
+	return resp
+}
+
+// isText reports whether a body of this type can be returned as a string;
+// anything else is base64 encoded.
+func isText(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(t, "text/"),
+		t == "application/json",
+		strings.HasSuffix(t, "+json"),
+		t == "application/xml",
+		strings.HasSuffix(t, "+xml"),
+		t == "application/javascript":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,266 @@
+package apigateway
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestNewRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		event     events.APIGatewayProxyRequest
+		wantURI   string
+		wantBody  string
+		wantIDHdr string
+	}{
+		{
+			name: "stage prefix stripped",
+			event: events.APIGatewayProxyRequest{
+				HTTPMethod:     "GET",
+				Path:           "/prod/api/v1/stats",
+				RequestContext: events.APIGatewayProxyRequestContext{Stage: "prod", RequestID: "gw-1"},
+			},
+			wantURI:   "/api/v1/stats",
+			wantIDHdr: "gw-1",
+		},
+		{
+			name: "stage only",
+			event: events.APIGatewayProxyRequest{
+				HTTPMethod:     "GET",
+				Path:           "/prod",
+				RequestContext: events.APIGatewayProxyRequestContext{Stage: "prod"},
+			},
+			wantURI: "/",
+		},
+		{
+			name: "stage name as a path prefix is kept",
+			event: events.APIGatewayProxyRequest{
+				HTTPMethod:     "GET",
+				Path:           "/production/x",
+				RequestContext: events.APIGatewayProxyRequestContext{Stage: "prod"},
+			},
+			wantURI: "/production/x",
+		},
+		{
+			name: "multi-value query wins",
+			event: events.APIGatewayProxyRequest{
+				HTTPMethod:                      "GET",
+				Path:                            "/export",
+				QueryStringParameters:           map[string]string{"mode": "last"},
+				MultiValueQueryStringParameters: map[string][]string{"mode": {"a", "b"}},
+			},
+			wantURI: "/export?mode=a&mode=b",
+		},
+		{
+			name: "single-value query",
+			event: events.APIGatewayProxyRequest{
+				HTTPMethod:            "GET",
+				Path:                  "/export",
+				QueryStringParameters: map[string]string{"format": "csv"},
+			},
+			wantURI: "/export?format=csv",
+		},
+		{
+			name: "base64 body",
+			event: events.APIGatewayProxyRequest{
+				HTTPMethod:      "POST",
+				Path:            "/imports",
+				Body:            base64.StdEncoding.EncodeToString([]byte("a,b\n1,2\n")),
+				IsBase64Encoded: true,
+			},
+			wantURI:  "/imports",
+			wantBody: "a,b\n1,2\n",
+		},
+		{
+			name: "client request ID kept",
+			event: events.APIGatewayProxyRequest{
+				HTTPMethod:     "POST",
+				Path:           "/sessions",
+				Headers:        map[string]string{"X-Request-Id": "client-1"},
+				Body:           `{"mode":"addition"}`,
+				RequestContext: events.APIGatewayProxyRequestContext{RequestID: "gw-1"},
+			},
+			wantURI:   "/sessions",
+			wantBody:  `{"mode":"addition"}`,
+			wantIDHdr: "client-1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := NewRequest(context.Background(), tt.event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if req.Method != tt.event.HTTPMethod {
+				t.Errorf("method = %s, want %s", req.Method, tt.event.HTTPMethod)
+			}
+			if req.RequestURI != tt.wantURI || req.URL.RequestURI() != tt.wantURI {
+				t.Errorf("URI = %s (URL %s), want %s", req.RequestURI, req.URL.RequestURI(), tt.wantURI)
+			}
+			body, _ := io.ReadAll(req.Body)
+			if string(body) != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+			if got := req.Header.Get("X-Request-Id"); got != tt.wantIDHdr {
+				t.Errorf("X-Request-Id = %q, want %q", got, tt.wantIDHdr)
+			}
+		})
+	}
+}
+
+func TestNewRequestHeaders(t *testing.T) {
+	event := events.APIGatewayProxyRequest{
+		HTTPMethod: "GET",
+		Path:       "/",
+		Headers:    map[string]string{"Host": "ignored.example", "Accept": "text/csv"},
+		MultiValueHeaders: map[string][]string{
+			"Host":            {"api.example"},
+			"X-Forwarded-For": {"203.0.113.7", "10.0.0.1"},
+		},
+		RequestContext: events.APIGatewayProxyRequestContext{
+			Identity: events.APIGatewayRequestIdentity{SourceIP: "203.0.113.7"},
+		},
+	}
+	req, err := NewRequest(context.Background(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Host != "api.example" {
+		t.Errorf("Host = %q, want api.example", req.Host)
+	}
+	if got := req.Header.Values("X-Forwarded-For"); len(got) != 2 {
+		t.Errorf("X-Forwarded-For = %q, want both values", got)
+	}
+	// Multi-value headers replace the single-value map rather than merging
+	if got := req.Header.Get("Accept"); got != "" {
+		t.Errorf("Accept = %q, want it ignored", got)
+	}
+	if req.RemoteAddr != "203.0.113.7:0" {
+		t.Errorf("RemoteAddr = %q, want 203.0.113.7:0", req.RemoteAddr)
+	}
+}
+
+func TestNewRequestRemoteAddr(t *testing.T) {
+	for ip, want := range map[string]string{
+		"203.0.113.7": "203.0.113.7:0",
+		"2001:db8::1": "[2001:db8::1]:0",
+	} {
+		req, err := NewRequest(context.Background(), events.APIGatewayProxyRequest{
+			HTTPMethod: "GET",
+			Path:       "/",
+			RequestContext: events.APIGatewayProxyRequestContext{
+				Identity: events.APIGatewayRequestIdentity{SourceIP: ip},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.RemoteAddr != want {
+			t.Errorf("RemoteAddr for %s = %q, want %q", ip, req.RemoteAddr, want)
+		}
+		if host, _, err := net.SplitHostPort(req.RemoteAddr); err != nil || host != ip {
+			t.Errorf("SplitHostPort(%q) = %q, %v", req.RemoteAddr, host, err)
+		}
+	}
+}
+
+func TestNewRequestBadBase64(t *testing.T) {
+	_, err := NewRequest(context.Background(), events.APIGatewayProxyRequest{
+		HTTPMethod: "POST", Path: "/", Body: "not base64!", IsBase64Encoded: true,
+	})
+	if err == nil {
+		t.Error("invalid base64 body accepted")
+	}
+}
+
+func TestHandlerResponse(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x00")
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus int
+		wantBody   string
+		wantBase64 bool
+		wantType   string
+	}{
+		{
+			name: "json as text",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				io.WriteString(w, `{"ok":true}`)
+			},
+			wantStatus: http.StatusCreated,
+			wantBody:   `{"ok":true}`,
+			wantType:   "application/json",
+		},
+		{
+			name: "problem json as text",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/problem+json")
+				w.WriteHeader(http.StatusNotFound)
+				io.WriteString(w, `{}`)
+			},
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{}`,
+			wantType:   "application/problem+json",
+		},
+		{
+			name: "binary as base64",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/zip")
+				w.Write(png)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   base64.StdEncoding.EncodeToString(png),
+			wantBase64: true,
+			wantType:   "application/zip",
+		},
+		{
+			name: "type sniffed",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write(png)
+			},
+			wantStatus: http.StatusOK,
+			wantBody:   base64.StdEncoding.EncodeToString(png),
+			wantBase64: true,
+			wantType:   "image/png",
+		},
+		{
+			name: "empty",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+				w.WriteHeader(http.StatusOK) // later calls are ignored
+			},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "nothing written",
+			handler:    func(w http.ResponseWriter, r *http.Request) {},
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := Handler(tt.handler)(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if resp.Body != tt.wantBody || resp.IsBase64Encoded != tt.wantBase64 {
+				t.Errorf("body = %q (base64 %v), want %q (base64 %v)", resp.Body, resp.IsBase64Encoded, tt.wantBody, tt.wantBase64)
+			}
+			if got := http.Header(resp.MultiValueHeaders).Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+		})
+	}
+}
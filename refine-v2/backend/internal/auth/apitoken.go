@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Personal access token scopes.
+const (
+	ScopeStatsRead     = "stats:read"
+	ScopeSessionsWrite = "sessions:write"
+)
+
+var validScopes = map[string]bool{
+	ScopeStatsRead:     true,
+	ScopeSessionsWrite: true,
+}
+
+func ValidScope(scope string) bool {
+	return validScopes[scope]
+}
+
+// APITokenPrefix identifies personal access tokens so they can be told apart
+// from session JWTs in an Authorization header.
+const APITokenPrefix = "rfn_"
+
+// GenerateAPIToken returns a new personal access token, its hash for storage
+// and a short display prefix. The token itself is never stored.
+func GenerateAPIToken() (token, hash, display string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+	token = APITokenPrefix + hex.EncodeToString(buf)
+	return token, HashAPIToken(token), token[:len(APITokenPrefix)+6], nil
+}
+
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
+
+// HashAPIToken hashes a token for lookup. Tokens carry 256 bits of randomness
+// so an unsalted fast hash is sufficient.
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -10,13 +10,37 @@ import (
 type Claims struct {
 	UserID   int64  `json:"user_id"`
 	Username string `json:"username"`
+	Purpose  string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
-var jwtSecret []byte
+// PurposeTwoFactor marks a short-lived token issued after a correct password
+// for an account with 2FA enabled. It only grants access to the second login step.
+const PurposeTwoFactor = "2fa"
 
+// twoFactorAudience is the aud claim of challenge tokens. Consumers that
+// verify tokens against the JWKS without knowing about the purpose claim
+// still reject them unless they ask for this audience.
+const twoFactorAudience = "refine-2fa"
+
+// sessionTTL is how long a login session token is valid.
+var sessionTTL = 7 * 24 * time.Hour
+
+// SetSessionTTL sets the lifetime of tokens from GenerateToken.
+func SetSessionTTL(d time.Duration) {
+	sessionTTL = d
+}
+
+// SessionTTL returns the lifetime of tokens from GenerateToken.
+func SessionTTL() time.Duration {
+	return sessionTTL
+}
+
+// SetSecret installs a keyring that signs and verifies with a single HMAC
+// secret. Use SetKeyring for asymmetric keys and rotation.
 func SetSecret(secret string) {
-	jwtSecret = []byte(secret)
+	kr, _ := NewKeyring(NewHMACKey(DefaultHMACKeyID, []byte(secret)))
+	SetKeyring(kr)
 }
 
 func GenerateToken(userID int64, username string) (string, error) {
@@ -24,22 +48,70 @@ func GenerateToken(userID int64, username string) (string, error) {
 		UserID:   userID,
 		Username: username,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(7 * 24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(sessionTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	return signClaims(claims)
+}
+
+// GenerateTwoFactorToken issues the challenge token for the second login step.
+func GenerateTwoFactorToken(userID int64, username string) (string, error) {
+	claims := Claims{
+		UserID:   userID,
+		Username: username,
+		Purpose:  PurposeTwoFactor,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{twoFactorAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(5 * time.Minute)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	return signClaims(claims)
 }
 
+// ValidateToken validates a session token. Challenge tokens are rejected.
 func ValidateToken(tokenStr string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(t *jwt.Token) (any, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return jwtSecret, nil
-	})
+	claims, err := parseToken(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" || len(claims.Audience) > 0 {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// ValidateTwoFactorToken validates a challenge token from GenerateTwoFactorToken.
+func ValidateTwoFactorToken(tokenStr string) (*Claims, error) {
+	claims, err := parseToken(tokenStr, jwt.WithAudience(twoFactorAudience))
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != PurposeTwoFactor {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+func signClaims(claims Claims) (string, error) {
+	kr, err := currentKeyring()
+	if err != nil {
+		return "", err
+	}
+	return kr.sign(claims)
+}
+
+func parseToken(tokenStr string, opts ...jwt.ParserOption) (*Claims, error) {
+	kr, err := currentKeyring()
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append(opts, jwt.WithValidMethods([]string{"HS256", "RS256", "EdDSA"}))
+	token, err := jwt.ParseWithClaims(tokenStr, &Claims{}, kr.keyFunc, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is a single JWT key. Verify-only keys have no signing key.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	sign   any // []byte, *rsa.PrivateKey or ed25519.PrivateKey
+	verify any // []byte, *rsa.PublicKey or ed25519.PublicKey
+}
+
+// CanSign reports whether the key holds private material.
+func (k *Key) CanSign() bool {
+	return k.sign != nil
+}
+
+// DefaultHMACKeyID is the kid of tokens signed with the HMAC secret when no
+// other ID is configured.
+const DefaultHMACKeyID = "hs256"
+
+// NewHMACKey wraps a shared secret. The ID is a name chosen by the operator,
+// never derived from the secret. Tokens issued before key IDs existed carry
+// no kid header and are verified against the HMAC key.
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{
+		ID:     id,
+		Method: jwt.SigningMethodHS256,
+		sign:   secret,
+		verify: secret,
+	}
+}
+
+// LoadKeyFile reads a PEM encoded RSA or Ed25519 key. Private keys (PKCS#8 or
+// PKCS#1) can sign and verify; public keys (PKIX) can only verify.
+func LoadKeyFile(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block found", path)
+	}
+
+	var raw any
+	switch block.Type {
+	case "PRIVATE KEY":
+		raw, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		raw, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		raw, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	key, err := newAsymmetricKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+func newAsymmetricKey(raw any) (*Key, error) {
+	k := &Key{}
+	switch v := raw.(type) {
+	case *rsa.PrivateKey:
+		k.Method, k.sign, k.verify = jwt.SigningMethodRS256, v, &v.PublicKey
+	case *rsa.PublicKey:
+		k.Method, k.verify = jwt.SigningMethodRS256, v
+	case ed25519.PrivateKey:
+		k.Method, k.sign, k.verify = jwt.SigningMethodEdDSA, v, v.Public()
+	case ed25519.PublicKey:
+		k.Method, k.verify = jwt.SigningMethodEdDSA, v
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", raw)
+	}
+
+	if rsaPub, ok := k.verify.(*rsa.PublicKey); ok && rsaPub.N.BitLen() < 2048 {
+		return nil, errors.New("RSA keys must be at least 2048 bits")
+	}
+
+	jwk := k.jwk()
+	k.ID = jwk.thumbprint()
+	return k, nil
+}
+
+// Keyring holds the current signing key and every key still accepted for
+// verification. During rotation the previous key stays in the ring until all
+// tokens it signed have expired.
+type Keyring struct {
+	signing *Key
+	keys    map[string]*Key
+	legacy  *Key // verifies tokens without a kid header
+}
+
+// NewKeyring builds a keyring that signs with signing and additionally
+// verifies with each of verifyOnly.
+func NewKeyring(signing *Key, verifyOnly ...*Key) (*Keyring, error) {
+	if signing == nil || !signing.CanSign() {
+		return nil, errors.New("signing key must include private key material")
+	}
+
+	kr := &Keyring{signing: signing, keys: map[string]*Key{}}
+	for _, k := range append([]*Key{signing}, verifyOnly...) {
+		if _, dup := kr.keys[k.ID]; dup {
+			continue
+		}
+		kr.keys[k.ID] = k
+		if k.Method == jwt.SigningMethodHS256 && kr.legacy == nil {
+			kr.legacy = k
+		}
+	}
+	return kr, nil
+}
+
+func (kr *Keyring) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(kr.signing.Method, claims)
+	token.Header["kid"] = kr.signing.ID
+	return token.SignedString(kr.signing.sign)
+}
+
+func (kr *Keyring) keyFunc(t *jwt.Token) (any, error) {
+	key := kr.legacy
+	if kid, ok := t.Header["kid"].(string); ok {
+		key = kr.keys[kid]
+		// HMAC tokens from before the key ID was configurable carry an ID
+		// that no longer names a key
+		if key == nil && t.Method.Alg() == jwt.SigningMethodHS256.Alg() {
+			key = kr.legacy
+		}
+	}
+	if key == nil {
+		return nil, errors.New("unknown signing key")
+	}
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.verify, nil
+}
+
+var (
+	keyringMu sync.RWMutex
+	keyring   *Keyring
+)
+
+// SetKeyring installs the keyring used by GenerateToken and ValidateToken.
+func SetKeyring(kr *Keyring) {
+	keyringMu.Lock()
+	defer keyringMu.Unlock()
+	keyring = kr
+}
+
+func currentKeyring() (*Keyring, error) {
+	keyringMu.RLock()
+	defer keyringMu.RUnlock()
+	if keyring == nil {
+		return nil, errors.New("auth keyring not configured")
+	}
+	return keyring, nil
+}
+
+// --- JWKS ---
+
+// JWK is the public half of an asymmetric key as published in a JWK Set.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (k *Key) jwk() JWK {
+	b64 := base64.RawURLEncoding.EncodeToString
+	switch pub := k.verify.(type) {
+	case *rsa.PublicKey:
+		return JWK{Kty: "RSA", Kid: k.ID, Use: "sig", Alg: "RS256",
+			N: b64(pub.N.Bytes()), E: b64(big.NewInt(int64(pub.E)).Bytes())}
+	case ed25519.PublicKey:
+		return JWK{Kty: "OKP", Kid: k.ID, Use: "sig", Alg: "EdDSA", Crv: "Ed25519", X: b64(pub)}
+	}
+	return JWK{}
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint, used as the key ID.
+func (j JWK) thumbprint() string {
+	var members any
+	switch j.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{j.E, j.Kty, j.N}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{j.Crv, j.Kty, j.X}
+	}
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// PublicJWKS returns the asymmetric verification keys in the current keyring.
+// HMAC secrets are never published.
+func PublicJWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	kr, err := currentKeyring()
+	if err != nil {
+		return set
+	}
+	for _, k := range kr.keys {
+		if k.Method != jwt.SigningMethodHS256 {
+			set.Keys = append(set.Keys, k.jwk())
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
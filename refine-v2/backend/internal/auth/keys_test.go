@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newEd25519Key(t *testing.T) *Key {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := newAsymmetricKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func useKeyring(t *testing.T, signing *Key, verifyOnly ...*Key) {
+	t.Helper()
+	kr, err := NewKeyring(signing, verifyOnly...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetKeyring(kr)
+	t.Cleanup(func() { SetKeyring(nil) })
+}
+
+func TestTokenRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		key  func(t *testing.T) *Key
+	}{
+		{"hmac", func(*testing.T) *Key { return NewHMACKey(DefaultHMACKeyID, []byte("secret")) }},
+		{"ed25519", newEd25519Key},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useKeyring(t, tt.key(t))
+			token, err := GenerateToken(7, "alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := ValidateToken(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.UserID != 7 || claims.Username != "alice" {
+				t.Errorf("claims = %d %q, want 7 alice", claims.UserID, claims.Username)
+			}
+		})
+	}
+}
+
+func TestTwoFactorTokenIsNotASession(t *testing.T) {
+	useKeyring(t, NewHMACKey(DefaultHMACKeyID, []byte("secret")))
+
+	challenge, err := GenerateTwoFactorToken(7, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateToken(challenge); err == nil {
+		t.Error("challenge token accepted as a session")
+	}
+	if _, err := ValidateTwoFactorToken(challenge); err != nil {
+		t.Errorf("challenge token rejected: %v", err)
+	}
+
+	session, err := GenerateToken(7, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateTwoFactorToken(session); err == nil {
+		t.Error("session token accepted as a challenge")
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	old, current := newEd25519Key(t), newEd25519Key(t)
+
+	useKeyring(t, old)
+	token, err := GenerateToken(7, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The old key still verifies while it is in the ring
+	useKeyring(t, current, old)
+	if _, err := ValidateToken(token); err != nil {
+		t.Errorf("token from the previous key rejected: %v", err)
+	}
+
+	// and stops once it is removed
+	useKeyring(t, current)
+	if _, err := ValidateToken(token); err == nil {
+		t.Error("token from a removed key accepted")
+	}
+}
+
+func TestKeyringHMACKeyID(t *testing.T) {
+	secret := []byte("secret")
+
+	// Tokens without a kid, or with one from before it was configurable,
+	// verify against the HMAC key
+	for name, kid := range map[string]any{"no kid": nil, "old kid": "a1b2c3d4"} {
+		t.Run(name, func(t *testing.T) {
+			useKeyring(t, NewHMACKey("2026-10", secret))
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+				UserID: 7,
+				RegisteredClaims: jwt.RegisteredClaims{
+					ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+				},
+			})
+			if kid != nil {
+				token.Header["kid"] = kid
+			}
+			signed, err := token.SignedString(secret)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ValidateToken(signed); err != nil {
+				t.Errorf("legacy token rejected: %v", err)
+			}
+		})
+	}
+
+	// An asymmetric token naming an unknown key is not retried with HMAC
+	useKeyring(t, newEd25519Key(t))
+	token, err := GenerateToken(7, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	useKeyring(t, NewHMACKey(DefaultHMACKeyID, secret))
+	if _, err := ValidateToken(token); err == nil {
+		t.Error("token from an unknown key accepted")
+	}
+}
+
+func TestNewKeyringNeedsPrivateKey(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k, err := newAsymmetricKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.CanSign() {
+		t.Error("public key can sign")
+	}
+	if _, err := NewKeyring(k); err == nil {
+		t.Error("keyring built with a verify-only signing key")
+	}
+}
+
+func TestRSAKeySize(t *testing.T) {
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newAsymmetricKey(small); err == nil {
+		t.Error("1024-bit RSA key accepted")
+	}
+}
+
+func TestPublicJWKS(t *testing.T) {
+	signing, next := newEd25519Key(t), newEd25519Key(t)
+	useKeyring(t, signing, next, NewHMACKey(DefaultHMACKeyID, []byte("secret")))
+
+	set := PublicJWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("published %d keys, want the 2 asymmetric ones", len(set.Keys))
+	}
+	want := map[string]bool{signing.ID: true, next.ID: true}
+	for _, k := range set.Keys {
+		if !want[k.Kid] {
+			t.Errorf("unexpected kid %q", k.Kid)
+		}
+		if k.Kty != "OKP" || k.Crv != "Ed25519" || k.Alg != "EdDSA" || k.X == "" {
+			t.Errorf("key %q = %+v, want an Ed25519 public key", k.Kid, k)
+		}
+		// The kid is the RFC 7638 thumbprint of the published key
+		if k.thumbprint() != k.Kid {
+			t.Errorf("kid %q is not the key's thumbprint", k.Kid)
+		}
+	}
+	if set.Keys[0].Kid > set.Keys[1].Kid {
+		t.Error("keys not sorted by kid")
+	}
+
+	SetKeyring(nil)
+	if set := PublicJWKS(); set.Keys == nil || len(set.Keys) != 0 {
+		t.Errorf("JWKS without a keyring = %+v, want an empty set", set)
+	}
+}
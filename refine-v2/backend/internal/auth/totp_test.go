@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"encoding/base32"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed from RFC 6238 appendix B, base32 encoded.
+var rfc6238Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+// The RFC 6238 SHA-1 test vectors, truncated from 8 to 6 digits.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestHOTPMatchesRFC6238(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		if got := hotp([]byte("12345678901234567890"), uint64(v.unix/totpPeriod)); got != v.code {
+			t.Errorf("hotp at %d = %s, want %s", v.unix, got, v.code)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		at := time.Unix(v.unix, 0)
+		step, ok := MatchTOTP(rfc6238Secret, v.code, at)
+		if !ok {
+			t.Errorf("MatchTOTP(%s) at %d rejected", v.code, v.unix)
+			continue
+		}
+		if step != v.unix/totpPeriod {
+			t.Errorf("MatchTOTP(%s) at %d step = %d, want %d", v.code, v.unix, step, v.unix/totpPeriod)
+		}
+	}
+}
+
+func TestMatchTOTPWindow(t *testing.T) {
+	const unix, code = 1111111111, "050471"
+	tests := []struct {
+		name   string
+		offset time.Duration
+		want   bool
+	}{
+		{"previous step", -totpPeriod * time.Second, true},
+		{"next step", totpPeriod * time.Second, true},
+		{"two steps early", -2 * totpPeriod * time.Second, false},
+		{"two steps late", 2 * totpPeriod * time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := MatchTOTP(rfc6238Secret, code, time.Unix(unix, 0).Add(tt.offset))
+			if ok != tt.want {
+				t.Fatalf("MatchTOTP = %v, want %v", ok, tt.want)
+			}
+			if ok && step != unix/totpPeriod {
+				t.Errorf("step = %d, want the step the code was generated for (%d)", step, unix/totpPeriod)
+			}
+		})
+	}
+}
+
+func TestMatchTOTPRejectsMalformed(t *testing.T) {
+	at := time.Unix(59, 0)
+	tests := []struct {
+		name, secret, code string
+	}{
+		{"wrong code", rfc6238Secret, "287083"},
+		{"short code", rfc6238Secret, "28708"},
+		{"long code", rfc6238Secret, "94287082"},
+		{"bad secret", "not base32!", "287082"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := MatchTOTP(tt.secret, tt.code, at); ok {
+				t.Errorf("MatchTOTP(%q, %q) accepted", tt.secret, tt.code)
+			}
+		})
+	}
+
+	// Surrounding space and a lower-case secret are tolerated
+	if _, ok := MatchTOTP(rfc6238Secret, " 287082 ", at); !ok {
+		t.Error("code with surrounding space rejected")
+	}
+	lower := []byte(rfc6238Secret)
+	for i, c := range lower {
+		if c >= 'A' && c <= 'Z' {
+			lower[i] = c + 'a' - 'A'
+		}
+	}
+	if _, ok := MatchTOTP(string(lower), "287082", at); !ok {
+		t.Error("lower-case secret rejected")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := b32.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret %q is not base32: %v", secret, err)
+	}
+	if len(key) != 20 {
+		t.Errorf("secret is %d bytes, want 20", len(key))
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d codes, want %d", len(codes), recoveryCodeCount)
+	}
+	format := regexp.MustCompile(`^[0-9a-f]{5}-[0-9a-f]{5}$`)
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Errorf("code %q is not xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("code %q repeated", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestHashRecoveryCodeNormalises(t *testing.T) {
+	want := HashRecoveryCode("abcde-12345")
+	for _, entered := range []string{" abcde-12345 ", "ABCDE-12345", "abcde - 12345"} {
+		if got := HashRecoveryCode(entered); got != want {
+			t.Errorf("HashRecoveryCode(%q) differs from the canonical code", entered)
+		}
+	}
+	if HashRecoveryCode("abcde-12346") == want {
+		t.Error("different codes hash the same")
+	}
+}
@@ -0,0 +1,200 @@
+// Package cache provides a small read-through cache for values that are
+// expensive to compute and cheap to invalidate, such as leaderboards.
+//
+// Cache is the storage interface. Memory is the in-process implementation;
+// a shared cache such as Redis can implement the same interface so that
+// invalidations are seen by every instance.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"refine-v2/backend/internal/metrics"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Cache stores opaque values with per-entry TTLs.
+type Cache interface {
+	// Get returns the value for key and whether it was present and unexpired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value for ttl. A zero ttl keeps the value until it is
+	// deleted or evicted.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Loader reads JSON-encoded values through a Cache, loading them on a miss.
+// Concurrent misses for the same key share one load. Each Loader counts its
+// hits and misses under its name, both in Snapshot and in the cache metrics.
+type Loader[T any] struct {
+	cache Cache
+	ttl   time.Duration
+	group Group
+	stats *Stats
+}
+
+// NewLoader creates a Loader that caches values for ttl and records its
+// counts under name.
+func NewLoader[T any](c Cache, name string, ttl time.Duration) *Loader[T] {
+	return &Loader[T]{cache: c, ttl: ttl, stats: register(name)}
+}
+
+// Get returns the cached value for key, or calls load and caches its result.
+func (l *Loader[T]) Get(ctx context.Context, key string, load func(context.Context) (T, error)) (T, error) {
+	return l.get(ctx, key, nil, load)
+}
+
+// GetAt is Get for keys that are invalidated by advancing version before
+// deleting them. A load only joins loads that started at the same version,
+// and its result is kept only if version has not moved since the load
+// started, so a load that overlaps an invalidation cannot put back the value
+// the invalidation removed.
+func (l *Loader[T]) GetAt(ctx context.Context, key string, version *atomic.Int64, load func(context.Context) (T, error)) (T, error) {
+	return l.get(ctx, key, version, load)
+}
+
+func (l *Loader[T]) get(ctx context.Context, key string, version *atomic.Int64, load func(context.Context) (T, error)) (T, error) {
+	if v, ok := l.Lookup(ctx, key); ok {
+		return v, nil
+	}
+
+	call := key
+	var start int64
+	if version != nil {
+		start = version.Load()
+		call = key + "@" + strconv.FormatInt(start, 10)
+	}
+	// The load is shared, so one caller going away must not fail the others;
+	// the store's own query timeout still bounds it.
+	loadCtx := context.WithoutCancel(ctx)
+	v, err, _ := l.group.Do(call, func() (any, error) {
+		v, err := load(loadCtx)
+		if err != nil {
+			return v, err
+		}
+		if version == nil {
+			l.Set(ctx, key, v)
+			return v, nil
+		}
+		// An invalidation between the check and the Set deletes the key
+		// before the Set lands, so check again and delete it here instead.
+		if version.Load() == start {
+			l.Set(ctx, key, v)
+			if version.Load() != start {
+				l.cache.Delete(ctx, key)
+			}
+		}
+		return v, nil
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return v.(T), nil
+}
+
+// Lookup returns the cached value for key without loading it on a miss.
+// Cache errors are counted and treated as a miss so that a failing shared
+// cache degrades to uncached reads rather than failed requests.
+func (l *Loader[T]) Lookup(ctx context.Context, key string) (T, bool) {
+	var v T
+	b, ok, err := l.cache.Get(ctx, key)
+	if err != nil {
+		l.stats.fail()
+	} else if ok {
+		if err := json.Unmarshal(b, &v); err == nil {
+			l.stats.hit()
+			return v, true
+		}
+		l.stats.fail()
+	}
+	l.stats.miss()
+	return v, false
+}
+
+// Set caches v under key.
+func (l *Loader[T]) Set(ctx context.Context, key string, v T) {
+	b, err := json.Marshal(v)
+	if err == nil {
+		err = l.cache.Set(ctx, key, b, l.ttl)
+	}
+	if err != nil {
+		l.stats.fail()
+	}
+}
+
+// Stats counts lookups for one Loader.
+type Stats struct {
+	hits, misses, errors atomic.Uint64
+
+	hitCounter, missCounter, errorCounter prometheus.Counter
+}
+
+func (s *Stats) hit() {
+	s.hits.Add(1)
+	s.hitCounter.Inc()
+}
+
+func (s *Stats) miss() {
+	s.misses.Add(1)
+	s.missCounter.Inc()
+}
+
+func (s *Stats) fail() {
+	s.errors.Add(1)
+	s.errorCounter.Inc()
+}
+
+// Counts is a point-in-time copy of a Loader's Stats.
+type Counts struct {
+	Name   string `json:"name"`
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Errors uint64 `json:"errors"`
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]*Stats{}
+)
+
+// register returns the Stats for name, so Loaders sharing a name share counts.
+func register(name string) *Stats {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	s, ok := registry[name]
+	if !ok {
+		s = &Stats{
+			hitCounter:   metrics.CacheHits.WithLabelValues(name),
+			missCounter:  metrics.CacheMisses.WithLabelValues(name),
+			errorCounter: metrics.CacheErrors.WithLabelValues(name),
+		}
+		registry[name] = s
+	}
+	return s
+}
+
+// Snapshot returns the counts for every Loader, sorted by name.
+func Snapshot() []Counts {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	counts := make([]Counts, 0, len(registry))
+	for name, s := range registry {
+		counts = append(counts, Counts{
+			Name:   name,
+			Hits:   s.hits.Load(),
+			Misses: s.misses.Load(),
+			Errors: s.errors.Load(),
+		})
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Name < counts[j].Name })
+	return counts
+}
@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type entry struct {
+	value   []byte
+	expires time.Time // zero means no expiry
+}
+
+// Memory is an in-process Cache bounded by the total size of its keys and values.
+// When full, arbitrary entries are evicted to make room. State is not shared
+// between instances.
+type Memory struct {
+	mu       sync.Mutex
+	entries  map[string]entry
+	size     int
+	maxBytes int
+	stop     chan struct{}
+}
+
+// NewMemory creates a Memory cache holding up to maxBytes of entries that
+// sweeps expired entries every interval.
+func NewMemory(maxBytes int, interval time.Duration) *Memory {
+	m := &Memory{
+		entries:  make(map[string]entry),
+		maxBytes: maxBytes,
+		stop:     make(chan struct{}),
+	}
+	go m.sweep(interval)
+	return m
+}
+
+// Close stops the background sweeper.
+func (m *Memory) Close() {
+	close(m.stop)
+}
+
+func (m *Memory) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		m.removeLocked(key)
+		return nil, false, nil
+	}
+	return e.value, true, nil
+}
+
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	size := len(key) + len(value)
+	if size > m.maxBytes {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.removeLocked(key)
+	for k := range m.entries {
+		if m.size+size <= m.maxBytes {
+			break
+		}
+		m.removeLocked(k)
+	}
+
+	e := entry{value: value}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+	m.entries[key] = e
+	m.size += size
+	return nil
+}
+
+func (m *Memory) Delete(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, k := range keys {
+		m.removeLocked(k)
+	}
+	return nil
+}
+
+func (m *Memory) removeLocked(key string) {
+	if e, ok := m.entries[key]; ok {
+		m.size -= len(key) + len(e.value)
+		delete(m.entries, key)
+	}
+}
+
+func (m *Memory) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case now := <-ticker.C:
+			m.mu.Lock()
+			for k, e := range m.entries {
+				if !e.expires.IsZero() && now.After(e.expires) {
+					m.removeLocked(k)
+				}
+			}
+			m.mu.Unlock()
+		}
+	}
+}
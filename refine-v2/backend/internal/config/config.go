@@ -0,0 +1,330 @@
+// Package config defines the server's settings and loads them from, in
+// increasing order of precedence: built-in defaults, a YAML or TOML file,
+// environment variables and command-line flags.
+//
+// Every setting has a file key (section.name), a flag of the same name and,
+// where one existed before config files, its original environment variable.
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	Server   Server   `yaml:"server" toml:"server"`
+	Database Database `yaml:"database" toml:"database"`
+	Auth     Auth     `yaml:"auth" toml:"auth"`
+	CORS     CORS     `yaml:"cors" toml:"cors"`
+	Problems Problems `yaml:"problems" toml:"problems"`
+	Cache    Cache    `yaml:"cache" toml:"cache"`
+	Log      Log      `yaml:"log" toml:"log"`
+	Metrics  Metrics  `yaml:"metrics" toml:"metrics"`
+	Tracing  Tracing  `yaml:"tracing" toml:"tracing"`
+}
+
+type Server struct {
+	Port              int           `yaml:"port" toml:"port" env:"PORT" usage:"HTTP listen port"`
+	FrontendURL       string        `yaml:"frontend_url" toml:"frontend_url" env:"FRONTEND_URL" usage:"frontend origin; https enables secure cookies"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" toml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" usage:"time allowed to read request headers"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" toml:"read_timeout" env:"HTTP_READ_TIMEOUT" usage:"time allowed to read a whole request"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" toml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" usage:"time allowed to write a response; must exceed handler_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" usage:"keep-alive idle timeout"`
+	HandlerTimeout    time.Duration `yaml:"handler_timeout" toml:"handler_timeout" env:"HTTP_HANDLER_TIMEOUT" usage:"per-request deadline after which a 503 is sent"`
+	ShutdownDelay     time.Duration `yaml:"shutdown_delay" toml:"shutdown_delay" env:"SHUTDOWN_DELAY" usage:"keep serving this long after SIGTERM while /readyz reports draining"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" usage:"how long to wait for in-flight requests on shutdown"`
+	TrustedProxies    []string      `yaml:"trusted_proxies" toml:"trusted_proxies" env:"TRUSTED_PROXIES" usage:"comma-separated IPs or CIDRs of reverse proxies whose X-Forwarded-For is believed"`
+}
+
+type Database struct {
+	URL             string        `yaml:"url" toml:"url" env:"DATABASE_URL" secret:"url" usage:"postgres://..., sqlite:<path> or memory:"`
+	AutoMigrate     bool          `yaml:"auto_migrate" toml:"auto_migrate" env:"AUTO_MIGRATE" usage:"apply pending migrations at startup"`
+	QueryTimeout    time.Duration `yaml:"query_timeout" toml:"query_timeout" env:"DB_QUERY_TIMEOUT" usage:"deadline for each store call; 0 disables"`
+	SlowQuery       time.Duration `yaml:"slow_query" toml:"slow_query" env:"DB_SLOW_QUERY" usage:"log statements slower than this; 0 disables"`
+	MaxOpenConns    int           `yaml:"max_open_conns" toml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" usage:"Postgres connection pool size"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" toml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" usage:"Postgres idle connections kept open"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" toml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" usage:"close Postgres connections idle this long"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" usage:"recycle Postgres connections after this long"`
+}
+
+type Auth struct {
+	JWTSecret      string        `yaml:"jwt_secret" toml:"jwt_secret" env:"JWT_SECRET" secret:"true" usage:"HMAC secret; only verifies old tokens when signing_key_file is set"`
+	JWTKeyID       string        `yaml:"jwt_key_id" toml:"jwt_key_id" env:"JWT_KEY_ID" usage:"kid header of tokens signed with jwt_secret"`
+	SigningKeyFile string        `yaml:"signing_key_file" toml:"signing_key_file" env:"JWT_SIGNING_KEY_FILE" usage:"PEM RSA or Ed25519 private key that signs new tokens"`
+	VerifyKeyFiles []string      `yaml:"verify_key_files" toml:"verify_key_files" env:"JWT_VERIFY_KEY_FILES" usage:"comma-separated older keys still accepted during rotation"`
+	SessionTTL     time.Duration `yaml:"session_ttl" toml:"session_ttl" env:"SESSION_TTL" usage:"lifetime of login sessions"`
+}
+
+type CORS struct {
+	AllowedOrigins []string `yaml:"allowed_origins" toml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS" usage:"comma-separated origins; defaults to server.frontend_url"`
+	MaxAge         int      `yaml:"max_age" toml:"max_age" env:"CORS_MAX_AGE" usage:"seconds browsers may cache a preflight response"`
+}
+
+type Problems struct {
+	DefaultCount int    `yaml:"default_count" toml:"default_count" env:"PROBLEMS_DEFAULT_COUNT" usage:"problems generated when the request gives no count"`
+	MaxCount     int    `yaml:"max_count" toml:"max_count" env:"PROBLEMS_MAX_COUNT" usage:"largest problem set one request may generate"`
+	SeedSecret   string `yaml:"seed_secret" toml:"seed_secret" env:"SEED_SECRET" secret:"true" usage:"HMAC key that signs problem set seeds; defaults to one derived from auth.jwt_secret"`
+}
+
+type Cache struct {
+	TTL        time.Duration `yaml:"ttl" toml:"ttl" env:"CACHE_TTL" usage:"how long leaderboards are cached; 0 disables caching"`
+	MaxMB      int           `yaml:"max_mb" toml:"max_mb" env:"CACHE_MAX_MB" usage:"cache memory budget in MiB"`
+	ProblemTTL time.Duration `yaml:"problem_ttl" toml:"problem_ttl" env:"CACHE_PROBLEM_TTL" usage:"how long generated problem sets are kept for validation"`
+}
+
+type Log struct {
+	Level  string `yaml:"level" toml:"level" env:"LOG_LEVEL" usage:"debug, info, warn or error"`
+	Format string `yaml:"format" toml:"format" env:"LOG_FORMAT" usage:"json or text; empty picks json for the server and text for subcommands"`
+}
+
+type Metrics struct {
+	Addr  string `yaml:"addr" toml:"addr" env:"METRICS_ADDR" usage:"serve /metrics on this address instead of the main port"`
+	Token string `yaml:"token" toml:"token" env:"METRICS_TOKEN" secret:"true" usage:"bearer token required to scrape /metrics"`
+}
+
+type Tracing struct {
+	Exporter    string `yaml:"exporter" toml:"exporter" env:"OTEL_TRACES_EXPORTER" usage:"otlp or none; the endpoint comes from OTEL_EXPORTER_OTLP_ENDPOINT"`
+	ServiceName string `yaml:"service_name" toml:"service_name" env:"OTEL_SERVICE_NAME" usage:"service.name reported on spans"`
+}
+
+// Default returns the built-in settings.
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Port:              8080,
+			FrontendURL:       "http://localhost:5173",
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      35 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			HandlerTimeout:    30 * time.Second,
+			ShutdownTimeout:   25 * time.Second,
+		},
+		Database: Database{
+			AutoMigrate:     true,
+			QueryTimeout:    5 * time.Second,
+			MaxOpenConns:    10,
+			MaxIdleConns:    5,
+			ConnMaxIdleTime: 5 * time.Minute,
+			ConnMaxLifetime: 30 * time.Minute,
+		},
+		Auth: Auth{
+			JWTKeyID:   "hs256",
+			SessionTTL: 7 * 24 * time.Hour,
+		},
+		CORS: CORS{
+			MaxAge: 300,
+		},
+		Problems: Problems{
+			DefaultCount: 50,
+			MaxCount:     200,
+		},
+		Cache: Cache{
+			TTL:        time.Minute,
+			MaxMB:      64,
+			ProblemTTL: time.Hour,
+		},
+		Log: Log{
+			Level: "info",
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			ServiceName: "refine-api",
+		},
+	}
+}
+
+// Origins returns the CORS allowed origins, defaulting to the frontend.
+func (c *Config) Origins() []string {
+	if len(c.CORS.AllowedOrigins) > 0 {
+		return c.CORS.AllowedOrigins
+	}
+	return []string{c.Server.FrontendURL}
+}
+
+// SeedKey returns the key that signs problem set seeds: problems.seed_secret,
+// or a key derived from auth.jwt_secret so that existing deployments need no
+// new setting.
+func (c *Config) SeedKey() []byte {
+	if c.Problems.SeedSecret != "" {
+		return []byte(c.Problems.SeedSecret)
+	}
+	mac := hmac.New(sha256.New, []byte(c.Auth.JWTSecret))
+	mac.Write([]byte("refine problem seeds"))
+	return mac.Sum(nil)
+}
+
+// ProxyPrefixes returns server.trusted_proxies as prefixes. Validate has
+// already rejected entries that don't parse.
+func (c *Config) ProxyPrefixes() []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, p := range c.Server.TrustedProxies {
+		if prefix, err := parsePrefix(p); err == nil {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// parsePrefix parses a CIDR, or a single address as a prefix of its own.
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		return p.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	bad := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", describe(key), fmt.Sprintf(format, args...)))
+	}
+
+	s := c.Server
+	if s.Port < 1 || s.Port > 65535 {
+		bad("server.port", "must be between 1 and 65535")
+	}
+	if u, err := url.Parse(s.FrontendURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		bad("server.frontend_url", "must be an http or https URL, got %q", s.FrontendURL)
+	}
+	for _, d := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"server.read_header_timeout", s.ReadHeaderTimeout},
+		{"server.read_timeout", s.ReadTimeout},
+		{"server.write_timeout", s.WriteTimeout},
+		{"server.idle_timeout", s.IdleTimeout},
+		{"server.shutdown_delay", s.ShutdownDelay},
+		{"server.shutdown_timeout", s.ShutdownTimeout},
+		{"cache.ttl", c.Cache.TTL},
+		{"cache.problem_ttl", c.Cache.ProblemTTL},
+	} {
+		if d.value < 0 {
+			bad(d.key, "must not be negative")
+		}
+	}
+	if s.HandlerTimeout <= 0 {
+		bad("server.handler_timeout", "must be positive")
+	}
+	if s.WriteTimeout > 0 && s.WriteTimeout <= s.HandlerTimeout {
+		bad("server.write_timeout", "must be longer than server.handler_timeout (%s) so the timeout response can be sent", s.HandlerTimeout)
+	}
+	for _, p := range s.TrustedProxies {
+		if _, err := parsePrefix(p); err != nil {
+			bad("server.trusted_proxies", "%q is not an IP address or CIDR", p)
+		}
+	}
+
+	if err := c.Database.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.Auth.JWTSecret == "" && c.Auth.SigningKeyFile == "" {
+		bad("auth.jwt_secret", "auth.jwt_secret or auth.signing_key_file is required")
+	}
+	if c.Auth.JWTSecret != "" && c.Auth.JWTKeyID == "" {
+		bad("auth.jwt_key_id", "is required with auth.jwt_secret")
+	}
+	if c.Auth.SessionTTL <= 0 {
+		bad("auth.session_ttl", "must be positive")
+	}
+
+	if c.CORS.MaxAge < 0 {
+		bad("cors.max_age", "must not be negative")
+	}
+
+	if c.Problems.MaxCount < 1 {
+		bad("problems.max_count", "must be at least 1")
+	}
+	if c.Problems.DefaultCount < 1 || c.Problems.DefaultCount > c.Problems.MaxCount {
+		bad("problems.default_count", "must be between 1 and problems.max_count (%d)", c.Problems.MaxCount)
+	}
+	if c.Problems.SeedSecret == "" && c.Auth.JWTSecret == "" {
+		bad("problems.seed_secret", "is required when auth.jwt_secret is not set")
+	}
+
+	if c.Cache.MaxMB < 1 {
+		bad("cache.max_mb", "must be at least 1")
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		bad("log.level", "must be debug, info, warn or error, got %q", c.Log.Level)
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case "", "json", "text":
+	default:
+		bad("log.format", "must be json or text, got %q", c.Log.Format)
+	}
+
+	switch c.Tracing.Exporter {
+	case "", "none", "otlp":
+	default:
+		bad("tracing.exporter", "must be otlp or none, got %q", c.Tracing.Exporter)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate checks the settings needed to open the database, which is all the
+// migrate and stats subcommands use.
+func (d Database) Validate() error {
+	var errs []error
+	bad := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", describe(key), fmt.Sprintf(format, args...)))
+	}
+
+	switch {
+	case d.URL == "":
+		bad("database.url", "is required")
+	case strings.HasPrefix(d.URL, "postgres://"), strings.HasPrefix(d.URL, "postgresql://"),
+		strings.HasPrefix(d.URL, "sqlite:"), d.URL == "memory:":
+	default:
+		bad("database.url", "unsupported scheme in %q (want postgres://, sqlite: or memory:)", RedactURL(d.URL))
+	}
+	if d.QueryTimeout < 0 {
+		bad("database.query_timeout", "must not be negative")
+	}
+	if d.SlowQuery < 0 {
+		bad("database.slow_query", "must not be negative")
+	}
+	if d.MaxOpenConns < 1 {
+		bad("database.max_open_conns", "must be at least 1")
+	}
+	if d.MaxIdleConns < 0 {
+		bad("database.max_idle_conns", "must not be negative")
+	}
+	if d.ConnMaxIdleTime < 0 {
+		bad("database.conn_max_idle_time", "must not be negative")
+	}
+	if d.ConnMaxLifetime < 0 {
+		bad("database.conn_max_lifetime", "must not be negative")
+	}
+
+	return errors.Join(errs...)
+}
+
+// RedactURL drops the password from a URL, if it has one.
+func RedactURL(raw string) string {
+	if u, err := url.Parse(raw); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "REDACTED")
+			return u.String()
+		}
+	}
+	return raw
+}
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the environment variable that points at a config file when
+// -config is not given.
+const FileEnv = "REFINE_CONFIG"
+
+// setting is one leaf field of Config.
+type setting struct {
+	key    string // file key and flag name, e.g. "database.url"
+	env    string
+	usage  string
+	secret string // "true" to hide the value, "url" to hide its password
+	index  []int
+}
+
+var settings = collect(reflect.TypeOf(Config{}), "", nil)
+
+func collect(t reflect.Type, prefix string, index []int) []setting {
+	var out []setting
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := prefix + f.Tag.Get("yaml")
+		idx := append(append([]int(nil), index...), i)
+		if f.Type.Kind() == reflect.Struct && f.Type != reflect.TypeOf(time.Duration(0)) {
+			out = append(out, collect(f.Type, key+".", idx)...)
+			continue
+		}
+		out = append(out, setting{
+			key:    key,
+			env:    f.Tag.Get("env"),
+			usage:  f.Tag.Get("usage"),
+			secret: f.Tag.Get("secret"),
+			index:  idx,
+		})
+	}
+	return out
+}
+
+// describe names a setting with every way of setting it, for error messages.
+func describe(key string) string {
+	for _, s := range settings {
+		if s.key == key && s.env != "" {
+			return fmt.Sprintf("%s (%s, -%s)", key, s.env, key)
+		}
+	}
+	return key
+}
+
+// Load builds the configuration for a command from defaults, the file given
+// by -config or $REFINE_CONFIG, the environment (read through getenv) and the
+// flags in args. It returns the arguments left after the flags. The result is
+// not validated; call Validate or Database.Validate as appropriate.
+func Load(name string, args []string, getenv func(string) string, output io.Writer) (*Config, []string, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	configFile := fs.String("config", "", "path to a YAML or TOML config file (default $"+FileEnv+")")
+
+	flags := map[string]string{}
+	for _, s := range settings {
+		usage := s.usage
+		if s.env != "" {
+			usage += " ($" + s.env + ")"
+		}
+		fs.Func(s.key, usage, func(v string) error {
+			flags[s.key] = v
+			return nil
+		})
+	}
+	// Flags may come before or after the command's own arguments
+	var rest []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		rest = append(rest, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	cfg := Default()
+
+	path := *configFile
+	if path == "" {
+		path = getenv(FileEnv)
+	}
+	if path != "" {
+		if err := loadFile(cfg, path); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	v := reflect.ValueOf(cfg).Elem()
+	for _, s := range settings {
+		if s.env == "" {
+			continue
+		}
+		if raw := getenv(s.env); raw != "" {
+			if err := set(v.FieldByIndex(s.index), raw); err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+	for _, s := range settings {
+		if raw, ok := flags[s.key]; ok {
+			if err := set(v.FieldByIndex(s.index), raw); err != nil {
+				return nil, nil, fmt.Errorf("-%s: %w", s.key, err)
+			}
+		}
+	}
+
+	return cfg, rest, nil
+}
+
+// loadFile decodes path over cfg, choosing the format by extension. Unknown
+// keys are errors so that typos do not silently fall back to defaults.
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			keys := make([]string, len(undecoded))
+			for i, k := range undecoded {
+				keys[i] = k.String()
+			}
+			sort.Strings(keys)
+			return fmt.Errorf("%s: unknown keys %s", path, strings.Join(keys, ", "))
+		}
+	default:
+		return fmt.Errorf("%s: config file must end in .yaml, .yml or .toml", path)
+	}
+	return nil
+}
+
+// set parses raw into the field v.
+func set(v reflect.Value, raw string) error {
+	switch v.Interface().(type) {
+	case time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q (use e.g. 30s, 5m)", raw)
+		}
+		v.SetInt(int64(d))
+	case string:
+		v.SetString(raw)
+	case int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(int64(n))
+	case bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q (use true or false)", raw)
+		}
+		v.SetBool(b)
+	case []string:
+		var list []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// Redacted returns a copy of c with secrets masked, safe to print or log.
+func (c *Config) Redacted() *Config {
+	out := *c
+	v := reflect.ValueOf(&out).Elem()
+	for _, s := range settings {
+		f := v.FieldByIndex(s.index)
+		switch {
+		case s.secret == "true" && f.String() != "":
+			f.SetString("REDACTED")
+		case s.secret == "url":
+			f.SetString(RedactURL(f.String()))
+		}
+	}
+	return &out
+}
+
+// WriteYAML writes c as a YAML config file.
+func (c *Config) WriteYAML(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,168 @@
+// Package cached wraps a database.Store with a read-through cache for
+// leaderboards.
+//
+// SaveGameSession invalidates exactly the boards the new session can appear
+// on, and ImportGameSessions the personal boards of the imported sessions.
+// Admin actions and renames can change any global board, so they bump a
+// generation number that is part of every global board key instead. The
+// generation is kept in the Store rather than in the cache, where it could be
+// evicted, and starts from the clock so that a restarted instance never reads
+// boards cached under an earlier process's generation. With a cache shared
+// between instances, each instance therefore only sees its own invalidations
+// of global boards until the TTL runs out.
+//
+// Every invalidation also advances version before deleting keys, and loads
+// that overlap one are not cached: they may have read the boards from before
+// the change.
+package cached
+
+import (
+	"context"
+	"fmt"
+	"refine-v2/backend/internal/cache"
+	"refine-v2/backend/internal/database"
+	"refine-v2/backend/internal/models"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Store caches leaderboard reads; every other method goes straight to the
+// wrapped Store.
+type Store struct {
+	database.Store
+	cache    cache.Cache
+	global   *cache.Loader[[]models.LeaderboardEntry]
+	personal *cache.Loader[[]models.LeaderboardEntry]
+
+	generation atomic.Int64
+	version    atomic.Int64
+}
+
+// New wraps store, caching leaderboards in c for up to ttl.
+func New(store database.Store, c cache.Cache, ttl time.Duration) *Store {
+	s := &Store{
+		Store:    store,
+		cache:    c,
+		global:   cache.NewLoader[[]models.LeaderboardEntry](c, "leaderboard_global", ttl),
+		personal: cache.NewLoader[[]models.LeaderboardEntry](c, "leaderboard_personal", ttl),
+	}
+	s.generation.Store(time.Now().UnixNano())
+	return s
+}
+
+func (s *Store) GetGlobalLeaderboard(ctx context.Context, mode string, difficulty int, timeLimit int, period string) ([]models.LeaderboardEntry, error) {
+	key := s.globalKey(mode, difficulty, timeLimit, period)
+	return s.global.GetAt(ctx, key, &s.version, func(ctx context.Context) ([]models.LeaderboardEntry, error) {
+		return s.Store.GetGlobalLeaderboard(ctx, mode, difficulty, timeLimit, period)
+	})
+}
+
+func (s *Store) GetPersonalLeaderboard(ctx context.Context, userID int64, mode string, difficulty int, timeLimit int) ([]models.LeaderboardEntry, error) {
+	key := personalKey(userID, mode, difficulty, timeLimit)
+	return s.personal.GetAt(ctx, key, &s.version, func(ctx context.Context) ([]models.LeaderboardEntry, error) {
+		return s.Store.GetPersonalLeaderboard(ctx, userID, mode, difficulty, timeLimit)
+	})
+}
+
+func (s *Store) SaveGameSession(ctx context.Context, userID int64, req models.SaveSessionRequest) error {
+	if err := s.Store.SaveGameSession(ctx, userID, req); err != nil {
+		return err
+	}
+
+	keys := []string{personalKey(userID, req.Mode, req.Difficulty, req.TimeLimit)}
+	for _, period := range database.LeaderboardPeriods {
+		keys = append(keys, s.globalKey(req.Mode, req.Difficulty, req.TimeLimit, period))
+	}
+	s.invalidate(ctx, keys...)
+	return nil
+}
+
+// ImportGameSessions only touches personal boards: imported sessions are
+// never on the global ones.
+func (s *Store) ImportGameSessions(ctx context.Context, userID int64, sessions []models.ImportedSession) ([]int64, error) {
+	ids, err := s.Store.ImportGameSessions(ctx, userID, sessions)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var keys []string
+	for i, req := range sessions {
+		key := personalKey(userID, req.Mode, req.Difficulty, req.TimeLimit)
+		if ids[i] != 0 && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) > 0 {
+		s.invalidate(ctx, keys...)
+	}
+	return ids, nil
+}
+
+func (s *Store) UpdateUsername(ctx context.Context, userID int64, newUsername string) error {
+	if err := s.Store.UpdateUsername(ctx, userID, newUsername); err != nil {
+		return err
+	}
+	s.invalidateGlobal()
+	return nil
+}
+
+func (s *Store) DeleteUser(ctx context.Context, userID int64) error {
+	if err := s.Store.DeleteUser(ctx, userID); err != nil {
+		return err
+	}
+	s.invalidateGlobal()
+	return nil
+}
+
+func (s *Store) SetUserBanned(ctx context.Context, userID int64, banned bool, reason string, audit models.AuditEntry) (bool, error) {
+	found, err := s.Store.SetUserBanned(ctx, userID, banned, reason, audit)
+	if found {
+		s.invalidateGlobal()
+	}
+	return found, err
+}
+
+func (s *Store) AdminUpdateUsername(ctx context.Context, userID int64, username string, audit models.AuditEntry) (bool, error) {
+	found, err := s.Store.AdminUpdateUsername(ctx, userID, username, audit)
+	if found {
+		s.invalidateGlobal()
+	}
+	return found, err
+}
+
+func (s *Store) SetSessionHidden(ctx context.Context, sessionID int64, hidden bool, audit models.AuditEntry) (bool, error) {
+	found, err := s.Store.SetSessionHidden(ctx, sessionID, hidden, audit)
+	if found {
+		s.invalidateGlobal()
+	}
+	return found, err
+}
+
+// globalKey includes the current period start, so day and week boards roll
+// over without waiting for the TTL.
+func (s *Store) globalKey(mode string, difficulty, timeLimit int, period string) string {
+	start := database.PeriodStart(period, time.Now())
+	return fmt.Sprintf("leaderboard:global:%s:%s:%d:%d:%s:%d",
+		strconv.FormatInt(s.generation.Load(), 36), mode, difficulty, timeLimit, period, start.Unix())
+}
+
+func personalKey(userID int64, mode string, difficulty, timeLimit int) string {
+	return fmt.Sprintf("leaderboard:personal:%d:%s:%d:%d", userID, mode, difficulty, timeLimit)
+}
+
+// invalidate deletes keys after advancing version, so loads already under way
+// for them do not cache what they read.
+func (s *Store) invalidate(ctx context.Context, keys ...string) {
+	s.version.Add(1)
+	s.cache.Delete(ctx, keys...)
+}
+
+// invalidateGlobal moves every global board to new keys. Loads still running
+// under the old generation can only cache under the old keys, which nothing
+// reads any more.
+func (s *Store) invalidateGlobal() {
+	s.generation.Add(1)
+}
@@ -0,0 +1,98 @@
+package cached
+
+import (
+	"context"
+	"refine-v2/backend/internal/cache"
+	"refine-v2/backend/internal/database/memory"
+	"refine-v2/backend/internal/models"
+	"testing"
+	"time"
+)
+
+// slowStore blocks personal leaderboard reads after they have read the board
+// until release is closed.
+type slowStore struct {
+	*memory.Store
+	read    chan struct{}
+	release chan struct{}
+}
+
+func (s *slowStore) GetPersonalLeaderboard(ctx context.Context, userID int64, mode string, difficulty int, timeLimit int) ([]models.LeaderboardEntry, error) {
+	entries, err := s.Store.GetPersonalLeaderboard(ctx, userID, mode, difficulty, timeLimit)
+	s.read <- struct{}{}
+	<-s.release
+	return entries, err
+}
+
+func newTestStore(t *testing.T) (*memory.Store, *cache.Memory, int64) {
+	t.Helper()
+	mem := memory.New()
+	user, err := mem.CreateUser(context.Background(), "alice@example.com", "alice", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := cache.NewMemory(1<<20, time.Minute)
+	t.Cleanup(c.Close)
+	return mem, c, user.ID
+}
+
+func TestSaveDuringLoadIsNotCachedStale(t *testing.T) {
+	ctx := context.Background()
+	mem, c, userID := newTestStore(t)
+	slow := &slowStore{Store: mem, read: make(chan struct{}), release: make(chan struct{})}
+	s := New(slow, c, time.Minute)
+
+	done := make(chan []models.LeaderboardEntry)
+	go func() {
+		entries, err := s.GetPersonalLeaderboard(ctx, userID, "addition", 1, 60)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- entries
+	}()
+	<-slow.read
+
+	// The save lands after the load has read the empty board
+	req := models.SaveSessionRequest{Mode: "addition", Difficulty: 1, TimeLimit: 60, Score: 10, Correct: 10, Total: 10}
+	if err := s.SaveGameSession(ctx, userID, req); err != nil {
+		t.Fatal(err)
+	}
+	close(slow.release)
+	if entries := <-done; len(entries) != 0 {
+		t.Fatalf("load that started before the save returned %d entries", len(entries))
+	}
+
+	go func() { <-slow.read }()
+	entries, err := s.GetPersonalLeaderboard(ctx, userID, "addition", 1, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("board after the save has %d entries, want the saved session", len(entries))
+	}
+}
+
+func TestRenameInvalidatesGlobalBoards(t *testing.T) {
+	ctx := context.Background()
+	mem, c, userID := newTestStore(t)
+	s := New(mem, c, time.Minute)
+
+	req := models.SaveSessionRequest{Mode: "addition", Difficulty: 1, TimeLimit: 60, Score: 10, Correct: 10, Total: 10}
+	if err := s.SaveGameSession(ctx, userID, req); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := s.GetGlobalLeaderboard(ctx, "addition", 1, 60, models.PeriodAll); err != nil || len(entries) != 1 {
+		t.Fatalf("global board = %v, %v; want one entry", entries, err)
+	}
+
+	if err := s.UpdateUsername(ctx, userID, "alice2"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := s.GetGlobalLeaderboard(ctx, "addition", 1, 60, models.PeriodAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Username != "alice2" {
+		t.Errorf("global board after rename = %+v, want alice2", entries)
+	}
+}
@@ -0,0 +1,19 @@
+package database
+
+import (
+	"net/http"
+
+	"refine-v2/backend/internal/apierr"
+)
+
+// Domain errors returned by every Store implementation. Handlers should match
+// these with errors.Is rather than inspecting driver errors. They carry their
+// API status and code, so a handler can send one to the client as is.
+var (
+	ErrNotFound      = apierr.New(http.StatusNotFound, apierr.CodeNotFound, "Not found")
+	ErrEmailTaken    = apierr.New(http.StatusConflict, apierr.CodeEmailTaken, "Email already registered")
+	ErrUsernameTaken = apierr.New(http.StatusConflict, apierr.CodeUsernameTaken, "Username already taken")
+	ErrConflict      = apierr.New(http.StatusConflict, apierr.CodeConflict, "Conflicts with an existing record")
+	ErrSeedUsed      = apierr.New(http.StatusConflict, apierr.CodeSeedUsed, "This problem set has already been saved")
+	ErrImportActive  = apierr.New(http.StatusConflict, apierr.CodeImportInProgress, "Wait for your current import to finish")
+)
@@ -0,0 +1,845 @@
+// Package memory is an in-process Store for handler tests and local
+// development. Data is lost when the process exits.
+package memory
+
+import (
+	"context"
+	"math"
+	"refine-v2/backend/internal/database"
+	"refine-v2/backend/internal/models"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type user struct {
+	models.User
+	passwordHash string
+	totpSecret   string
+	totpLastStep int64
+	role         string
+	bannedAt     *time.Time
+	banReason    string
+	recovery     map[string]bool // code hash -> used
+}
+
+type apiToken struct {
+	models.APIToken
+	userID int64
+	hash   string
+}
+
+type session struct {
+	models.GameSessionRecord
+	userID int64
+	hidden bool
+}
+
+type importJob struct {
+	models.ImportJob
+	userID int64
+}
+
+type Store struct {
+	mu sync.Mutex
+
+	users    map[int64]*user
+	tokens   map[int64]*apiToken
+	sessions []*session // ordered by PlayedAt, then ID
+	emails   []models.EmailSignup
+	audit    []models.AuditEntry
+	imports  map[int64]*importJob
+	seeds    map[string]bool // seeds of saved sessions
+
+	nextUserID    int64
+	nextTokenID   int64
+	nextSessionID int64
+	nextAuditID   int64
+	nextImportID  int64
+}
+
+var _ database.Store = (*Store)(nil)
+
+func New() *Store {
+	return &Store{
+		users:   make(map[int64]*user),
+		tokens:  make(map[int64]*apiToken),
+		imports: make(map[int64]*importJob),
+		seeds:   make(map[string]bool),
+	}
+}
+
+func (s *Store) Ping(_ context.Context) error {
+	return nil
+}
+
+func (s *Store) Close() error {
+	return nil
+}
+
+// --- Users ---
+
+func (s *Store) CreateUser(_ context.Context, email, username, passwordHash string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Email == email {
+			return nil, database.ErrEmailTaken
+		}
+		if u.Username == username {
+			return nil, database.ErrUsernameTaken
+		}
+	}
+
+	s.nextUserID++
+	u := &user{
+		User: models.User{
+			ID:        s.nextUserID,
+			Email:     email,
+			Username:  username,
+			CreatedAt: time.Now(),
+		},
+		passwordHash: passwordHash,
+		role:         models.RoleUser,
+		recovery:     map[string]bool{},
+	}
+	s.users[u.ID] = u
+
+	created := u.User
+	return &created, nil
+}
+
+func (s *Store) GetUserByEmail(_ context.Context, email string) (*models.User, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if u.Email == email {
+			found := u.User
+			return &found, u.passwordHash, nil
+		}
+	}
+	return nil, "", database.ErrNotFound
+}
+
+func (s *Store) GetUserByID(_ context.Context, id int64) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return nil, database.ErrNotFound
+	}
+	found := u.User
+	return &found, nil
+}
+
+func (s *Store) GetUserAccess(_ context.Context, userID int64) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return "", false, database.ErrNotFound
+	}
+	return u.role, u.bannedAt != nil, nil
+}
+
+func (s *Store) GetPasswordHash(_ context.Context, userID int64) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return "", database.ErrNotFound
+	}
+	return u.passwordHash, nil
+}
+
+func (s *Store) UpdatePassword(_ context.Context, userID int64, newHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[userID]; ok {
+		u.passwordHash = newHash
+	}
+	return nil
+}
+
+func (s *Store) UpdateUsername(_ context.Context, userID int64, newUsername string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.renameLocked(userID, newUsername)
+	return err
+}
+
+func (s *Store) renameLocked(userID int64, newUsername string) (bool, error) {
+	u, ok := s.users[userID]
+	if !ok {
+		return false, nil
+	}
+	for _, other := range s.users {
+		if other.ID != userID && other.Username == newUsername {
+			return false, database.ErrUsernameTaken
+		}
+	}
+	u.Username = newUsername
+	return true, nil
+}
+
+func (s *Store) DeleteUser(_ context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.sessions[:0]
+	for _, gs := range s.sessions {
+		if gs.userID != userID {
+			kept = append(kept, gs)
+		}
+	}
+	s.sessions = kept
+
+	for id, t := range s.tokens {
+		if t.userID == userID {
+			delete(s.tokens, id)
+		}
+	}
+	for id, job := range s.imports {
+		if job.userID == userID {
+			delete(s.imports, id)
+		}
+	}
+
+	delete(s.users, userID)
+	return nil
+}
+
+// --- Two-factor auth ---
+
+func (s *Store) GetTOTP(_ context.Context, userID int64) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return "", false, database.ErrNotFound
+	}
+	return u.totpSecret, u.TwoFactorEnabled, nil
+}
+
+func (s *Store) SetPendingTOTP(_ context.Context, userID int64, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[userID]; ok {
+		u.totpSecret = secret
+		u.TwoFactorEnabled = false
+	}
+	return nil
+}
+
+func (s *Store) EnableTOTP(_ context.Context, userID int64, recoveryHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[userID]; ok {
+		u.TwoFactorEnabled = true
+		u.recovery = newRecovery(recoveryHashes)
+	}
+	return nil
+}
+
+func (s *Store) DisableTOTP(_ context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[userID]; ok {
+		u.totpSecret = ""
+		u.TwoFactorEnabled = false
+		u.recovery = map[string]bool{}
+	}
+	return nil
+}
+
+func (s *Store) ReplaceRecoveryCodes(_ context.Context, userID int64, hashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[userID]; ok {
+		u.recovery = newRecovery(hashes)
+	}
+	return nil
+}
+
+func (s *Store) UseTOTPStep(_ context.Context, userID int64, step int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok || step <= u.totpLastStep {
+		return false, nil
+	}
+	u.totpLastStep = step
+	return true, nil
+}
+
+func (s *Store) ConsumeRecoveryCode(_ context.Context, userID int64, hash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return false, nil
+	}
+	used, exists := u.recovery[hash]
+	if !exists || used {
+		return false, nil
+	}
+	u.recovery[hash] = true
+	return true, nil
+}
+
+func newRecovery(hashes []string) map[string]bool {
+	m := make(map[string]bool, len(hashes))
+	for _, h := range hashes {
+		m[h] = false
+	}
+	return m
+}
+
+// --- Personal access tokens ---
+
+func (s *Store) CreateAPIToken(_ context.Context, userID int64, name, hash, prefix string, scopes []string, expiresAt time.Time) (*models.APIToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.tokens {
+		if t.hash == hash {
+			return nil, database.ErrConflict
+		}
+	}
+
+	s.nextTokenID++
+	t := &apiToken{
+		APIToken: models.APIToken{
+			ID:        s.nextTokenID,
+			Name:      name,
+			Prefix:    prefix,
+			Scopes:    append([]string{}, scopes...),
+			ExpiresAt: expiresAt,
+			CreatedAt: time.Now(),
+		},
+		userID: userID,
+		hash:   hash,
+	}
+	s.tokens[t.ID] = t
+
+	created := t.APIToken
+	return &created, nil
+}
+
+func (s *Store) ListAPITokens(_ context.Context, userID int64) ([]models.APIToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tokens []models.APIToken
+	for _, t := range s.tokens {
+		if t.userID == userID {
+			tokens = append(tokens, t.APIToken)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID > tokens[j].ID })
+	return tokens, nil
+}
+
+func (s *Store) DeleteAPIToken(_ context.Context, userID, tokenID int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tokens[tokenID]
+	if !ok || t.userID != userID {
+		return false, nil
+	}
+	delete(s.tokens, tokenID)
+	return true, nil
+}
+
+func (s *Store) AuthenticateAPIToken(_ context.Context, hash string) (*models.User, []string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, t := range s.tokens {
+		if t.hash != hash || !t.ExpiresAt.After(now) {
+			continue
+		}
+		u, ok := s.users[t.userID]
+		if !ok {
+			break
+		}
+		t.LastUsedAt = &now
+		found := u.User
+		return &found, append([]string{}, t.Scopes...), nil
+	}
+	return nil, nil, database.ErrNotFound
+}
+
+// --- Email signups ---
+
+func (s *Store) InsertEmail(_ context.Context, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.emails {
+		if e.Email == email {
+			return database.ErrEmailTaken
+		}
+	}
+	s.emails = append(s.emails, models.EmailSignup{Email: email, CreatedAt: time.Now()})
+	return nil
+}
+
+// --- Game sessions ---
+
+func (s *Store) SaveGameSession(_ context.Context, userID int64, req models.SaveSessionRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if req.Seed != "" {
+		if s.seeds[req.Seed] {
+			return database.ErrSeedUsed
+		}
+		s.seeds[req.Seed] = true
+	}
+
+	s.nextSessionID++
+	s.insertSessionLocked(&session{
+		GameSessionRecord: models.GameSessionRecord{
+			ID:         s.nextSessionID,
+			Mode:       req.Mode,
+			Difficulty: req.Difficulty,
+			Score:      req.Score,
+			Correct:    req.Correct,
+			Total:      req.Total,
+			TimeLimit:  req.TimeLimit,
+			PlayedAt:   time.Now(),
+			Seed:       req.Seed,
+			Answers:    req.Answers,
+			Practice:   req.Practice,
+		},
+		userID: userID,
+	})
+	return nil
+}
+
+// insertSessionLocked adds gs in time order. New sessions go at the end;
+// imported ones can be placed anywhere in the history.
+func (s *Store) insertSessionLocked(gs *session) {
+	i := sort.Search(len(s.sessions), func(i int) bool {
+		other := s.sessions[i]
+		return other.PlayedAt.After(gs.PlayedAt) || (other.PlayedAt.Equal(gs.PlayedAt) && other.ID > gs.ID)
+	})
+	s.sessions = slices.Insert(s.sessions, i, gs)
+}
+
+// --- Imports ---
+
+func (s *Store) CreateImportJob(_ context.Context, userID int64, job *models.ImportJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, j := range s.imports {
+		if j.userID == userID && (j.Status == models.ImportPending || j.Status == models.ImportRunning) &&
+			j.CreatedAt.After(now.Add(-models.ImportStaleAfter)) {
+			return database.ErrImportActive
+		}
+	}
+
+	s.nextImportID++
+	job.ID = s.nextImportID
+	job.CreatedAt = now
+	s.imports[job.ID] = &importJob{ImportJob: copyImportJob(*job), userID: userID}
+	return nil
+}
+
+func (s *Store) UpdateImportJob(_ context.Context, job *models.ImportJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored, ok := s.imports[job.ID]; ok {
+		stored.ImportJob = copyImportJob(*job)
+	}
+	return nil
+}
+
+func (s *Store) GetImportJob(_ context.Context, userID, jobID int64) (*models.ImportJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.imports[jobID]
+	if !ok || job.userID != userID {
+		return nil, database.ErrNotFound
+	}
+	c := copyImportJob(job.ImportJob)
+	return &c, nil
+}
+
+// copyImportJob keeps the caller from sharing Rows with the stored job, as
+// a database would.
+func copyImportJob(job models.ImportJob) models.ImportJob {
+	job.Rows = slices.Clone(job.Rows)
+	return job
+}
+
+func (s *Store) ImportGameSessions(_ context.Context, userID int64, sessions []models.ImportedSession) ([]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]int64, len(sessions))
+	for i, req := range sessions {
+		duplicate := slices.ContainsFunc(s.sessions, func(gs *session) bool {
+			return gs.userID == userID && gs.PlayedAt.Equal(req.PlayedAt) &&
+				gs.Mode == req.Mode && gs.Difficulty == req.Difficulty && gs.TimeLimit == req.TimeLimit &&
+				gs.Score == req.Score && gs.Correct == req.Correct && gs.Total == req.Total
+		})
+		if duplicate {
+			continue
+		}
+		s.nextSessionID++
+		s.insertSessionLocked(&session{
+			GameSessionRecord: models.GameSessionRecord{
+				ID:         s.nextSessionID,
+				Mode:       req.Mode,
+				Difficulty: req.Difficulty,
+				Score:      req.Score,
+				Correct:    req.Correct,
+				Total:      req.Total,
+				TimeLimit:  req.TimeLimit,
+				PlayedAt:   req.PlayedAt,
+				Imported:   true,
+			},
+			userID: userID,
+		})
+		ids[i] = s.nextSessionID
+	}
+	return ids, nil
+}
+
+// --- Leaderboard ---
+
+func (s *Store) GetGlobalLeaderboard(_ context.Context, mode string, difficulty int, timeLimit int, period string) ([]models.LeaderboardEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start := database.PeriodStart(period, time.Now())
+	end := database.PeriodEnd(period, start)
+
+	return s.leaderboardLocked(func(gs *session) bool {
+		u, ok := s.users[gs.userID]
+		inPeriod := start.IsZero() || (!gs.PlayedAt.Before(start) && gs.PlayedAt.Before(end))
+		return ok && u.bannedAt == nil && !gs.hidden && !gs.Imported && !gs.Practice && inPeriod &&
+			gs.Mode == mode && gs.Difficulty == difficulty && gs.TimeLimit == timeLimit
+	}, true), nil
+}
+
+func (s *Store) GetPersonalLeaderboard(_ context.Context, userID int64, mode string, difficulty int, timeLimit int) ([]models.LeaderboardEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.leaderboardLocked(func(gs *session) bool {
+		return gs.userID == userID &&
+			gs.Mode == mode && gs.Difficulty == difficulty && gs.TimeLimit == timeLimit
+	}, false), nil
+}
+
+func (s *Store) leaderboardLocked(match func(*session) bool, withUsername bool) []models.LeaderboardEntry {
+	var matched []*session
+	for _, gs := range s.sessions {
+		if match(gs) {
+			matched = append(matched, gs)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].Score > matched[j].Score })
+	if len(matched) > 5 {
+		matched = matched[:5]
+	}
+
+	var entries []models.LeaderboardEntry
+	for i, gs := range matched {
+		e := models.LeaderboardEntry{
+			Rank:      i + 1,
+			Score:     gs.Score,
+			Correct:   gs.Correct,
+			Total:     gs.Total,
+			TimeLimit: gs.TimeLimit,
+			PlayedAt:  gs.PlayedAt,
+		}
+		if withUsername {
+			e.Username = s.users[gs.userID].Username
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// --- Stats ---
+
+func (s *Store) GetUserStats(_ context.Context, userID int64, difficulty int) ([]models.ModeStat, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type agg struct {
+		games, best, scoreSum int
+		accSum                float64
+		accN                  int
+	}
+	byMode := map[string]*agg{}
+	for _, gs := range s.sessions {
+		if gs.userID != userID || gs.Difficulty != difficulty {
+			continue
+		}
+		a, ok := byMode[gs.Mode]
+		if !ok {
+			a = &agg{best: gs.Score}
+			byMode[gs.Mode] = a
+		}
+		a.games++
+		a.best = max(a.best, gs.Score)
+		a.scoreSum += gs.Score
+		if gs.Total > 0 {
+			a.accSum += float64(gs.Correct) * 100 / float64(gs.Total)
+			a.accN++
+		}
+	}
+
+	var stats []models.ModeStat
+	for mode, a := range byMode {
+		st := models.ModeStat{
+			Mode:        mode,
+			Difficulty:  difficulty,
+			GamesPlayed: a.games,
+			BestScore:   a.best,
+			AvgScore:    round1(float64(a.scoreSum) / float64(a.games)),
+		}
+		if a.accN > 0 {
+			st.AvgAccuracy = round1(a.accSum / float64(a.accN))
+		}
+		stats = append(stats, st)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Mode < stats[j].Mode })
+	return stats, nil
+}
+
+func (s *Store) GetRecentGames(_ context.Context, userID int64, mode string) ([]models.GameSessionRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var games []models.GameSessionRecord
+	for i := len(s.sessions) - 1; i >= 0 && len(games) < 10; i-- {
+		gs := s.sessions[i]
+		if gs.userID == userID && (mode == "" || gs.Mode == mode) {
+			games = append(games, gs.GameSessionRecord)
+		}
+	}
+	return games, nil
+}
+
+func (s *Store) ListGameSessions(_ context.Context, userID int64, mode string, after *models.SessionCursor, limit int) ([]models.GameSessionRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Sessions are kept in time order, so walking backwards is newest first
+	var games []models.GameSessionRecord
+	for i := len(s.sessions) - 1; i >= 0 && len(games) < limit; i-- {
+		gs := s.sessions[i]
+		if gs.userID != userID || (mode != "" && gs.Mode != mode) {
+			continue
+		}
+		if after != nil && !gs.PlayedAt.Before(after.PlayedAt) &&
+			!(gs.PlayedAt.Equal(after.PlayedAt) && gs.ID < after.ID) {
+			continue
+		}
+		games = append(games, gs.GameSessionRecord)
+	}
+	return games, nil
+}
+
+func (s *Store) ListSessionsBetween(_ context.Context, userID int64, filter models.SessionFilter, from, to time.Time) ([]models.GameSessionRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var games []models.GameSessionRecord
+	for _, gs := range s.sessions {
+		if gs.userID == userID && matchesFilter(gs, filter) &&
+			!gs.PlayedAt.Before(from) && gs.PlayedAt.Before(to) {
+			games = append(games, gs.GameSessionRecord)
+		}
+	}
+	return games, nil
+}
+
+func (s *Store) GetBestScoreBefore(_ context.Context, userID int64, filter models.SessionFilter, t time.Time) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	best, found := 0, false
+	for _, gs := range s.sessions {
+		if gs.userID == userID && matchesFilter(gs, filter) && gs.PlayedAt.Before(t) {
+			best, found = max(best, gs.Score), true
+		}
+	}
+	return best, found, nil
+}
+
+func matchesFilter(gs *session, filter models.SessionFilter) bool {
+	return (filter.Mode == "" || gs.Mode == filter.Mode) &&
+		(filter.Difficulty == 0 || gs.Difficulty == filter.Difficulty) &&
+		(filter.TimeLimit == 0 || gs.TimeLimit == filter.TimeLimit)
+}
+
+func round1(f float64) float64 {
+	return math.Round(f*10) / 10
+}
+
+// --- Admin ---
+
+func (s *Store) SearchUsers(_ context.Context, query string, limit, offset int) ([]models.AdminUser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query = strings.ToLower(query)
+	var users []models.AdminUser
+	for _, u := range s.users {
+		if !strings.Contains(strings.ToLower(u.Email), query) &&
+			!strings.Contains(strings.ToLower(u.Username), query) {
+			continue
+		}
+		au := models.AdminUser{
+			ID:        u.ID,
+			Email:     u.Email,
+			Username:  u.Username,
+			Role:      u.role,
+			BannedAt:  u.bannedAt,
+			BanReason: u.banReason,
+			CreatedAt: u.CreatedAt,
+		}
+		for _, gs := range s.sessions {
+			if gs.userID == u.ID {
+				au.GamesPlayed++
+			}
+		}
+		users = append(users, au)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return page(users, limit, offset), nil
+}
+
+func (s *Store) GetUserSessions(_ context.Context, userID int64, limit, offset int) ([]models.AdminGameSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var sessions []models.AdminGameSession
+	for i := len(s.sessions) - 1; i >= 0; i-- {
+		gs := s.sessions[i]
+		if gs.userID == userID {
+			sessions = append(sessions, models.AdminGameSession{
+				GameSessionRecord: gs.GameSessionRecord,
+				LeaderboardHidden: gs.hidden,
+			})
+		}
+	}
+	return page(sessions, limit, offset), nil
+}
+
+func (s *Store) SetUserBanned(_ context.Context, userID int64, banned bool, reason string, audit models.AuditEntry) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[userID]
+	if !ok {
+		return false, nil
+	}
+	if banned {
+		now := time.Now()
+		u.bannedAt, u.banReason = &now, reason
+	} else {
+		u.bannedAt, u.banReason = nil, ""
+	}
+	s.auditLocked(audit)
+	return true, nil
+}
+
+func (s *Store) AdminUpdateUsername(_ context.Context, userID int64, username string, audit models.AuditEntry) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found, err := s.renameLocked(userID, username)
+	if err != nil || !found {
+		return false, err
+	}
+	s.auditLocked(audit)
+	return true, nil
+}
+
+func (s *Store) SetSessionHidden(_ context.Context, sessionID int64, hidden bool, audit models.AuditEntry) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, gs := range s.sessions {
+		if gs.ID == sessionID {
+			gs.hidden = hidden
+			s.auditLocked(audit)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) ListEmailSignups(_ context.Context) ([]models.EmailSignup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]models.EmailSignup{}, s.emails...), nil
+}
+
+func (s *Store) WriteAudit(_ context.Context, audit models.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.auditLocked(audit)
+	return nil
+}
+
+func (s *Store) ListAuditLog(_ context.Context, limit, offset int) ([]models.AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]models.AuditEntry, 0, len(s.audit))
+	for i := len(s.audit) - 1; i >= 0; i-- {
+		entries = append(entries, s.audit[i])
+	}
+	return page(entries, limit, offset), nil
+}
+
+func (s *Store) auditLocked(audit models.AuditEntry) {
+	s.nextAuditID++
+	audit.ID = s.nextAuditID
+	audit.CreatedAt = time.Now()
+	s.audit = append(s.audit, audit)
+}
+
+func page[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
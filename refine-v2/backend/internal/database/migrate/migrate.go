@@ -0,0 +1,283 @@
+// Package migrate applies numbered, reversible SQL migrations.
+//
+// Migrations are pairs of files named NNNN_description.up.sql and
+// NNNN_description.down.sql, normally embedded in the binary by the dialect
+// package. Applied versions are recorded in schema_migrations. Each
+// migration runs in its own transaction together with its bookkeeping row.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migration is one numbered schema change with its up and down scripts.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes one known migration and whether it has been applied.
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Locker serialises migrations across processes. Lock must block until the
+// lock is held and return a function that releases it.
+type Locker interface {
+	Lock(ctx context.Context, db *sql.DB) (unlock func(), err error)
+}
+
+// NoLock is a Locker for backends that cannot run concurrent migrations
+// anyway, such as a single-connection SQLite database.
+type NoLock struct{}
+
+func (NoLock) Lock(context.Context, *sql.DB) (func(), error) {
+	return func() {}, nil
+}
+
+// Migrator applies and reverts a fixed set of migrations against db.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	locker     Locker
+}
+
+// New loads migrations from fsys (the root of the migration directory).
+func New(db *sql.DB, fsys fs.FS, locker Locker) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations, locker: locker}, nil
+}
+
+// Load parses every *.up.sql / *.down.sql pair in fsys, sorted by version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || path.Ext(name) != ".sql" {
+			continue
+		}
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("migration %s: must end in .up.sql or .down.sql", name)
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		num, desc, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(num)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: name must start with a positive version number", name)
+		}
+
+		body, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: desc}
+			byVersion[version] = m
+		} else if m.Name != desc {
+			return nil, fmt.Errorf("migration %d: conflicting names %q and %q", version, m.Name, desc)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s: both up and down files are required", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Latest returns the highest known migration version.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies all pending migrations and returns those it applied.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var ran []Migration
+	for _, mig := range m.migrations {
+		if _, done := applied[mig.Version]; done {
+			continue
+		}
+		if err := m.run(ctx, mig.Up,
+			`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`,
+			mig.Version, mig.Name, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			return ran, fmt.Errorf("migration %d_%s up: %w", mig.Version, mig.Name, err)
+		}
+		ran = append(ran, mig)
+	}
+	return ran, nil
+}
+
+// Down reverts the most recent steps applied migrations and returns them.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	for i := len(m.migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+		mig := m.migrations[i]
+		if _, done := applied[mig.Version]; !done {
+			continue
+		}
+		if err := m.run(ctx, mig.Down,
+			`DELETE FROM schema_migrations WHERE version = $1`, mig.Version); err != nil {
+			return reverted, fmt.Errorf("migration %d_%s down: %w", mig.Version, mig.Name, err)
+		}
+		reverted = append(reverted, mig)
+	}
+	return reverted, nil
+}
+
+// Status lists every known migration with its applied time, if any.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, mig := range m.migrations {
+		statuses[i] = Status{Version: mig.Version, Name: mig.Name}
+		if at, ok := applied[mig.Version]; ok {
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses, nil
+}
+
+// Pending returns how many known migrations have not been applied.
+func (m *Migrator) Pending(ctx context.Context) (int, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *Migrator) lock(ctx context.Context) (func(), error) {
+	unlock, err := m.locker.Lock(ctx, m.db)
+	if err != nil {
+		return nil, fmt.Errorf("acquire migration lock: %w", err)
+	}
+	if err := m.ensureTable(ctx); err != nil {
+		unlock()
+		return nil, err
+	}
+	return unlock, nil
+}
+
+// applied_at is stored as RFC 3339 text so the table is identical on every dialect.
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx,
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    BIGINT PRIMARY KEY,
+			name       TEXT NOT NULL,
+			applied_at TEXT NOT NULL
+		)`)
+	return err
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]time.Time, error) {
+	rows, err := m.db.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at string
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		t, _ := time.Parse(time.RFC3339, at)
+		applied[version] = t
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) run(ctx context.Context, script, bookkeeping string, args ...any) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"refine-v2/backend/internal/models"
+	"time"
+)
+
+// LeaderboardPeriods lists every period a leaderboard is kept for.
+var LeaderboardPeriods = []string{models.PeriodAll, models.PeriodWeek, models.PeriodDay}
+
+// PeriodStart returns the UTC start of the period containing t. It returns
+// the zero time for models.PeriodAll.
+func PeriodStart(period string, t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case models.PeriodDay:
+		return day
+	case models.PeriodWeek:
+		// Weekday is 0 on Sunday; count Monday as the first day
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	}
+	return time.Time{}
+}
+
+// PeriodEnd returns the end (exclusive) of the period starting at start.
+func PeriodEnd(period string, start time.Time) time.Time {
+	switch period {
+	case models.PeriodDay:
+		return start.AddDate(0, 0, 1)
+	case models.PeriodWeek:
+		return start.AddDate(0, 0, 7)
+	}
+	return time.Time{}
+}
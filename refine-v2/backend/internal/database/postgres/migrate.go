@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"io/fs"
+	"refine-v2/backend/internal/database/migrate"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey is an arbitrary constant shared by every instance so only
+// one of them migrates at a time.
+const migrationLockKey = 0x7265_6669_6e65 // "refine"
+
+func NewMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(db, sub, advisoryLock{})
+}
+
+// advisoryLock holds a session-level Postgres advisory lock on a dedicated
+// connection for the duration of the migration run.
+type advisoryLock struct{}
+
+func (advisoryLock) Lock(ctx context.Context, db *sql.DB) (func(), error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return func() {
+		conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+		conn.Close()
+	}, nil
+}
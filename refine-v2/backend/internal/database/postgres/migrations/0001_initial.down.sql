@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS email_signups;
+DROP TABLE IF EXISTS game_sessions;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,34 @@
+-- Matches the schema created before versioned migrations existed, so
+-- existing databases adopt this version without changes.
+
+CREATE TABLE IF NOT EXISTS users (
+	id            BIGSERIAL PRIMARY KEY,
+	email         TEXT UNIQUE NOT NULL,
+	username      TEXT UNIQUE NOT NULL,
+	password_hash TEXT NOT NULL,
+	created_at    TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS game_sessions (
+	id         BIGSERIAL PRIMARY KEY,
+	user_id    BIGINT REFERENCES users(id),
+	mode       TEXT NOT NULL,
+	difficulty INT NOT NULL,
+	score      INT NOT NULL,
+	correct    INT NOT NULL,
+	total      INT NOT NULL,
+	time_limit INT NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS email_signups (
+	id         BIGSERIAL PRIMARY KEY,
+	email      TEXT UNIQUE NOT NULL,
+	created_at TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_game_sessions_leaderboard
+	ON game_sessions(mode, difficulty, score DESC);
+
+CREATE INDEX IF NOT EXISTS idx_game_sessions_user
+	ON game_sessions(user_id, mode, difficulty);
@@ -0,0 +1,11 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS recovery_codes (
+	id         BIGSERIAL PRIMARY KEY,
+	user_id    BIGINT NOT NULL REFERENCES users(id),
+	code_hash  TEXT NOT NULL,
+	used_at    TIMESTAMPTZ,
+	created_at TIMESTAMPTZ DEFAULT now(),
+	UNIQUE (user_id, code_hash)
+);
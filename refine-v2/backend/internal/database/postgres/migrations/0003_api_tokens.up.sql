@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS api_tokens (
+	id           BIGSERIAL PRIMARY KEY,
+	user_id      BIGINT NOT NULL REFERENCES users(id),
+	name         TEXT NOT NULL,
+	token_hash   TEXT UNIQUE NOT NULL,
+	prefix       TEXT NOT NULL,
+	scopes       TEXT NOT NULL,
+	expires_at   TIMESTAMPTZ NOT NULL,
+	last_used_at TIMESTAMPTZ,
+	created_at   TIMESTAMPTZ DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_tokens_user
+	ON api_tokens(user_id);
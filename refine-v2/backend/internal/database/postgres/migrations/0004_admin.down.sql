@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS admin_audit_log;
+ALTER TABLE game_sessions DROP COLUMN IF EXISTS leaderboard_hidden;
+ALTER TABLE users DROP COLUMN IF EXISTS ban_reason;
+ALTER TABLE users DROP COLUMN IF EXISTS banned_at;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'user';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS banned_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS ban_reason TEXT;
+ALTER TABLE game_sessions ADD COLUMN IF NOT EXISTS leaderboard_hidden BOOLEAN NOT NULL DEFAULT false;
+
+-- No FK on admin_id/target_id: audit entries must outlive deleted users
+CREATE TABLE IF NOT EXISTS admin_audit_log (
+	id          BIGSERIAL PRIMARY KEY,
+	admin_id    BIGINT NOT NULL,
+	action      TEXT NOT NULL,
+	target_type TEXT NOT NULL,
+	target_id   BIGINT,
+	details     TEXT NOT NULL DEFAULT '',
+	created_at  TIMESTAMPTZ DEFAULT now()
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS leaderboard_tops;
+DROP TABLE IF EXISTS personal_records;
+DROP TABLE IF EXISTS user_stats;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_game_sessions_user_played;
+ALTER TABLE game_sessions DROP COLUMN IF EXISTS answers;
+ALTER TABLE game_sessions DROP COLUMN IF EXISTS seed;
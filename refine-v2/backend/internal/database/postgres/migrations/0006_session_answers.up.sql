@@ -0,0 +1,9 @@
+-- Sessions saved through /api/v2 keep their seed and answers, so the
+-- individual problems can be regenerated for exports. answers is a JSON
+-- array of integers. Older sessions have neither.
+ALTER TABLE game_sessions ADD COLUMN IF NOT EXISTS seed TEXT;
+ALTER TABLE game_sessions ADD COLUMN IF NOT EXISTS answers TEXT;
+
+-- Keyset pagination of a user's history, newest first
+CREATE INDEX IF NOT EXISTS idx_game_sessions_user_played
+	ON game_sessions(user_id, created_at DESC, id DESC);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS import_jobs;
+ALTER TABLE game_sessions DROP COLUMN IF EXISTS imported;
@@ -0,0 +1,3 @@
+-- The last TOTP time step accepted, so a code cannot be replayed while it
+-- is still within the validation window.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT;
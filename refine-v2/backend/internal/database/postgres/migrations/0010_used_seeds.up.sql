@@ -0,0 +1,6 @@
+-- Seeds of sessions saved through /api/v2, so each problem set the server
+-- issues can be saved only once. Kept when sessions or users are deleted.
+CREATE TABLE IF NOT EXISTS used_seeds (
+	seed    TEXT PRIMARY KEY,
+	used_at TIMESTAMPTZ NOT NULL
+);
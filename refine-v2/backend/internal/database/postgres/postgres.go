@@ -0,0 +1,59 @@
+// Package postgres is the Postgres-backed Store used in production.
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"refine-v2/backend/internal/database/sqlstore"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Pool sizes the connection pool.
+type Pool struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxIdleTime time.Duration
+	ConnMaxLifetime time.Duration
+}
+
+func Connect(dsn string, pool Pool) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func New(db *sql.DB, opts sqlstore.Options) *sqlstore.Store {
+	return sqlstore.New(db, dialect{}, opts)
+}
+
+type dialect struct{}
+
+func (dialect) Time(t time.Time) any {
+	return t
+}
+
+func (dialect) LockStatement() string {
+	return `SELECT pg_advisory_xact_lock(hashtext($1))`
+}
+
+func (dialect) UniqueViolation(err error) (string, bool) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return pqErr.Constraint, true
+	}
+	return "", false
+}
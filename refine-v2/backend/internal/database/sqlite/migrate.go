@@ -0,0 +1,21 @@
+package sqlite
+
+import (
+	"database/sql"
+	"embed"
+	"io/fs"
+	"refine-v2/backend/internal/database/migrate"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// NewMigrator needs no cross-process lock: Connect limits the pool to one
+// connection and SQLite's own file lock serialises writers.
+func NewMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(db, sub, migrate.NoLock{})
+}
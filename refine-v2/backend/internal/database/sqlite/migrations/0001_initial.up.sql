@@ -0,0 +1,34 @@
+-- Timestamps are stored as fixed-width UTC text so they compare correctly;
+-- the column defaults match the layout the Go side writes.
+
+CREATE TABLE users (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	email         TEXT UNIQUE NOT NULL,
+	username      TEXT UNIQUE NOT NULL,
+	password_hash TEXT NOT NULL,
+	created_at    TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE TABLE game_sessions (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id    INTEGER REFERENCES users(id),
+	mode       TEXT NOT NULL,
+	difficulty INTEGER NOT NULL,
+	score      INTEGER NOT NULL,
+	correct    INTEGER NOT NULL,
+	total      INTEGER NOT NULL,
+	time_limit INTEGER NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE TABLE email_signups (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	email      TEXT UNIQUE NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX idx_game_sessions_leaderboard
+	ON game_sessions(mode, difficulty, score DESC);
+
+CREATE INDEX idx_game_sessions_user
+	ON game_sessions(user_id, mode, difficulty);
@@ -0,0 +1,14 @@
+CREATE TABLE api_tokens (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id      INTEGER NOT NULL REFERENCES users(id),
+	name         TEXT NOT NULL,
+	token_hash   TEXT UNIQUE NOT NULL,
+	prefix       TEXT NOT NULL,
+	scopes       TEXT NOT NULL,
+	expires_at   TIMESTAMP NOT NULL,
+	last_used_at TIMESTAMP,
+	created_at   TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX idx_api_tokens_user
+	ON api_tokens(user_id);
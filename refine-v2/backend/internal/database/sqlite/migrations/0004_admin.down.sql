@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS admin_audit_log;
+ALTER TABLE game_sessions DROP COLUMN leaderboard_hidden;
+ALTER TABLE users DROP COLUMN ban_reason;
+ALTER TABLE users DROP COLUMN banned_at;
+ALTER TABLE users DROP COLUMN role;
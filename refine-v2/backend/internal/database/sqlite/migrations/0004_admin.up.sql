@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user';
+ALTER TABLE users ADD COLUMN banned_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN ban_reason TEXT;
+ALTER TABLE game_sessions ADD COLUMN leaderboard_hidden BOOLEAN NOT NULL DEFAULT false;
+
+-- No FK on admin_id/target_id: audit entries must outlive deleted users
+CREATE TABLE admin_audit_log (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	admin_id    INTEGER NOT NULL,
+	action      TEXT NOT NULL,
+	target_type TEXT NOT NULL,
+	target_id   INTEGER,
+	details     TEXT NOT NULL DEFAULT '',
+	created_at  TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
@@ -0,0 +1,3 @@
+DROP TABLE leaderboard_tops;
+DROP TABLE personal_records;
+DROP TABLE user_stats;
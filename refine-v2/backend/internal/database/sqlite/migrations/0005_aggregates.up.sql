@@ -0,0 +1,80 @@
+-- Aggregates maintained by SaveGameSession so stats and leaderboards do not
+-- scan game_sessions. "refine-api stats rebuild" recomputes all of them.
+
+CREATE TABLE user_stats (
+	user_id        INTEGER NOT NULL REFERENCES users(id),
+	mode           TEXT NOT NULL,
+	difficulty     INTEGER NOT NULL,
+	time_limit     INTEGER NOT NULL,
+	games_played   INTEGER NOT NULL,
+	best_score     INTEGER NOT NULL,
+	score_sum      INTEGER NOT NULL,
+	-- accuracy_sum adds up per-game accuracy % over the accuracy_games with total > 0
+	accuracy_sum   REAL NOT NULL,
+	accuracy_games INTEGER NOT NULL,
+	PRIMARY KEY (user_id, mode, difficulty, time_limit)
+);
+
+-- Top sessions per user and game settings
+CREATE TABLE personal_records (
+	user_id    INTEGER NOT NULL REFERENCES users(id),
+	mode       TEXT NOT NULL,
+	difficulty INTEGER NOT NULL,
+	time_limit INTEGER NOT NULL,
+	session_id INTEGER NOT NULL REFERENCES game_sessions(id),
+	score      INTEGER NOT NULL,
+	PRIMARY KEY (user_id, mode, difficulty, time_limit, session_id)
+);
+
+-- Top visible sessions per period ("all", "week", "day") and game settings.
+-- period_start is the UTC start date of the period, or '' for all-time.
+CREATE TABLE leaderboard_tops (
+	period       TEXT NOT NULL,
+	period_start TEXT NOT NULL,
+	mode         TEXT NOT NULL,
+	difficulty   INTEGER NOT NULL,
+	time_limit   INTEGER NOT NULL,
+	session_id   INTEGER NOT NULL REFERENCES game_sessions(id),
+	score        INTEGER NOT NULL,
+	PRIMARY KEY (period, period_start, mode, difficulty, time_limit, session_id)
+);
+
+-- Backfill from existing sessions. Weeks start on Monday, in UTC.
+INSERT INTO user_stats
+SELECT user_id, mode, difficulty, time_limit, COUNT(*), MAX(score), SUM(score),
+	COALESCE(SUM(CASE WHEN total > 0 THEN correct * 100.0 / total END), 0),
+	SUM(CASE WHEN total > 0 THEN 1 ELSE 0 END)
+FROM game_sessions
+WHERE user_id IS NOT NULL
+GROUP BY user_id, mode, difficulty, time_limit;
+
+INSERT INTO personal_records
+SELECT user_id, mode, difficulty, time_limit, id, score
+FROM (
+	SELECT gs.*, ROW_NUMBER() OVER (
+		PARTITION BY user_id, mode, difficulty, time_limit ORDER BY score DESC, id
+	) AS rn
+	FROM game_sessions gs
+	WHERE user_id IS NOT NULL
+) ranked
+WHERE rn <= 5;
+
+INSERT INTO leaderboard_tops
+SELECT period, period_start, mode, difficulty, time_limit, id, score
+FROM (
+	SELECT p.period, p.period_start, p.mode, p.difficulty, p.time_limit, p.id, p.score,
+		ROW_NUMBER() OVER (
+			PARTITION BY p.period, p.period_start, p.mode, p.difficulty, p.time_limit
+			ORDER BY p.score DESC, p.id
+		) AS rn
+	FROM (
+		SELECT 'all' AS period, '' AS period_start, gs.* FROM game_sessions gs
+		UNION ALL
+		SELECT 'week', date(created_at, '-' || ((strftime('%w', created_at) + 6) % 7) || ' days'), gs.* FROM game_sessions gs
+		UNION ALL
+		SELECT 'day', date(created_at), gs.* FROM game_sessions gs
+	) p
+	JOIN users u ON u.id = p.user_id
+	WHERE NOT p.leaderboard_hidden AND u.banned_at IS NULL
+) ranked
+WHERE rn <= 5;
@@ -0,0 +1,3 @@
+DROP INDEX idx_game_sessions_user_played;
+ALTER TABLE game_sessions DROP COLUMN answers;
+ALTER TABLE game_sessions DROP COLUMN seed;
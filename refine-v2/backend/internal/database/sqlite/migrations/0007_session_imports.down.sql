@@ -0,0 +1,2 @@
+DROP TABLE import_jobs;
+ALTER TABLE game_sessions DROP COLUMN imported;
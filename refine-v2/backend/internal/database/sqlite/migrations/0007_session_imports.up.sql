@@ -0,0 +1,24 @@
+-- Sessions imported from other practice tools count towards the user's own
+-- stats but are kept off the global leaderboards.
+ALTER TABLE game_sessions ADD COLUMN imported BOOLEAN NOT NULL DEFAULT false;
+
+-- Background import jobs. results is a JSON array with the outcome of each
+-- row that has been processed.
+CREATE TABLE import_jobs (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id     INTEGER NOT NULL REFERENCES users(id),
+	status      TEXT NOT NULL,
+	format      TEXT NOT NULL,
+	total_rows  INTEGER NOT NULL,
+	processed   INTEGER NOT NULL DEFAULT 0,
+	imported    INTEGER NOT NULL DEFAULT 0,
+	duplicates  INTEGER NOT NULL DEFAULT 0,
+	invalid     INTEGER NOT NULL DEFAULT 0,
+	error       TEXT NOT NULL DEFAULT '',
+	results     TEXT NOT NULL DEFAULT '[]',
+	created_at  TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+	finished_at TIMESTAMP
+);
+
+CREATE INDEX idx_import_jobs_user
+	ON import_jobs(user_id);
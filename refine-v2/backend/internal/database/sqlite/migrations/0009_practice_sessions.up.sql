@@ -0,0 +1,46 @@
+-- Focused practice sets count towards the user's own stats but, like
+-- imported sessions, are kept off the global leaderboards. Sets saved
+-- before the flag existed are recognised by their seed, and the boards they
+-- are taken off are refilled with the next best visible sessions.
+ALTER TABLE game_sessions ADD COLUMN practice BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE game_sessions SET practice = true WHERE seed LIKE 'focus:%';
+
+CREATE TEMPORARY TABLE practice_boards AS
+SELECT DISTINCT lt.period, lt.period_start, lt.mode, lt.difficulty, lt.time_limit
+FROM leaderboard_tops lt
+JOIN game_sessions gs ON gs.id = lt.session_id
+WHERE gs.practice;
+
+DELETE FROM leaderboard_tops
+WHERE EXISTS (
+	SELECT 1 FROM practice_boards b
+	WHERE b.period = leaderboard_tops.period AND b.period_start = leaderboard_tops.period_start
+		AND b.mode = leaderboard_tops.mode AND b.difficulty = leaderboard_tops.difficulty
+		AND b.time_limit = leaderboard_tops.time_limit
+);
+
+-- As in 0005, with practice and imported sessions left out.
+INSERT INTO leaderboard_tops
+SELECT period, period_start, mode, difficulty, time_limit, id, score
+FROM (
+	SELECT p.period, p.period_start, p.mode, p.difficulty, p.time_limit, p.id, p.score,
+		ROW_NUMBER() OVER (
+			PARTITION BY p.period, p.period_start, p.mode, p.difficulty, p.time_limit
+			ORDER BY p.score DESC, p.id
+		) AS rn
+	FROM (
+		SELECT 'all' AS period, '' AS period_start, gs.* FROM game_sessions gs
+		UNION ALL
+		SELECT 'week', date(created_at, '-' || ((strftime('%w', created_at) + 6) % 7) || ' days'), gs.* FROM game_sessions gs
+		UNION ALL
+		SELECT 'day', date(created_at), gs.* FROM game_sessions gs
+	) p
+	JOIN users u ON u.id = p.user_id
+	JOIN practice_boards b ON b.period = p.period AND b.period_start = p.period_start
+		AND b.mode = p.mode AND b.difficulty = p.difficulty AND b.time_limit = p.time_limit
+	WHERE NOT p.leaderboard_hidden AND NOT p.imported AND NOT p.practice AND u.banned_at IS NULL
+) ranked
+WHERE rn <= 5;
+
+DROP TABLE practice_boards;
@@ -0,0 +1,71 @@
+// Package sqlite is an embedded Store for single-binary self-hosting. It uses
+// a pure Go driver, so no cgo or external database is required.
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"refine-v2/backend/internal/database/sqlstore"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// timeLayout is fixed width so stored timestamps compare correctly as text.
+// It matches the column defaults in the schema.
+const timeLayout = "2006-01-02 15:04:05.000"
+
+// Connect opens the database file at path, creating it if needed.
+func Connect(path string) (*sql.DB, error) {
+	dsn := "file:" + path +
+		"?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer; serialising through one connection
+	// avoids SQLITE_BUSY under concurrent requests.
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func New(db *sql.DB, opts sqlstore.Options) *sqlstore.Store {
+	return sqlstore.New(db, dialect{}, opts)
+}
+
+type dialect struct{}
+
+func (dialect) Time(t time.Time) any {
+	return t.UTC().Format(timeLayout)
+}
+
+// LockStatement is empty: the single connection already serialises
+// transactions.
+func (dialect) LockStatement() string {
+	return ""
+}
+
+func (dialect) UniqueViolation(err error) (string, bool) {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return "", false
+	}
+	if sqliteErr.Code() != sqlite3.SQLITE_CONSTRAINT_UNIQUE && sqliteErr.Code() != sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY {
+		return "", false
+	}
+	// Message format: "UNIQUE constraint failed: users.email"
+	msg := sqliteErr.Error()
+	if i := strings.LastIndex(msg, ": "); i >= 0 {
+		return msg[i+2:], true
+	}
+	return msg, true
+}
@@ -0,0 +1,364 @@
+package sqlite_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"refine-v2/backend/internal/database"
+	"refine-v2/backend/internal/database/migrate"
+	"refine-v2/backend/internal/database/sqlite"
+	"refine-v2/backend/internal/database/sqlstore"
+	"refine-v2/backend/internal/models"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// open creates a fresh database file and returns its store and migrator with
+// every migration applied.
+func open(t *testing.T) (*sqlstore.Store, *migrate.Migrator) {
+	t.Helper()
+	db, err := sqlite.Connect(filepath.Join(t.TempDir(), "refine.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := sqlite.NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return sqlite.New(db, sqlstore.Options{}), migrator
+}
+
+func createUser(t *testing.T, store database.Store, name string) int64 {
+	t.Helper()
+	u, err := store.CreateUser(context.Background(), name+"@example.com", name, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.ID
+}
+
+func TestMigrationsRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store, migrator := open(t)
+
+	if n, err := migrator.Pending(ctx); err != nil || n != 0 {
+		t.Fatalf("pending after up = %d, %v", n, err)
+	}
+	// Leave data behind so the down scripts run against a used schema
+	userID := createUser(t, store, "alice")
+	if err := store.SaveGameSession(ctx, userID, models.SaveSessionRequest{
+		Mode: "addition", Difficulty: 1, TimeLimit: 60, Score: 100, Correct: 10, Total: 12, Seed: "s1",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	reverted, err := migrator.Down(ctx, migrator.Latest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != migrator.Latest() {
+		t.Errorf("reverted %d migrations, want %d", len(reverted), migrator.Latest())
+	}
+	var tables int
+	if err := store.DB.QueryRow(
+		`SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name NOT IN ('schema_migrations', 'sqlite_sequence')`,
+	).Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Errorf("%d tables left after reverting every migration", tables)
+	}
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != migrator.Latest() {
+		t.Errorf("reapplied %d migrations, want %d", len(applied), migrator.Latest())
+	}
+	createUser(t, store, "alice")
+}
+
+// snapshot reads everything the precomputed aggregates serve.
+type snapshot struct {
+	Stats    map[int64][]models.ModeStat
+	Personal map[int64][]models.LeaderboardEntry
+	Global   map[string][]models.LeaderboardEntry
+}
+
+func takeSnapshot(t *testing.T, store database.Store, users []int64) snapshot {
+	t.Helper()
+	ctx := context.Background()
+	snap := snapshot{
+		Stats:    map[int64][]models.ModeStat{},
+		Personal: map[int64][]models.LeaderboardEntry{},
+		Global:   map[string][]models.LeaderboardEntry{},
+	}
+	for _, userID := range users {
+		for difficulty := 1; difficulty <= 3; difficulty++ {
+			stats, err := store.GetUserStats(ctx, userID, difficulty)
+			if err != nil {
+				t.Fatal(err)
+			}
+			snap.Stats[userID] = append(snap.Stats[userID], stats...)
+		}
+		for _, mode := range []string{"addition", "multiplication"} {
+			for difficulty := 1; difficulty <= 3; difficulty++ {
+				board, err := store.GetPersonalLeaderboard(ctx, userID, mode, difficulty, 60)
+				if err != nil {
+					t.Fatal(err)
+				}
+				snap.Personal[userID] = append(snap.Personal[userID], board...)
+			}
+		}
+	}
+	for _, mode := range []string{"addition", "multiplication"} {
+		for difficulty := 1; difficulty <= 3; difficulty++ {
+			for _, period := range []string{models.PeriodAll, models.PeriodWeek, models.PeriodDay} {
+				board, err := store.GetGlobalLeaderboard(ctx, mode, difficulty, 60, period)
+				if err != nil {
+					t.Fatal(err)
+				}
+				snap.Global[fmt.Sprintf("%s/%d/%s", mode, difficulty, period)] = board
+			}
+		}
+	}
+	return snap
+}
+
+func TestAggregatesMatchRebuild(t *testing.T) {
+	ctx := context.Background()
+	store, _ := open(t)
+	alice, bob := createUser(t, store, "alice"), createUser(t, store, "bob")
+	users := []int64{alice, bob}
+
+	saves := []struct {
+		user int64
+		req  models.SaveSessionRequest
+	}{
+		{alice, models.SaveSessionRequest{Mode: "addition", Difficulty: 1, TimeLimit: 60, Score: 120, Correct: 12, Total: 13}},
+		{alice, models.SaveSessionRequest{Mode: "addition", Difficulty: 1, TimeLimit: 60, Score: 90, Correct: 9, Total: 10}},
+		{alice, models.SaveSessionRequest{Mode: "addition", Difficulty: 2, TimeLimit: 60, Score: 70, Correct: 7, Total: 9}},
+		{alice, models.SaveSessionRequest{Mode: "addition", Difficulty: 1, TimeLimit: 60, Score: 300, Correct: 30, Total: 30, Practice: true}},
+		{bob, models.SaveSessionRequest{Mode: "addition", Difficulty: 1, TimeLimit: 60, Score: 150, Correct: 15, Total: 16}},
+		{bob, models.SaveSessionRequest{Mode: "multiplication", Difficulty: 3, TimeLimit: 60, Score: 40, Correct: 4, Total: 8}},
+	}
+	for _, s := range saves {
+		if err := store.SaveGameSession(ctx, s.user, s.req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lastMonth := time.Now().UTC().AddDate(0, -1, 0).Truncate(time.Second)
+	if _, err := store.ImportGameSessions(ctx, bob, []models.ImportedSession{
+		{SaveSessionRequest: models.SaveSessionRequest{Mode: "addition", Difficulty: 1, TimeLimit: 60, Score: 500, Correct: 50, Total: 50}, PlayedAt: lastMonth},
+		{SaveSessionRequest: models.SaveSessionRequest{Mode: "multiplication", Difficulty: 3, TimeLimit: 60, Score: 60, Correct: 6, Total: 7}, PlayedAt: lastMonth},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hide bob's best live session from the global boards
+	recent, err := store.ListGameSessions(ctx, bob, "addition", nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := store.SetSessionHidden(ctx, recent[0].ID, true, models.AuditEntry{AdminID: alice, Action: "hide_session"}); err != nil || !ok {
+		t.Fatalf("hide = %v, %v", ok, err)
+	}
+
+	incremental := takeSnapshot(t, store, users)
+	if err := store.RebuildAggregates(ctx); err != nil {
+		t.Fatal(err)
+	}
+	rebuilt := takeSnapshot(t, store, users)
+
+	if !reflect.DeepEqual(incremental, rebuilt) {
+		t.Errorf("incremental aggregates differ from a rebuild\nincremental: %+v\nrebuilt:     %+v", incremental, rebuilt)
+	}
+
+	// Practice, imported and hidden sessions stay off the global board
+	top := rebuilt.Global["addition/1/"+models.PeriodAll]
+	if len(top) != 2 || top[0].Score != 120 || top[1].Score != 90 {
+		t.Errorf("global addition board = %+v, want alice's 120 and 90 only", top)
+	}
+	// but count towards personal bests
+	if best := rebuilt.Personal[bob]; len(best) == 0 || best[0].Score != 500 {
+		t.Errorf("bob's personal board = %+v, want the imported 500 first", best)
+	}
+}
+
+func TestPracticeMigrationRefillsBoards(t *testing.T) {
+	ctx := context.Background()
+	store, migrator := open(t)
+	alice := createUser(t, store, "alice")
+
+	// A focused set saved before 0009 sits at the top of the board
+	for _, score := range []int{10, 20, 30, 40, 50, 60} {
+		if err := store.SaveGameSession(ctx, alice, models.SaveSessionRequest{
+			Mode: "addition", Difficulty: 1, TimeLimit: 60, Score: score, Correct: score / 10, Total: score / 10,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.SaveGameSession(ctx, alice, models.SaveSessionRequest{
+		Mode: "addition", Difficulty: 1, TimeLimit: 60, Score: 100, Correct: 10, Total: 10, Seed: "focus:addition:1:abc",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Down(ctx, migrator.Latest()-8); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	migrated := takeSnapshot(t, store, []int64{alice})
+	var scores []int
+	for _, e := range migrated.Global["addition/1/"+models.PeriodAll] {
+		scores = append(scores, e.Score)
+	}
+	if want := []int{60, 50, 40, 30, 20}; !reflect.DeepEqual(scores, want) {
+		t.Errorf("global board after 0009 = %v, want %v", scores, want)
+	}
+	if err := store.RebuildAggregates(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt := takeSnapshot(t, store, []int64{alice}); !reflect.DeepEqual(migrated, rebuilt) {
+		t.Errorf("aggregates after 0009 differ from a rebuild\nmigrated: %+v\nrebuilt:  %+v", migrated, rebuilt)
+	}
+}
+
+func TestListGameSessionsCursor(t *testing.T) {
+	ctx := context.Background()
+	store, _ := open(t)
+	userID := createUser(t, store, "alice")
+
+	// Several sessions share a time, so pages must break ties by ID
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	var sessions []models.ImportedSession
+	for i := range 7 {
+		sessions = append(sessions, models.ImportedSession{
+			SaveSessionRequest: models.SaveSessionRequest{Mode: "addition", Difficulty: 1, TimeLimit: 60, Score: 10 * i, Correct: i, Total: 10},
+			PlayedAt:           base.Add(time.Duration(i/3) * time.Minute),
+		})
+	}
+	if _, err := store.ImportGameSessions(ctx, userID, sessions); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := store.ListGameSessions(ctx, userID, "", nil, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != len(sessions) {
+		t.Fatalf("listed %d sessions, want %d", len(all), len(sessions))
+	}
+
+	var paged []models.GameSessionRecord
+	var after *models.SessionCursor
+	for range len(sessions) {
+		page, err := store.ListGameSessions(ctx, userID, "", after, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paged = append(paged, page...)
+		if len(page) < 2 {
+			break
+		}
+		last := page[len(page)-1]
+		after = &models.SessionCursor{PlayedAt: last.PlayedAt, ID: last.ID}
+	}
+	if len(paged) != len(all) {
+		t.Fatalf("pages returned %d sessions, want %d", len(paged), len(all))
+	}
+	for i := range all {
+		if paged[i].ID != all[i].ID {
+			t.Fatalf("session %d: paged ID %d, want %d", i, paged[i].ID, all[i].ID)
+		}
+		if i > 0 && (all[i].PlayedAt.After(all[i-1].PlayedAt) ||
+			all[i].PlayedAt.Equal(all[i-1].PlayedAt) && all[i].ID > all[i-1].ID) {
+			t.Errorf("session %d is not older than the one before", i)
+		}
+	}
+}
+
+func TestImportDeduplicates(t *testing.T) {
+	ctx := context.Background()
+	store, _ := open(t)
+	alice, bob := createUser(t, store, "alice"), createUser(t, store, "bob")
+
+	at := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	session := models.ImportedSession{
+		SaveSessionRequest: models.SaveSessionRequest{Mode: "addition", Difficulty: 1, TimeLimit: 60, Score: 100, Correct: 10, Total: 11},
+		PlayedAt:           at,
+	}
+	other := session
+	other.Score = 110
+
+	ids, err := store.ImportGameSessions(ctx, alice, []models.ImportedSession{session, session, other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids[0] == 0 || ids[1] != 0 || ids[2] == 0 {
+		t.Errorf("first import IDs = %v, want the repeated row skipped", ids)
+	}
+
+	ids, err = store.ImportGameSessions(ctx, alice, []models.ImportedSession{session, other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids[0] != 0 || ids[1] != 0 {
+		t.Errorf("reimport IDs = %v, want both skipped", ids)
+	}
+
+	// Another user's identical session is not a duplicate
+	ids, err = store.ImportGameSessions(ctx, bob, []models.ImportedSession{session})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids[0] == 0 {
+		t.Error("another user's session treated as a duplicate")
+	}
+
+	stats, err := store.GetUserStats(ctx, alice, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats[0].GamesPlayed != 2 {
+		t.Errorf("alice's stats = %+v, want 2 games", stats)
+	}
+}
+
+func TestOneImportJobAtATime(t *testing.T) {
+	ctx := context.Background()
+	store, _ := open(t)
+	alice, bob := createUser(t, store, "alice"), createUser(t, store, "bob")
+
+	job := &models.ImportJob{Status: models.ImportPending, Format: "csv", TotalRows: 1, Rows: []models.ImportRowResult{}}
+	if err := store.CreateImportJob(ctx, alice, job); err != nil {
+		t.Fatal(err)
+	}
+	second := &models.ImportJob{Status: models.ImportPending, Format: "csv", TotalRows: 1, Rows: []models.ImportRowResult{}}
+	if err := store.CreateImportJob(ctx, alice, second); !errors.Is(err, database.ErrImportActive) {
+		t.Errorf("second job while pending: err = %v, want ErrImportActive", err)
+	}
+	if err := store.CreateImportJob(ctx, bob, second); err != nil {
+		t.Errorf("another user's job refused: %v", err)
+	}
+
+	now := time.Now()
+	job.Status, job.FinishedAt = models.ImportCompleted, &now
+	if err := store.UpdateImportJob(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	third := &models.ImportJob{Status: models.ImportPending, Format: "csv", TotalRows: 1, Rows: []models.ImportRowResult{}}
+	if err := store.CreateImportJob(ctx, alice, third); err != nil {
+		t.Errorf("job after the previous one finished refused: %v", err)
+	}
+}
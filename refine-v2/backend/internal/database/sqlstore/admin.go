@@ -0,0 +1,215 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"refine-v2/backend/internal/models"
+	"strings"
+)
+
+func (s *Store) SearchUsers(ctx context.Context, query string, limit, offset int) ([]models.AdminUser, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	pattern := "%" + escapeLike(strings.ToLower(query)) + "%"
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT u.id, u.email, u.username, u.role, u.banned_at, COALESCE(u.ban_reason, ''), u.created_at,
+			(SELECT COUNT(*) FROM game_sessions gs WHERE gs.user_id = u.id)
+		 FROM users u
+		 WHERE LOWER(u.email) LIKE $1 ESCAPE '\' OR LOWER(u.username) LIKE $1 ESCAPE '\'
+		 ORDER BY u.id
+		 LIMIT $2 OFFSET $3`,
+		pattern, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.AdminUser
+	for rows.Next() {
+		var u models.AdminUser
+		var bannedAt sql.NullTime
+		if err := rows.Scan(&u.ID, &u.Email, &u.Username, &u.Role, &bannedAt, &u.BanReason, &u.CreatedAt, &u.GamesPlayed); err != nil {
+			return nil, err
+		}
+		if bannedAt.Valid {
+			u.BannedAt = &bannedAt.Time
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (s *Store) GetUserSessions(ctx context.Context, userID int64, limit, offset int) ([]models.AdminGameSession, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, mode, difficulty, score, correct, total, time_limit, created_at, leaderboard_hidden
+		 FROM game_sessions
+		 WHERE user_id = $1
+		 ORDER BY created_at DESC
+		 LIMIT $2 OFFSET $3`,
+		userID, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []models.AdminGameSession
+	for rows.Next() {
+		var g models.AdminGameSession
+		if err := rows.Scan(&g.ID, &g.Mode, &g.Difficulty, &g.Score, &g.Correct, &g.Total, &g.TimeLimit, &g.PlayedAt, &g.LeaderboardHidden); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, g)
+	}
+	return sessions, rows.Err()
+}
+
+// SetUserBanned bans or unbans a user. It reports false if the user does not exist.
+func (s *Store) SetUserBanned(ctx context.Context, userID int64, banned bool, reason string, audit models.AuditEntry) (bool, error) {
+	return s.withAudit(ctx, audit, func(ctx context.Context, tx *hookedTx) (sql.Result, error) {
+		var res sql.Result
+		var err error
+		if banned {
+			res, err = tx.ExecContext(ctx,
+				`UPDATE users SET banned_at = $1, ban_reason = $2 WHERE id = $3`, s.now(), reason, userID)
+		} else {
+			res, err = tx.ExecContext(ctx,
+				`UPDATE users SET banned_at = NULL, ban_reason = NULL WHERE id = $1`, userID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return res, s.refreshSessionBoards(ctx, tx, `user_id = $1`, userID)
+	})
+}
+
+// AdminUpdateUsername renames a user. It reports false if the user does not exist.
+func (s *Store) AdminUpdateUsername(ctx context.Context, userID int64, username string, audit models.AuditEntry) (bool, error) {
+	return s.withAudit(ctx, audit, func(ctx context.Context, tx *hookedTx) (sql.Result, error) {
+		return tx.ExecContext(ctx,
+			`UPDATE users SET username = $1 WHERE id = $2`, username, userID)
+	})
+}
+
+// SetSessionHidden removes a session from (or restores it to) the global
+// leaderboard. It reports false if the session does not exist.
+func (s *Store) SetSessionHidden(ctx context.Context, sessionID int64, hidden bool, audit models.AuditEntry) (bool, error) {
+	return s.withAudit(ctx, audit, func(ctx context.Context, tx *hookedTx) (sql.Result, error) {
+		res, err := tx.ExecContext(ctx,
+			`UPDATE game_sessions SET leaderboard_hidden = $1 WHERE id = $2`, hidden, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		return res, s.refreshSessionBoards(ctx, tx, `id = $1`, sessionID)
+	})
+}
+
+func (s *Store) ListEmailSignups(ctx context.Context) ([]models.EmailSignup, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT email, created_at FROM email_signups ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var signups []models.EmailSignup
+	for rows.Next() {
+		var e models.EmailSignup
+		if err := rows.Scan(&e.Email, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		signups = append(signups, e)
+	}
+	return signups, rows.Err()
+}
+
+// --- Audit log ---
+
+// WriteAudit records a read-only admin action.
+func (s *Store) WriteAudit(ctx context.Context, audit models.AuditEntry) error {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	return insertAudit(ctx, s.DB, audit)
+}
+
+func (s *Store) ListAuditLog(ctx context.Context, limit, offset int) ([]models.AuditEntry, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, admin_id, action, target_type, target_id, details, created_at
+		 FROM admin_audit_log
+		 ORDER BY id DESC
+		 LIMIT $1 OFFSET $2`,
+		limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		var e models.AuditEntry
+		var targetID sql.NullInt64
+		if err := rows.Scan(&e.ID, &e.AdminID, &e.Action, &e.TargetType, &targetID, &e.Details, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if targetID.Valid {
+			e.TargetID = &targetID.Int64
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func insertAudit(ctx context.Context, db execer, audit models.AuditEntry) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO admin_audit_log (admin_id, action, target_type, target_id, details)
+		 VALUES ($1, $2, $3, $4, $5)`,
+		audit.AdminID, audit.Action, audit.TargetType, audit.TargetID, audit.Details)
+	return err
+}
+
+// withAudit runs fn and records audit in one transaction. The audit entry is
+// only written, and the transaction only committed, if fn affected a row.
+func (s *Store) withAudit(ctx context.Context, audit models.AuditEntry, fn func(context.Context, *hookedTx) (sql.Result, error)) (bool, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := fn(ctx, tx)
+	if err != nil {
+		return false, s.mapError(err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	if err := insertAudit(ctx, tx, audit); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,408 @@
+package sqlstore
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"refine-v2/backend/internal/database"
+	"slices"
+	"sort"
+	"time"
+)
+
+// Stats and leaderboards are served from aggregate tables that are kept up to
+// date in the same transaction as the change that affects them:
+//
+//   - user_stats: per user/mode/difficulty/time limit counts, sums and bests
+//   - personal_records: each user's top sessions per game settings
+//   - leaderboard_tops: the top visible sessions per period and game settings;
+//     hidden, imported, practice and banned users' sessions are left out
+//
+// RebuildAggregates recomputes all three from game_sessions.
+//
+// Each top list is trimmed by a DELETE after the INSERT, which only sees
+// committed rows and the transaction's own. Concurrent saves to the same list
+// would each keep their own row, so every list is locked by name first.
+
+// leaderboardSize is how many sessions each leaderboard keeps.
+const leaderboardSize = 5
+
+const periodStartLayout = "2006-01-02"
+
+// boardKey identifies one leaderboard in leaderboard_tops. start is the
+// period's UTC start date, or "" for all-time.
+type boardKey struct {
+	period     string
+	start      string
+	mode       string
+	difficulty int
+	timeLimit  int
+}
+
+// boardKeys returns the leaderboards a session played at playedAt counts towards.
+func boardKeys(mode string, difficulty, timeLimit int, playedAt time.Time) []boardKey {
+	keys := make([]boardKey, len(database.LeaderboardPeriods))
+	for i, period := range database.LeaderboardPeriods {
+		keys[i] = boardKey{
+			period:     period,
+			start:      periodStartKey(database.PeriodStart(period, playedAt)),
+			mode:       mode,
+			difficulty: difficulty,
+			timeLimit:  timeLimit,
+		}
+	}
+	return keys
+}
+
+func (k boardKey) lockName() string {
+	return fmt.Sprintf("leaderboard_tops:%s:%s:%s:%d:%d", k.period, k.start, k.mode, k.difficulty, k.timeLimit)
+}
+
+// lock takes the transaction-scoped locks on names, in sorted order so that
+// transactions locking overlapping sets cannot deadlock.
+func (s *Store) lock(ctx context.Context, tx *hookedTx, names ...string) error {
+	stmt := s.dialect.LockStatement()
+	if stmt == "" {
+		return nil
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if _, err := tx.ExecContext(ctx, stmt, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockBoards locks the leaderboards in keys.
+func (s *Store) lockBoards(ctx context.Context, tx *hookedTx, keys []boardKey) error {
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = k.lockName()
+	}
+	return s.lock(ctx, tx, names...)
+}
+
+func periodStartKey(start time.Time) string {
+	if start.IsZero() {
+		return ""
+	}
+	return start.Format(periodStartLayout)
+}
+
+// gameSession is the subset of a game_sessions row the aggregates depend on.
+type gameSession struct {
+	id         int64
+	userID     int64
+	mode       string
+	difficulty int
+	timeLimit  int
+	score      int
+	correct    int
+	total      int
+	playedAt   time.Time
+	imported   bool
+	practice   bool
+}
+
+// accuracy returns the session's accuracy percentage and whether it counts
+// towards the average. Sessions with no questions are left out, matching AVG
+// over NULLIF(total, 0).
+func (g gameSession) accuracy() (float64, int) {
+	if g.total <= 0 {
+		return 0, 0
+	}
+	return float64(g.correct) * 100 / float64(g.total), 1
+}
+
+// addSessionAggregates folds a newly saved session into the aggregates.
+func (s *Store) addSessionAggregates(ctx context.Context, tx *hookedTx, g gameSession) error {
+	acc, accGames := g.accuracy()
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO user_stats (user_id, mode, difficulty, time_limit,
+			games_played, best_score, score_sum, accuracy_sum, accuracy_games)
+		 VALUES ($1, $2, $3, $4, 1, $5, $6, $7, $8)
+		 ON CONFLICT (user_id, mode, difficulty, time_limit) DO UPDATE SET
+			games_played = user_stats.games_played + 1,
+			best_score = CASE WHEN excluded.best_score > user_stats.best_score
+				THEN excluded.best_score ELSE user_stats.best_score END,
+			score_sum = user_stats.score_sum + excluded.score_sum,
+			accuracy_sum = user_stats.accuracy_sum + excluded.accuracy_sum,
+			accuracy_games = user_stats.accuracy_games + excluded.accuracy_games`,
+		g.userID, g.mode, g.difficulty, g.timeLimit, g.score, g.score, acc, accGames); err != nil {
+		return err
+	}
+
+	if err := s.lock(ctx, tx, fmt.Sprintf("personal_records:%d:%s:%d:%d", g.userID, g.mode, g.difficulty, g.timeLimit)); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO personal_records (user_id, mode, difficulty, time_limit, session_id, score)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		g.userID, g.mode, g.difficulty, g.timeLimit, g.id, g.score); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM personal_records
+		 WHERE user_id = $1 AND mode = $2 AND difficulty = $3 AND time_limit = $4
+		   AND session_id NOT IN (
+			SELECT session_id FROM personal_records
+			WHERE user_id = $1 AND mode = $2 AND difficulty = $3 AND time_limit = $4
+			ORDER BY score DESC, session_id
+			LIMIT $5)`,
+		g.userID, g.mode, g.difficulty, g.timeLimit, leaderboardSize); err != nil {
+		return err
+	}
+
+	// New sessions are visible unless imported or practice: banned users are
+	// rejected before they can save, and sessions are only hidden after the
+	// fact.
+	if g.imported || g.practice {
+		return nil
+	}
+	keys := boardKeys(g.mode, g.difficulty, g.timeLimit, g.playedAt)
+	if err := s.lockBoards(ctx, tx, keys); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO leaderboard_tops (period, period_start, mode, difficulty, time_limit, session_id, score)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			k.period, k.start, k.mode, k.difficulty, k.timeLimit, g.id, g.score); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM leaderboard_tops
+			 WHERE period = $1 AND period_start = $2 AND mode = $3 AND difficulty = $4 AND time_limit = $5
+			   AND session_id NOT IN (
+				SELECT session_id FROM leaderboard_tops
+				WHERE period = $1 AND period_start = $2 AND mode = $3 AND difficulty = $4 AND time_limit = $5
+				ORDER BY score DESC, session_id
+				LIMIT $6)`,
+			k.period, k.start, k.mode, k.difficulty, k.timeLimit, leaderboardSize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshBoards recomputes the given leaderboards from game_sessions. It is
+// used when sessions leave or rejoin a board (hidden, restored, banned,
+// deleted), where the next-best session has to be found.
+func (s *Store) refreshBoards(ctx context.Context, tx *hookedTx, keys map[boardKey]bool) error {
+	if err := s.lockBoards(ctx, tx, slices.Collect(maps.Keys(keys))); err != nil {
+		return err
+	}
+	for k := range keys {
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM leaderboard_tops
+			 WHERE period = $1 AND period_start = $2 AND mode = $3 AND difficulty = $4 AND time_limit = $5`,
+			k.period, k.start, k.mode, k.difficulty, k.timeLimit); err != nil {
+			return err
+		}
+
+		query := `INSERT INTO leaderboard_tops (period, period_start, mode, difficulty, time_limit, session_id, score)
+			SELECT $1, $2, gs.mode, gs.difficulty, gs.time_limit, gs.id, gs.score
+			FROM game_sessions gs
+			JOIN users u ON u.id = gs.user_id
+			WHERE gs.mode = $3 AND gs.difficulty = $4 AND gs.time_limit = $5
+			  AND NOT gs.leaderboard_hidden AND NOT gs.imported AND NOT gs.practice AND u.banned_at IS NULL`
+		args := []any{k.period, k.start, k.mode, k.difficulty, k.timeLimit, leaderboardSize}
+		if k.start != "" {
+			start, err := time.Parse(periodStartLayout, k.start)
+			if err != nil {
+				return err
+			}
+			query += ` AND gs.created_at >= $7 AND gs.created_at < $8`
+			args = append(args, s.dialect.Time(start), s.dialect.Time(database.PeriodEnd(k.period, start)))
+		}
+		query += ` ORDER BY gs.score DESC, gs.id LIMIT $6`
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshSessionBoards refreshes every leaderboard the game_sessions matching
+// where could appear on.
+func (s *Store) refreshSessionBoards(ctx context.Context, tx *hookedTx, where string, arg any) error {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT mode, difficulty, time_limit, created_at FROM game_sessions WHERE `+where, arg)
+	if err != nil {
+		return err
+	}
+
+	keys := map[boardKey]bool{}
+	for rows.Next() {
+		var mode string
+		var difficulty, timeLimit int
+		var playedAt time.Time
+		if err := rows.Scan(&mode, &difficulty, &timeLimit, &playedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		for _, k := range boardKeys(mode, difficulty, timeLimit, playedAt) {
+			keys[k] = true
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return s.refreshBoards(ctx, tx, keys)
+}
+
+// userBoards returns the leaderboards the user's sessions are currently on.
+func (s *Store) userBoards(ctx context.Context, tx *hookedTx, userID int64) (map[boardKey]bool, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT t.period, t.period_start, t.mode, t.difficulty, t.time_limit
+		 FROM leaderboard_tops t
+		 JOIN game_sessions gs ON gs.id = t.session_id
+		 WHERE gs.user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := map[boardKey]bool{}
+	for rows.Next() {
+		var k boardKey
+		if err := rows.Scan(&k.period, &k.start, &k.mode, &k.difficulty, &k.timeLimit); err != nil {
+			return nil, err
+		}
+		keys[k] = true
+	}
+	return keys, rows.Err()
+}
+
+// RebuildAggregates recomputes user_stats, personal_records and
+// leaderboard_tops from game_sessions in a single transaction. It walks the
+// whole table, so it is not bounded by the per-query timeout.
+func (s *Store) RebuildAggregates(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	type statsKey struct {
+		userID     int64
+		mode       string
+		difficulty int
+		timeLimit  int
+	}
+	type statsAgg struct {
+		games, best, scoreSum, accGames int
+		accSum                          float64
+	}
+
+	stats := map[statsKey]*statsAgg{}
+	personal := map[statsKey][]topEntry{}
+	boards := map[boardKey][]topEntry{}
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT gs.id, gs.user_id, gs.mode, gs.difficulty, gs.time_limit,
+			gs.score, gs.correct, gs.total, gs.created_at,
+			NOT gs.leaderboard_hidden AND NOT gs.imported AND NOT gs.practice AND u.banned_at IS NULL
+		 FROM game_sessions gs
+		 JOIN users u ON u.id = gs.user_id`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var g gameSession
+		var visible bool
+		if err := rows.Scan(&g.id, &g.userID, &g.mode, &g.difficulty, &g.timeLimit,
+			&g.score, &g.correct, &g.total, &g.playedAt, &visible); err != nil {
+			rows.Close()
+			return err
+		}
+
+		sk := statsKey{g.userID, g.mode, g.difficulty, g.timeLimit}
+		agg := stats[sk]
+		if agg == nil {
+			agg = &statsAgg{}
+			stats[sk] = agg
+		}
+		acc, accGames := g.accuracy()
+		agg.games++
+		agg.best = max(agg.best, g.score)
+		agg.scoreSum += g.score
+		agg.accSum += acc
+		agg.accGames += accGames
+
+		entry := topEntry{sessionID: g.id, score: g.score}
+		personal[sk] = addTop(personal[sk], entry)
+		if visible {
+			for _, k := range boardKeys(g.mode, g.difficulty, g.timeLimit, g.playedAt) {
+				boards[k] = addTop(boards[k], entry)
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, table := range []string{"user_stats", "personal_records", "leaderboard_tops"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table); err != nil {
+			return err
+		}
+	}
+
+	for k, agg := range stats {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO user_stats (user_id, mode, difficulty, time_limit,
+				games_played, best_score, score_sum, accuracy_sum, accuracy_games)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			k.userID, k.mode, k.difficulty, k.timeLimit,
+			agg.games, agg.best, agg.scoreSum, agg.accSum, agg.accGames); err != nil {
+			return err
+		}
+	}
+	for k, top := range personal {
+		for _, e := range top {
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO personal_records (user_id, mode, difficulty, time_limit, session_id, score)
+				 VALUES ($1, $2, $3, $4, $5, $6)`,
+				k.userID, k.mode, k.difficulty, k.timeLimit, e.sessionID, e.score); err != nil {
+				return err
+			}
+		}
+	}
+	for k, top := range boards {
+		for _, e := range top {
+			if _, err := tx.ExecContext(ctx,
+				`INSERT INTO leaderboard_tops (period, period_start, mode, difficulty, time_limit, session_id, score)
+				 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+				k.period, k.start, k.mode, k.difficulty, k.timeLimit, e.sessionID, e.score); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+type topEntry struct {
+	sessionID int64
+	score     int
+}
+
+// addTop inserts e into a leaderboard ordered by score (ties to the earlier
+// session) and trims it to leaderboardSize.
+func addTop(top []topEntry, e topEntry) []topEntry {
+	top = append(top, e)
+	sort.SliceStable(top, func(i, j int) bool {
+		if top[i].score != top[j].score {
+			return top[i].score > top[j].score
+		}
+		return top[i].sessionID < top[j].sessionID
+	})
+	if len(top) > leaderboardSize {
+		top = top[:leaderboardSize]
+	}
+	return top
+}
@@ -0,0 +1,100 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// QueryHook observes every statement the store runs. The context is the one
+// the handler passed in, so request-scoped values such as the request ID are
+// visible to the hook.
+type QueryHook interface {
+	// BeforeQuery is called before the statement runs. The returned context
+	// is used for the statement and passed to AfterQuery.
+	BeforeQuery(ctx context.Context, query string) context.Context
+	AfterQuery(ctx context.Context, query string, elapsed time.Duration, err error)
+}
+
+// hookedDB wraps *sql.DB, and hookedTx wraps *sql.Tx, so that every
+// statement goes through the configured hooks.
+type hookedDB struct {
+	db    *sql.DB
+	hooks []QueryHook
+}
+
+type hookedTx struct {
+	tx    *sql.Tx
+	hooks []QueryHook
+}
+
+func observe(ctx context.Context, hooks []QueryHook, query string) (context.Context, func(error)) {
+	if len(hooks) == 0 {
+		return ctx, func(error) {}
+	}
+	for _, h := range hooks {
+		ctx = h.BeforeQuery(ctx, query)
+	}
+	start := time.Now()
+	return ctx, func(err error) {
+		elapsed := time.Since(start)
+		for _, h := range hooks {
+			h.AfterQuery(ctx, query, elapsed, err)
+		}
+	}
+}
+
+func (c *hookedDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, done := observe(ctx, c.hooks, query)
+	res, err := c.db.ExecContext(ctx, query, args...)
+	done(err)
+	return res, err
+}
+
+func (c *hookedDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, done := observe(ctx, c.hooks, query)
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	done(err)
+	return rows, err
+}
+
+// QueryRowContext reports the query error, if any, to the hooks. sql.ErrNoRows
+// only surfaces at Scan and is not treated as a failure.
+func (c *hookedDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, done := observe(ctx, c.hooks, query)
+	row := c.db.QueryRowContext(ctx, query, args...)
+	done(row.Err())
+	return row
+}
+
+func (c *hookedDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*hookedTx, error) {
+	t, err := c.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &hookedTx{tx: t, hooks: c.hooks}, nil
+}
+
+func (t *hookedTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, done := observe(ctx, t.hooks, query)
+	res, err := t.tx.ExecContext(ctx, query, args...)
+	done(err)
+	return res, err
+}
+
+func (t *hookedTx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, done := observe(ctx, t.hooks, query)
+	rows, err := t.tx.QueryContext(ctx, query, args...)
+	done(err)
+	return rows, err
+}
+
+func (t *hookedTx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, done := observe(ctx, t.hooks, query)
+	row := t.tx.QueryRowContext(ctx, query, args...)
+	done(row.Err())
+	return row
+}
+
+func (t *hookedTx) Commit() error   { return t.tx.Commit() }
+func (t *hookedTx) Rollback() error { return t.tx.Rollback() }
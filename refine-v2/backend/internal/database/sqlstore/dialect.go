@@ -0,0 +1,47 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+	"refine-v2/backend/internal/database"
+	"strings"
+	"time"
+)
+
+// Dialect covers the behaviour that differs between SQL backends.
+type Dialect interface {
+	// Time converts a timestamp argument into the form the backend stores,
+	// so that comparisons against stored columns are correct.
+	Time(t time.Time) any
+
+	// UniqueViolation reports whether err is a unique constraint violation
+	// and, if so, the name of the column (or constraint) that conflicted.
+	UniqueViolation(err error) (string, bool)
+
+	// LockStatement returns a statement that takes a lock, held until the
+	// transaction ends, on the name given as its one argument. It is "" for
+	// backends whose transactions are already serialised.
+	LockStatement() string
+}
+
+// mapError translates driver errors into database domain errors.
+func (s *Store) mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.ErrNotFound
+	}
+	if column, ok := s.dialect.UniqueViolation(err); ok {
+		column = strings.ToLower(column)
+		switch {
+		case strings.Contains(column, "email"):
+			return database.ErrEmailTaken
+		case strings.Contains(column, "username"):
+			return database.ErrUsernameTaken
+		default:
+			return database.ErrConflict
+		}
+	}
+	return err
+}
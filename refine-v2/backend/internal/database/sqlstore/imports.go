@@ -0,0 +1,157 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"refine-v2/backend/internal/database"
+	"refine-v2/backend/internal/models"
+	"time"
+)
+
+func (s *Store) CreateImportJob(ctx context.Context, userID int64, job *models.ImportJob) error {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	results, err := json.Marshal(job.Rows)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Serialise a user's concurrent requests so only one of them starts a job
+	if err := s.lock(ctx, tx, fmt.Sprintf("import_jobs:%d", userID)); err != nil {
+		return err
+	}
+	now := time.Now()
+	var active bool
+	if err := tx.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM import_jobs WHERE user_id = $1 AND status IN ($2, $3) AND created_at > $4)`,
+		userID, models.ImportPending, models.ImportRunning, s.dialect.Time(now.Add(-models.ImportStaleAfter)),
+	).Scan(&active); err != nil {
+		return err
+	}
+	if active {
+		return database.ErrImportActive
+	}
+
+	job.CreatedAt = now
+	if err := tx.QueryRowContext(ctx,
+		`INSERT INTO import_jobs (user_id, status, format, total_rows, processed, imported, duplicates, invalid, results, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		 RETURNING id`,
+		userID, job.Status, job.Format, job.TotalRows, job.Processed, job.Imported, job.Duplicates, job.Invalid,
+		string(results), s.dialect.Time(job.CreatedAt),
+	).Scan(&job.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Store) UpdateImportJob(ctx context.Context, job *models.ImportJob) error {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	results, err := json.Marshal(job.Rows)
+	if err != nil {
+		return err
+	}
+	var finishedAt any
+	if job.FinishedAt != nil {
+		finishedAt = s.dialect.Time(*job.FinishedAt)
+	}
+	_, err = s.db.ExecContext(ctx,
+		`UPDATE import_jobs SET status = $1, processed = $2, imported = $3, duplicates = $4, invalid = $5,
+			error = $6, results = $7, finished_at = $8
+		 WHERE id = $9`,
+		job.Status, job.Processed, job.Imported, job.Duplicates, job.Invalid,
+		job.Error, string(results), finishedAt, job.ID)
+	return err
+}
+
+func (s *Store) GetImportJob(ctx context.Context, userID, jobID int64) (*models.ImportJob, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	var job models.ImportJob
+	var results string
+	var finishedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, status, format, total_rows, processed, imported, duplicates, invalid, error, results, created_at, finished_at
+		 FROM import_jobs WHERE id = $1 AND user_id = $2`,
+		jobID, userID,
+	).Scan(&job.ID, &job.Status, &job.Format, &job.TotalRows, &job.Processed, &job.Imported, &job.Duplicates,
+		&job.Invalid, &job.Error, &results, &job.CreatedAt, &finishedAt)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	if err := json.Unmarshal([]byte(results), &job.Rows); err != nil {
+		return nil, fmt.Errorf("decode import results: %w", err)
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	return &job, nil
+}
+
+// ImportGameSessions saves the sessions in one transaction, so duplicates
+// within the batch are caught as well as ones already stored.
+func (s *Store) ImportGameSessions(ctx context.Context, userID int64, sessions []models.ImportedSession) ([]int64, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ids := make([]int64, len(sessions))
+	for i, req := range sessions {
+		playedAt := s.dialect.Time(req.PlayedAt)
+		var exists int
+		err := tx.QueryRowContext(ctx,
+			`SELECT 1 FROM game_sessions
+			 WHERE user_id = $1 AND created_at = $2 AND mode = $3 AND difficulty = $4
+			   AND time_limit = $5 AND score = $6 AND correct = $7 AND total = $8`,
+			userID, playedAt, req.Mode, req.Difficulty, req.TimeLimit, req.Score, req.Correct, req.Total,
+		).Scan(&exists)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+
+		g := gameSession{
+			userID:     userID,
+			mode:       req.Mode,
+			difficulty: req.Difficulty,
+			timeLimit:  req.TimeLimit,
+			score:      req.Score,
+			correct:    req.Correct,
+			total:      req.Total,
+			playedAt:   req.PlayedAt,
+			imported:   true,
+		}
+		if err := tx.QueryRowContext(ctx,
+			`INSERT INTO game_sessions (user_id, mode, difficulty, score, correct, total, time_limit, created_at, imported)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			 RETURNING id`,
+			userID, req.Mode, req.Difficulty, req.Score, req.Correct, req.Total, req.TimeLimit, playedAt, true,
+		).Scan(&g.id); err != nil {
+			return nil, err
+		}
+		if err := s.addSessionAggregates(ctx, tx, g); err != nil {
+			return nil, err
+		}
+		ids[i] = g.id
+	}
+	return ids, tx.Commit()
+}
@@ -0,0 +1,800 @@
+// Package sqlstore implements database.Store over database/sql. The SQL is
+// kept to the subset shared by Postgres and SQLite; the few differences are
+// behind Dialect.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"refine-v2/backend/internal/database"
+	"refine-v2/backend/internal/models"
+	"strings"
+	"time"
+)
+
+type Store struct {
+	DB      *sql.DB
+	db      *hookedDB
+	dialect Dialect
+	timeout time.Duration
+}
+
+var (
+	_ database.Store              = (*Store)(nil)
+	_ database.AggregateRebuilder = (*Store)(nil)
+)
+
+// DefaultQueryTimeout bounds each store call when Options.QueryTimeout is zero.
+const DefaultQueryTimeout = 5 * time.Second
+
+// Options configures a Store.
+type Options struct {
+	// QueryTimeout bounds each store call on top of any deadline already on
+	// the caller's context. Negative disables the per-call deadline.
+	QueryTimeout time.Duration
+	// Hooks observe every statement, in order.
+	Hooks []QueryHook
+}
+
+func New(db *sql.DB, dialect Dialect, opts Options) *Store {
+	timeout := opts.QueryTimeout
+	if timeout == 0 {
+		timeout = DefaultQueryTimeout
+	}
+	return &Store{
+		DB:      db,
+		db:      &hookedDB{db: db, hooks: opts.Hooks},
+		dialect: dialect,
+		timeout: timeout,
+	}
+}
+
+func (s *Store) Ping(ctx context.Context) error {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+	return s.DB.PingContext(ctx)
+}
+
+func (s *Store) Close() error {
+	return s.DB.Close()
+}
+
+// ctx derives the context for one store call from the caller's, so a client
+// disconnect or request timeout cancels the query.
+func (s *Store) ctx(parent context.Context) (context.Context, context.CancelFunc) {
+	if s.timeout < 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, s.timeout)
+}
+
+// now returns the current time as a query argument. Timestamps are always
+// passed in from Go rather than using SQL now() so comparisons behave the
+// same on every dialect.
+func (s *Store) now() any {
+	return s.dialect.Time(time.Now())
+}
+
+// --- Users ---
+
+func (s *Store) CreateUser(ctx context.Context, email, username, passwordHash string) (*models.User, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	var user models.User
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO users (email, username, password_hash)
+		 VALUES ($1, $2, $3)
+		 RETURNING id, email, username, created_at`,
+		email, username, passwordHash,
+	).Scan(&user.ID, &user.Email, &user.Username, &user.CreatedAt)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	return &user, nil
+}
+
+func (s *Store) GetUserByEmail(ctx context.Context, email string) (*models.User, string, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	var user models.User
+	var passwordHash string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, email, username, password_hash, totp_enabled, created_at
+		 FROM users WHERE email = $1`,
+		email,
+	).Scan(&user.ID, &user.Email, &user.Username, &passwordHash, &user.TwoFactorEnabled, &user.CreatedAt)
+	if err != nil {
+		return nil, "", s.mapError(err)
+	}
+	return &user, passwordHash, nil
+}
+
+func (s *Store) GetUserByID(ctx context.Context, id int64) (*models.User, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	var user models.User
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, email, username, totp_enabled, created_at
+		 FROM users WHERE id = $1`,
+		id,
+	).Scan(&user.ID, &user.Email, &user.Username, &user.TwoFactorEnabled, &user.CreatedAt)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	return &user, nil
+}
+
+// GetUserAccess returns the user's role and whether they are banned.
+func (s *Store) GetUserAccess(ctx context.Context, userID int64) (string, bool, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	var role string
+	var banned bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT role, banned_at IS NOT NULL FROM users WHERE id = $1`, userID,
+	).Scan(&role, &banned)
+	return role, banned, s.mapError(err)
+}
+
+func (s *Store) GetPasswordHash(ctx context.Context, userID int64) (string, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	var hash string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT password_hash FROM users WHERE id = $1`, userID,
+	).Scan(&hash)
+	return hash, s.mapError(err)
+}
+
+func (s *Store) UpdatePassword(ctx context.Context, userID int64, newHash string) error {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE users SET password_hash = $1 WHERE id = $2`, newHash, userID)
+	return err
+}
+
+func (s *Store) UpdateUsername(ctx context.Context, userID int64, newUsername string) error {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE users SET username = $1 WHERE id = $2`, newUsername, userID)
+	return s.mapError(err)
+}
+
+func (s *Store) DeleteUser(ctx context.Context, userID int64) error {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Boards the user is on are refilled from other users' sessions afterwards
+	boards, err := s.userBoards(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+
+	// Delete dependent rows first (FK constraints)
+	for _, q := range []string{
+		`DELETE FROM leaderboard_tops WHERE session_id IN (SELECT id FROM game_sessions WHERE user_id = $1)`,
+		`DELETE FROM personal_records WHERE user_id = $1`,
+		`DELETE FROM user_stats WHERE user_id = $1`,
+		`DELETE FROM game_sessions WHERE user_id = $1`,
+		`DELETE FROM recovery_codes WHERE user_id = $1`,
+		`DELETE FROM import_jobs WHERE user_id = $1`,
+		`DELETE FROM api_tokens WHERE user_id = $1`,
+		`DELETE FROM users WHERE id = $1`,
+	} {
+		if _, err := tx.ExecContext(ctx, q, userID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.refreshBoards(ctx, tx, boards); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// --- Two-factor auth ---
+
+// GetTOTP returns the user's TOTP secret (empty if none) and whether 2FA is enabled.
+func (s *Store) GetTOTP(ctx context.Context, userID int64) (string, bool, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	var secret sql.NullString
+	var enabled bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT totp_secret, totp_enabled FROM users WHERE id = $1`, userID,
+	).Scan(&secret, &enabled)
+	return secret.String, enabled, s.mapError(err)
+}
+
+// SetPendingTOTP stores a secret that is not yet active until EnableTOTP.
+func (s *Store) SetPendingTOTP(ctx context.Context, userID int64, secret string) error {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE users SET totp_secret = $1, totp_enabled = false WHERE id = $2`, secret, userID)
+	return err
+}
+
+// EnableTOTP activates 2FA and replaces any existing recovery codes.
+func (s *Store) EnableTOTP(ctx context.Context, userID int64, recoveryHashes []string) error {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE users SET totp_enabled = true WHERE id = $1`, userID); err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DisableTOTP clears the secret and all recovery codes.
+func (s *Store) DisableTOTP(ctx context.Context, userID int64) error {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE users SET totp_secret = NULL, totp_enabled = false WHERE id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes invalidates all existing recovery codes for the user.
+func (s *Store) ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes []string) error {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, hashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *hookedTx, userID int64, hashes []string) error {
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, h := range hashes {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseTOTPStep records step as the last accepted TOTP step, unless one at or
+// after it was already accepted.
+func (s *Store) UseTOTPStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE users SET totp_last_step = $2
+		 WHERE id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2)`,
+		userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ConsumeRecoveryCode marks an unused recovery code as used. It reports
+// false if the code does not exist or was already used.
+func (s *Store) ConsumeRecoveryCode(ctx context.Context, userID int64, hash string) (bool, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE recovery_codes SET used_at = $3
+		 WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`,
+		userID, hash, s.now())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// --- Personal access tokens ---
+
+func (s *Store) CreateAPIToken(ctx context.Context, userID int64, name, hash, prefix string, scopes []string, expiresAt time.Time) (*models.APIToken, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	t := models.APIToken{Name: name, Prefix: prefix, Scopes: scopes, ExpiresAt: expiresAt}
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO api_tokens (user_id, name, token_hash, prefix, scopes, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 RETURNING id, created_at`,
+		userID, name, hash, prefix, strings.Join(scopes, ","), s.dialect.Time(expiresAt),
+	).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *Store) ListAPITokens(ctx context.Context, userID int64) ([]models.APIToken, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, name, prefix, scopes, expires_at, last_used_at, created_at
+		 FROM api_tokens
+		 WHERE user_id = $1
+		 ORDER BY created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []models.APIToken
+	for rows.Next() {
+		var t models.APIToken
+		var scopes string
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&t.ID, &t.Name, &t.Prefix, &scopes, &t.ExpiresAt, &lastUsed, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		t.Scopes = splitScopes(scopes)
+		if lastUsed.Valid {
+			t.LastUsedAt = &lastUsed.Time
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, rows.Err()
+}
+
+// DeleteAPIToken revokes a token. It reports false if the token does not
+// exist or belongs to another user.
+func (s *Store) DeleteAPIToken(ctx context.Context, userID, tokenID int64) (bool, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM api_tokens WHERE id = $1 AND user_id = $2`, tokenID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// AuthenticateAPIToken looks up an unexpired token by hash, records its use
+// and returns the owning user and the token's scopes.
+func (s *Store) AuthenticateAPIToken(ctx context.Context, hash string) (*models.User, []string, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	var user models.User
+	var tokenID int64
+	var scopes string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT t.id, t.scopes, u.id, u.email, u.username, u.totp_enabled, u.created_at
+		 FROM api_tokens t
+		 JOIN users u ON u.id = t.user_id
+		 WHERE t.token_hash = $1 AND t.expires_at > $2`,
+		hash, s.now(),
+	).Scan(&tokenID, &scopes, &user.ID, &user.Email, &user.Username, &user.TwoFactorEnabled, &user.CreatedAt)
+	if err != nil {
+		return nil, nil, s.mapError(err)
+	}
+
+	// Throttle last-used writes to once a minute per token
+	now := time.Now()
+	if _, err := s.db.ExecContext(ctx,
+		`UPDATE api_tokens SET last_used_at = $2
+		 WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $3)`,
+		tokenID, s.dialect.Time(now), s.dialect.Time(now.Add(-time.Minute))); err != nil {
+		return nil, nil, err
+	}
+
+	return &user, splitScopes(scopes), nil
+}
+
+func splitScopes(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
+
+// --- Email signups ---
+
+func (s *Store) InsertEmail(ctx context.Context, email string) error {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO email_signups (email) VALUES ($1)`, email)
+	return s.mapError(err)
+}
+
+// --- Game sessions ---
+
+func (s *Store) SaveGameSession(ctx context.Context, userID int64, req models.SaveSessionRequest) error {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	g := gameSession{
+		userID:     userID,
+		mode:       req.Mode,
+		difficulty: req.Difficulty,
+		timeLimit:  req.TimeLimit,
+		score:      req.Score,
+		correct:    req.Correct,
+		total:      req.Total,
+		playedAt:   time.Now(),
+		practice:   req.Practice,
+	}
+	seed, answers, err := encodeAnswers(req.Seed, req.Answers)
+	if err != nil {
+		return err
+	}
+	if seed.Valid {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO used_seeds (seed, used_at) VALUES ($1, $2)`,
+			seed, s.dialect.Time(g.playedAt)); err != nil {
+			if errors.Is(s.mapError(err), database.ErrConflict) {
+				return database.ErrSeedUsed
+			}
+			return err
+		}
+	}
+	if err := tx.QueryRowContext(ctx,
+		`INSERT INTO game_sessions (user_id, mode, difficulty, score, correct, total, time_limit, created_at, seed, answers, practice)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		 RETURNING id`,
+		userID, req.Mode, req.Difficulty, req.Score, req.Correct, req.Total, req.TimeLimit, s.dialect.Time(g.playedAt), seed, answers, req.Practice,
+	).Scan(&g.id); err != nil {
+		return err
+	}
+
+	if err := s.addSessionAggregates(ctx, tx, g); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// --- Leaderboard ---
+
+func (s *Store) GetGlobalLeaderboard(ctx context.Context, mode string, difficulty int, timeLimit int, period string) ([]models.LeaderboardEntry, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	start := periodStartKey(database.PeriodStart(period, time.Now()))
+	return s.readBoard(ctx,
+		`SELECT u.username, gs.score, gs.correct, gs.total, gs.time_limit, gs.created_at
+		 FROM leaderboard_tops t
+		 JOIN game_sessions gs ON gs.id = t.session_id
+		 JOIN users u ON u.id = gs.user_id
+		 WHERE t.period = $1 AND t.period_start = $2
+		   AND t.mode = $3 AND t.difficulty = $4 AND t.time_limit = $5
+		 ORDER BY t.score DESC, t.session_id
+		 LIMIT $6`,
+		true, period, start, mode, difficulty, timeLimit, leaderboardSize)
+}
+
+func (s *Store) GetPersonalLeaderboard(ctx context.Context, userID int64, mode string, difficulty int, timeLimit int) ([]models.LeaderboardEntry, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	return s.readBoard(ctx,
+		`SELECT gs.score, gs.correct, gs.total, gs.time_limit, gs.created_at
+		 FROM personal_records p
+		 JOIN game_sessions gs ON gs.id = p.session_id
+		 WHERE p.user_id = $1 AND p.mode = $2 AND p.difficulty = $3 AND p.time_limit = $4
+		 ORDER BY p.score DESC, p.session_id
+		 LIMIT $5`,
+		false, userID, mode, difficulty, timeLimit, leaderboardSize)
+}
+
+// readBoard scans leaderboard rows in rank order. Global boards select the
+// username as the first column.
+func (s *Store) readBoard(ctx context.Context, query string, withUsername bool, args ...any) ([]models.LeaderboardEntry, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.LeaderboardEntry
+	rank := 1
+	for rows.Next() {
+		var e models.LeaderboardEntry
+		dest := []any{&e.Score, &e.Correct, &e.Total, &e.TimeLimit, &e.PlayedAt}
+		if withUsername {
+			dest = append([]any{&e.Username}, dest...)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		e.Rank = rank
+		rank++
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// --- Stats ---
+
+func (s *Store) GetUserStats(ctx context.Context, userID int64, difficulty int) ([]models.ModeStat, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT mode,
+			SUM(games_played),
+			MAX(best_score),
+			SUM(score_sum),
+			SUM(accuracy_sum),
+			SUM(accuracy_games)
+		 FROM user_stats
+		 WHERE user_id = $1 AND difficulty = $2
+		 GROUP BY mode
+		 ORDER BY mode`,
+		userID, difficulty,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []models.ModeStat
+	for rows.Next() {
+		var st models.ModeStat
+		var scoreSum, accGames int64
+		var accSum float64
+		if err := rows.Scan(&st.Mode, &st.GamesPlayed, &st.BestScore, &scoreSum, &accSum, &accGames); err != nil {
+			return nil, err
+		}
+		st.Difficulty = difficulty
+		if st.GamesPlayed > 0 {
+			st.AvgScore = round1(float64(scoreSum) / float64(st.GamesPlayed))
+		}
+		if accGames > 0 {
+			st.AvgAccuracy = round1(accSum / float64(accGames))
+		}
+		stats = append(stats, st)
+	}
+	return stats, rows.Err()
+}
+
+// GetRecentGames returns last 10 games, optionally filtered by mode.
+func (s *Store) GetRecentGames(ctx context.Context, userID int64, mode string) ([]models.GameSessionRecord, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	var query string
+	var args []any
+
+	if mode != "" {
+		query = `SELECT id, mode, difficulty, score, correct, total, time_limit, created_at
+			FROM game_sessions
+			WHERE user_id = $1 AND mode = $2
+			ORDER BY created_at DESC
+			LIMIT 10`
+		args = []any{userID, mode}
+	} else {
+		query = `SELECT id, mode, difficulty, score, correct, total, time_limit, created_at
+			FROM game_sessions
+			WHERE user_id = $1
+			ORDER BY created_at DESC
+			LIMIT 10`
+		args = []any{userID}
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var games []models.GameSessionRecord
+	for rows.Next() {
+		var g models.GameSessionRecord
+		if err := rows.Scan(&g.ID, &g.Mode, &g.Difficulty, &g.Score, &g.Correct, &g.Total, &g.TimeLimit, &g.PlayedAt); err != nil {
+			return nil, err
+		}
+		games = append(games, g)
+	}
+	return games, rows.Err()
+}
+
+// ListGameSessions pages through a user's history newest first. The cursor
+// compares on (created_at, id) so sessions saved in the same instant are
+// neither skipped nor repeated.
+func (s *Store) ListGameSessions(ctx context.Context, userID int64, mode string, after *models.SessionCursor, limit int) ([]models.GameSessionRecord, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	query := `SELECT id, mode, difficulty, score, correct, total, time_limit, created_at, seed, answers, imported, practice
+		FROM game_sessions
+		WHERE user_id = $1`
+	args := []any{userID}
+	if mode != "" {
+		args = append(args, mode)
+		query += fmt.Sprintf(` AND mode = $%d`, len(args))
+	}
+	if after != nil {
+		args = append(args, s.dialect.Time(after.PlayedAt), after.ID)
+		query += fmt.Sprintf(` AND (created_at < $%d OR (created_at = $%d AND id < $%d))`, len(args)-1, len(args)-1, len(args))
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(` ORDER BY created_at DESC, id DESC LIMIT $%d`, len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var games []models.GameSessionRecord
+	for rows.Next() {
+		var g models.GameSessionRecord
+		var seed, answers sql.NullString
+		if err := rows.Scan(&g.ID, &g.Mode, &g.Difficulty, &g.Score, &g.Correct, &g.Total, &g.TimeLimit, &g.PlayedAt, &seed, &answers, &g.Imported, &g.Practice); err != nil {
+			return nil, err
+		}
+		if g.Seed, g.Answers, err = decodeAnswers(seed, answers); err != nil {
+			return nil, err
+		}
+		games = append(games, g)
+	}
+	return games, rows.Err()
+}
+
+func (s *Store) ListSessionsBetween(ctx context.Context, userID int64, filter models.SessionFilter, from, to time.Time) ([]models.GameSessionRecord, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	where, args := s.sessionFilter(userID, filter)
+	args = append(args, s.dialect.Time(from), s.dialect.Time(to))
+	query := fmt.Sprintf(`SELECT id, mode, difficulty, score, correct, total, time_limit, created_at, seed, answers, imported, practice
+		FROM game_sessions
+		WHERE %s AND created_at >= $%d AND created_at < $%d
+		ORDER BY created_at, id`, where, len(args)-1, len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var games []models.GameSessionRecord
+	for rows.Next() {
+		var g models.GameSessionRecord
+		var seed, answers sql.NullString
+		if err := rows.Scan(&g.ID, &g.Mode, &g.Difficulty, &g.Score, &g.Correct, &g.Total, &g.TimeLimit, &g.PlayedAt, &seed, &answers, &g.Imported, &g.Practice); err != nil {
+			return nil, err
+		}
+		if g.Seed, g.Answers, err = decodeAnswers(seed, answers); err != nil {
+			return nil, err
+		}
+		games = append(games, g)
+	}
+	return games, rows.Err()
+}
+
+func (s *Store) GetBestScoreBefore(ctx context.Context, userID int64, filter models.SessionFilter, t time.Time) (int, bool, error) {
+	ctx, cancel := s.ctx(ctx)
+	defer cancel()
+
+	where, args := s.sessionFilter(userID, filter)
+	args = append(args, s.dialect.Time(t))
+	var best sql.NullInt64
+	err := s.db.QueryRowContext(ctx,
+		fmt.Sprintf(`SELECT MAX(score) FROM game_sessions WHERE %s AND created_at < $%d`, where, len(args)),
+		args...,
+	).Scan(&best)
+	if err != nil {
+		return 0, false, err
+	}
+	return int(best.Int64), best.Valid, nil
+}
+
+// sessionFilter returns the WHERE conditions and arguments selecting the
+// user's sessions that match filter.
+func (s *Store) sessionFilter(userID int64, filter models.SessionFilter) (string, []any) {
+	conds := []string{"user_id = $1"}
+	args := []any{userID}
+	add := func(column string, v any) {
+		args = append(args, v)
+		conds = append(conds, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if filter.Mode != "" {
+		add("mode", filter.Mode)
+	}
+	if filter.Difficulty != 0 {
+		add("difficulty", filter.Difficulty)
+	}
+	if filter.TimeLimit != 0 {
+		add("time_limit", filter.TimeLimit)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// encodeAnswers returns the seed and answers columns, both NULL for sessions
+// that were not scored by the server.
+func encodeAnswers(seed string, answers []int) (sql.NullString, sql.NullString, error) {
+	if seed == "" {
+		return sql.NullString{}, sql.NullString{}, nil
+	}
+	data, err := json.Marshal(answers)
+	if err != nil {
+		return sql.NullString{}, sql.NullString{}, err
+	}
+	return sql.NullString{String: seed, Valid: true}, sql.NullString{String: string(data), Valid: true}, nil
+}
+
+func decodeAnswers(seed, answers sql.NullString) (string, []int, error) {
+	if !seed.Valid || !answers.Valid {
+		return "", nil, nil
+	}
+	var decoded []int
+	if err := json.Unmarshal([]byte(answers.String), &decoded); err != nil {
+		return "", nil, fmt.Errorf("decode answers: %w", err)
+	}
+	return seed.String, decoded, nil
+}
+
+func round1(f float64) float64 {
+	return math.Round(f*10) / 10
+}
@@ -1,281 +1,145 @@
+// Package database defines the storage interface the handlers depend on.
+// Implementations live in subpackages: postgres and sqlite (both built on
+// sqlstore) and memory.
 package database
 
 import (
 	"context"
-	"database/sql"
 	"refine-v2/backend/internal/models"
 	"time"
 )
 
-type Store struct {
-	DB *sql.DB
-}
-
-func NewStore(db *sql.DB) *Store {
-	return &Store{DB: db}
-}
-
-func (s *Store) ctx() (context.Context, context.CancelFunc) {
-	return context.WithTimeout(context.Background(), 5*time.Second)
-}
-
-// --- Users ---
-
-func (s *Store) CreateUser(email, username, passwordHash string) (*models.User, error) {
-	ctx, cancel := s.ctx()
-	defer cancel()
-
-	var user models.User
-	err := s.DB.QueryRowContext(ctx,
-		`INSERT INTO users (email, username, password_hash)
-		 VALUES ($1, $2, $3)
-		 RETURNING id, email, username, created_at`,
-		email, username, passwordHash,
-	).Scan(&user.ID, &user.Email, &user.Username, &user.CreatedAt)
-	if err != nil {
-		return nil, err
-	}
-	return &user, nil
-}
-
-func (s *Store) GetUserByEmail(email string) (*models.User, string, error) {
-	ctx, cancel := s.ctx()
-	defer cancel()
-
-	var user models.User
-	var passwordHash string
-	err := s.DB.QueryRowContext(ctx,
-		`SELECT id, email, username, password_hash, created_at
-		 FROM users WHERE email = $1`,
-		email,
-	).Scan(&user.ID, &user.Email, &user.Username, &passwordHash, &user.CreatedAt)
-	if err != nil {
-		return nil, "", err
-	}
-	return &user, passwordHash, nil
-}
-
-func (s *Store) GetUserByID(id int64) (*models.User, error) {
-	ctx, cancel := s.ctx()
-	defer cancel()
-
-	var user models.User
-	err := s.DB.QueryRowContext(ctx,
-		`SELECT id, email, username, created_at
-		 FROM users WHERE id = $1`,
-		id,
-	).Scan(&user.ID, &user.Email, &user.Username, &user.CreatedAt)
-	if err != nil {
-		return nil, err
-	}
-	return &user, nil
-}
-
-func (s *Store) GetPasswordHash(userID int64) (string, error) {
-	ctx, cancel := s.ctx()
-	defer cancel()
-
-	var hash string
-	err := s.DB.QueryRowContext(ctx,
-		`SELECT password_hash FROM users WHERE id = $1`, userID,
-	).Scan(&hash)
-	return hash, err
-}
-
-func (s *Store) UpdatePassword(userID int64, newHash string) error {
-	ctx, cancel := s.ctx()
-	defer cancel()
-
-	_, err := s.DB.ExecContext(ctx,
-		`UPDATE users SET password_hash = $1 WHERE id = $2`, newHash, userID)
-	return err
-}
-
-func (s *Store) UpdateUsername(userID int64, newUsername string) error {
-	ctx, cancel := s.ctx()
-	defer cancel()
-
-	_, err := s.DB.ExecContext(ctx,
-		`UPDATE users SET username = $1 WHERE id = $2`, newUsername, userID)
-	return err
-}
-
-func (s *Store) DeleteUser(userID int64) error {
-	ctx, cancel := s.ctx()
-	defer cancel()
-
-	// Delete game sessions first (FK constraint)
-	if _, err := s.DB.ExecContext(ctx,
-		`DELETE FROM game_sessions WHERE user_id = $1`, userID); err != nil {
-		return err
-	}
-	_, err := s.DB.ExecContext(ctx,
-		`DELETE FROM users WHERE id = $1`, userID)
-	return err
-}
-
-// --- Email signups ---
-
-func (s *Store) InsertEmail(email string) error {
-	ctx, cancel := s.ctx()
-	defer cancel()
-
-	_, err := s.DB.ExecContext(ctx,
-		`INSERT INTO email_signups (email) VALUES ($1)`, email)
-	return err
-}
-
-// --- Game sessions ---
-
-func (s *Store) SaveGameSession(userID int64, req models.SaveSessionRequest) error {
-	ctx, cancel := s.ctx()
-	defer cancel()
-
-	_, err := s.DB.ExecContext(ctx,
-		`INSERT INTO game_sessions (user_id, mode, difficulty, score, correct, total, time_limit)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		userID, req.Mode, req.Difficulty, req.Score, req.Correct, req.Total, req.TimeLimit,
-	)
-	return err
-}
-
-// --- Leaderboard ---
-
-func (s *Store) GetGlobalLeaderboard(mode string, difficulty int, timeLimit int) ([]models.LeaderboardEntry, error) {
-	ctx, cancel := s.ctx()
-	defer cancel()
-
-	rows, err := s.DB.QueryContext(ctx,
-		`SELECT u.username, gs.score, gs.correct, gs.total, gs.time_limit, gs.created_at
-		 FROM game_sessions gs
-		 JOIN users u ON u.id = gs.user_id
-		 WHERE gs.mode = $1 AND gs.difficulty = $2 AND gs.time_limit = $3
-		 ORDER BY gs.score DESC
-		 LIMIT 5`,
-		mode, difficulty, timeLimit,
-	)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var entries []models.LeaderboardEntry
-	rank := 1
-	for rows.Next() {
-		var e models.LeaderboardEntry
-		if err := rows.Scan(&e.Username, &e.Score, &e.Correct, &e.Total, &e.TimeLimit, &e.PlayedAt); err != nil {
-			return nil, err
-		}
-		e.Rank = rank
-		rank++
-		entries = append(entries, e)
-	}
-	return entries, rows.Err()
-}
-
-func (s *Store) GetPersonalLeaderboard(userID int64, mode string, difficulty int, timeLimit int) ([]models.LeaderboardEntry, error) {
-	ctx, cancel := s.ctx()
-	defer cancel()
-
-	rows, err := s.DB.QueryContext(ctx,
-		`SELECT score, correct, total, time_limit, created_at
-		 FROM game_sessions
-		 WHERE user_id = $1 AND mode = $2 AND difficulty = $3 AND time_limit = $4
-		 ORDER BY score DESC
-		 LIMIT 5`,
-		userID, mode, difficulty, timeLimit,
-	)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var entries []models.LeaderboardEntry
-	rank := 1
-	for rows.Next() {
-		var e models.LeaderboardEntry
-		if err := rows.Scan(&e.Score, &e.Correct, &e.Total, &e.TimeLimit, &e.PlayedAt); err != nil {
-			return nil, err
-		}
-		e.Rank = rank
-		rank++
-		entries = append(entries, e)
-	}
-	return entries, rows.Err()
-}
-
-// --- Stats ---
-
-func (s *Store) GetUserStats(userID int64, difficulty int) ([]models.ModeStat, error) {
-	ctx, cancel := s.ctx()
-	defer cancel()
-
-	rows, err := s.DB.QueryContext(ctx,
-		`SELECT mode,
-			COUNT(*) as games_played,
-			MAX(score) as best_score,
-			ROUND(AVG(score)::numeric, 1) as avg_score,
-			ROUND(AVG(correct::numeric / NULLIF(total, 0) * 100)::numeric, 1) as avg_accuracy
-		 FROM game_sessions
-		 WHERE user_id = $1 AND difficulty = $2
-		 GROUP BY mode
-		 ORDER BY mode`,
-		userID, difficulty,
-	)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var stats []models.ModeStat
-	for rows.Next() {
-		var s models.ModeStat
-		if err := rows.Scan(&s.Mode, &s.GamesPlayed, &s.BestScore, &s.AvgScore, &s.AvgAccuracy); err != nil {
-			return nil, err
-		}
-		s.Difficulty = difficulty
-		stats = append(stats, s)
-	}
-	return stats, rows.Err()
-}
-
-// GetRecentGames returns last 10 games, optionally filtered by mode.
-func (s *Store) GetRecentGames(userID int64, mode string) ([]models.GameSessionRecord, error) {
-	ctx, cancel := s.ctx()
-	defer cancel()
-
-	var query string
-	var args []any
-
-	if mode != "" {
-		query = `SELECT id, mode, difficulty, score, correct, total, time_limit, created_at
-			FROM game_sessions
-			WHERE user_id = $1 AND mode = $2
-			ORDER BY created_at DESC
-			LIMIT 10`
-		args = []any{userID, mode}
-	} else {
-		query = `SELECT id, mode, difficulty, score, correct, total, time_limit, created_at
-			FROM game_sessions
-			WHERE user_id = $1
-			ORDER BY created_at DESC
-			LIMIT 10`
-		args = []any{userID}
-	}
-
-	rows, err := s.DB.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var games []models.GameSessionRecord
-	for rows.Next() {
-		var g models.GameSessionRecord
-		if err := rows.Scan(&g.ID, &g.Mode, &g.Difficulty, &g.Score, &g.Correct, &g.Total, &g.TimeLimit, &g.PlayedAt); err != nil {
-			return nil, err
-		}
-		games = append(games, g)
-	}
-	return games, rows.Err()
+// Store is the full repository used by the HTTP handlers. Every method takes
+// the caller's context; handlers pass r.Context() so that a client disconnect
+// or request timeout cancels the query.
+type Store interface {
+	UserStore
+	TwoFactorStore
+	APITokenStore
+	SessionStore
+	ImportStore
+	EmailStore
+	AdminStore
+
+	// Ping checks that the backing database is reachable.
+	Ping(ctx context.Context) error
+	Close() error
+}
+
+type UserStore interface {
+	// CreateUser returns ErrEmailTaken or ErrUsernameTaken on conflict.
+	CreateUser(ctx context.Context, email, username, passwordHash string) (*models.User, error)
+	// GetUserByEmail returns the user and their password hash, or ErrNotFound.
+	GetUserByEmail(ctx context.Context, email string) (*models.User, string, error)
+	GetUserByID(ctx context.Context, id int64) (*models.User, error)
+	// GetUserAccess returns the user's role and whether they are banned.
+	GetUserAccess(ctx context.Context, userID int64) (string, bool, error)
+	GetPasswordHash(ctx context.Context, userID int64) (string, error)
+	UpdatePassword(ctx context.Context, userID int64, newHash string) error
+	// UpdateUsername returns ErrUsernameTaken on conflict.
+	UpdateUsername(ctx context.Context, userID int64, newUsername string) error
+	DeleteUser(ctx context.Context, userID int64) error
+}
+
+type TwoFactorStore interface {
+	// GetTOTP returns the user's TOTP secret (empty if none) and whether 2FA is enabled.
+	GetTOTP(ctx context.Context, userID int64) (string, bool, error)
+	// SetPendingTOTP stores a secret that is not yet active until EnableTOTP.
+	SetPendingTOTP(ctx context.Context, userID int64, secret string) error
+	// EnableTOTP activates 2FA and replaces any existing recovery codes.
+	EnableTOTP(ctx context.Context, userID int64, recoveryHashes []string) error
+	// DisableTOTP clears the secret and all recovery codes.
+	DisableTOTP(ctx context.Context, userID int64) error
+	// ReplaceRecoveryCodes invalidates all existing recovery codes for the user.
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, hashes []string) error
+	// UseTOTPStep records step as the last TOTP time step accepted for the
+	// user. It reports false if step is not after the last one, so each code
+	// is accepted once.
+	UseTOTPStep(ctx context.Context, userID int64, step int64) (bool, error)
+	// ConsumeRecoveryCode marks an unused recovery code as used. It reports
+	// false if the code does not exist or was already used.
+	ConsumeRecoveryCode(ctx context.Context, userID int64, hash string) (bool, error)
+}
+
+type APITokenStore interface {
+	CreateAPIToken(ctx context.Context, userID int64, name, hash, prefix string, scopes []string, expiresAt time.Time) (*models.APIToken, error)
+	ListAPITokens(ctx context.Context, userID int64) ([]models.APIToken, error)
+	// DeleteAPIToken revokes a token. It reports false if the token does not
+	// exist or belongs to another user.
+	DeleteAPIToken(ctx context.Context, userID, tokenID int64) (bool, error)
+	// AuthenticateAPIToken looks up an unexpired token by hash, records its use
+	// and returns the owning user and the token's scopes, or ErrNotFound.
+	AuthenticateAPIToken(ctx context.Context, hash string) (*models.User, []string, error)
+}
+
+type SessionStore interface {
+	// SaveGameSession records a finished game. A session with a seed uses the
+	// seed up: saving another with the same seed returns ErrSeedUsed.
+	SaveGameSession(ctx context.Context, userID int64, req models.SaveSessionRequest) error
+	// GetGlobalLeaderboard returns the top sessions for the current period
+	// (one of models.PeriodAll, PeriodWeek or PeriodDay).
+	GetGlobalLeaderboard(ctx context.Context, mode string, difficulty int, timeLimit int, period string) ([]models.LeaderboardEntry, error)
+	GetPersonalLeaderboard(ctx context.Context, userID int64, mode string, difficulty int, timeLimit int) ([]models.LeaderboardEntry, error)
+	GetUserStats(ctx context.Context, userID int64, difficulty int) ([]models.ModeStat, error)
+	// GetRecentGames returns last 10 games, optionally filtered by mode.
+	GetRecentGames(ctx context.Context, userID int64, mode string) ([]models.GameSessionRecord, error)
+	// ListGameSessions returns up to limit of the user's sessions, newest
+	// first, optionally filtered by mode. With a cursor it starts after that
+	// session.
+	ListGameSessions(ctx context.Context, userID int64, mode string, after *models.SessionCursor, limit int) ([]models.GameSessionRecord, error)
+	// ListSessionsBetween returns the user's sessions matching filter played
+	// in [from, to), oldest first, with their seeds and answers.
+	ListSessionsBetween(ctx context.Context, userID int64, filter models.SessionFilter, from, to time.Time) ([]models.GameSessionRecord, error)
+	// GetBestScoreBefore returns the user's best score matching filter before
+	// t, and false if there are no such sessions.
+	GetBestScoreBefore(ctx context.Context, userID int64, filter models.SessionFilter, t time.Time) (int, bool, error)
+}
+
+// ImportStore keeps background import jobs and saves the sessions they import.
+type ImportStore interface {
+	// CreateImportJob stores a new job and sets its ID and CreatedAt. It
+	// returns ErrImportActive if the user has another job pending or running,
+	// ignoring those older than models.ImportStaleAfter.
+	CreateImportJob(ctx context.Context, userID int64, job *models.ImportJob) error
+	// UpdateImportJob saves the job's status, counts, error and row results.
+	UpdateImportJob(ctx context.Context, job *models.ImportJob) error
+	// GetImportJob returns ErrNotFound if the job does not exist or belongs
+	// to another user.
+	GetImportJob(ctx context.Context, userID, jobID int64) (*models.ImportJob, error)
+	// ImportGameSessions saves sessions flagged as imported: they count
+	// towards the user's stats and personal bests but never the global
+	// leaderboards. A session identical to one the user already has (same
+	// time, settings and result) is skipped. It returns the new session IDs
+	// in order, with 0 for skipped sessions.
+	ImportGameSessions(ctx context.Context, userID int64, sessions []models.ImportedSession) ([]int64, error)
+}
+
+type EmailStore interface {
+	// InsertEmail returns ErrEmailTaken if the email is already on the list.
+	InsertEmail(ctx context.Context, email string) error
+}
+
+// AdminStore mutations are applied together with their audit log entry, so
+// an action is never applied without being recorded.
+type AdminStore interface {
+	SearchUsers(ctx context.Context, query string, limit, offset int) ([]models.AdminUser, error)
+	GetUserSessions(ctx context.Context, userID int64, limit, offset int) ([]models.AdminGameSession, error)
+	// SetUserBanned bans or unbans a user. It reports false if the user does not exist.
+	SetUserBanned(ctx context.Context, userID int64, banned bool, reason string, audit models.AuditEntry) (bool, error)
+	// AdminUpdateUsername renames a user. It reports false if the user does not
+	// exist and returns ErrUsernameTaken on conflict.
+	AdminUpdateUsername(ctx context.Context, userID int64, username string, audit models.AuditEntry) (bool, error)
+	// SetSessionHidden removes a session from (or restores it to) the global
+	// leaderboard. It reports false if the session does not exist.
+	SetSessionHidden(ctx context.Context, sessionID int64, hidden bool, audit models.AuditEntry) (bool, error)
+	ListEmailSignups(ctx context.Context) ([]models.EmailSignup, error)
+	// WriteAudit records a read-only admin action.
+	WriteAudit(ctx context.Context, audit models.AuditEntry) error
+	ListAuditLog(ctx context.Context, limit, offset int) ([]models.AuditEntry, error)
+}
+
+// AggregateRebuilder is implemented by stores that keep precomputed stats and
+// leaderboards. RebuildAggregates recomputes them from the raw sessions.
+type AggregateRebuilder interface {
+	RebuildAggregates(ctx context.Context) error
 }
@@ -0,0 +1,292 @@
+package generator
+
+import (
+	"fmt"
+	"math/rand"
+	"refine-v2/backend/internal/models"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A focus seed names the targets of a focused set:
+// "focus:<target>,<target>,...:<seed>". Callers that accept focused sets
+// parse it with ParseFocusSeed and generate with GenerateFocused;
+// GenerateWithSeed ignores the targets.
+const focusPrefix = "focus:"
+
+// MaxFocusTargets is the most targets one problem set can aim at.
+const MaxFocusTargets = 20
+
+// MaxOperand bounds the numbers a target can name.
+const MaxOperand = 99999
+
+// regroupAttempts bounds how many problems are drawn looking for one that
+// carries or borrows before settling for one that doesn't.
+const regroupAttempts = 50
+
+var (
+	rangeTarget  = regexp.MustCompile(`^(\d+)(?:\.\.(\d+))?([-+x/])(\d+)(?:\.\.(\d+))?$`)
+	familyTarget = regexp.MustCompile(`^([x/])(\d+)$`)
+)
+
+// Target is one kind of problem to practise. Its key, from String and
+// ParseTarget, is one of:
+//
+//	7x8, 56/7, 12+9, 15-6          a single fact
+//	10..99+1..9, 100..999x2..9     operands in ranges
+//	x7, /7                         multiplication by or division by a number
+//	+carry, -borrow                additions that carry, subtractions that borrow
+type Target struct {
+	Operator string // "+", "-", "×" or "÷"
+	// Num1 and Num2 bound the operands; a zero Range means the difficulty's
+	// range.
+	Num1, Num2 Range
+	// Regroup asks for additions that carry or subtractions that borrow.
+	Regroup bool
+	// Commute shows the operands of a fact or family in either order.
+	Commute bool
+}
+
+var keyOperators = map[string]string{"+": "+", "-": "-", "x": "×", "/": "÷"}
+
+var operatorModes = map[string]string{
+	"+": "addition",
+	"-": "subtraction",
+	"×": "multiplication",
+	"÷": "division",
+}
+
+// ParseTarget parses a target key.
+func ParseTarget(key string) (Target, error) {
+	switch key {
+	case "+carry":
+		return Target{Operator: "+", Regroup: true}, nil
+	case "-borrow":
+		return Target{Operator: "-", Regroup: true}, nil
+	}
+
+	number := func(s string) (int, error) {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > MaxOperand {
+			return 0, fmt.Errorf("numbers must be 1-%d", MaxOperand)
+		}
+		return n, nil
+	}
+	span := func(lo, hi string) (Range, error) {
+		min, err := number(lo)
+		if err != nil {
+			return Range{}, err
+		}
+		max := min
+		if hi != "" {
+			if max, err = number(hi); err != nil {
+				return Range{}, err
+			}
+		}
+		if min > max {
+			return Range{}, fmt.Errorf("range %d..%d is empty", min, max)
+		}
+		return Range{Min: min, Max: max}, nil
+	}
+
+	if m := familyTarget.FindStringSubmatch(key); m != nil {
+		n, err := number(m[2])
+		if err != nil {
+			return Target{}, err
+		}
+		if m[1] == "x" {
+			return Target{Operator: "×", Num1: Range{Min: n, Max: n}, Commute: true}, nil
+		}
+		return Target{Operator: "÷", Num2: Range{Min: n, Max: n}}, nil
+	}
+
+	m := rangeTarget.FindStringSubmatch(key)
+	if m == nil {
+		return Target{}, fmt.Errorf("expected a fact like 7x8, a range like 10..99+1..9, x7, /7, +carry or -borrow")
+	}
+	t := Target{Operator: keyOperators[m[3]]}
+	var err error
+	if t.Num1, err = span(m[1], m[2]); err != nil {
+		return Target{}, err
+	}
+	if t.Num2, err = span(m[4], m[5]); err != nil {
+		return Target{}, err
+	}
+	fact := m[2] == "" && m[5] == ""
+	switch {
+	case t.Operator == "÷" && t.Num2.Min < 2:
+		return Target{}, fmt.Errorf("divisors must be at least 2")
+	case fact && t.Operator == "÷" && t.Num1.Min%t.Num2.Min != 0:
+		return Target{}, fmt.Errorf("%d is not a multiple of %d", t.Num1.Min, t.Num2.Min)
+	case fact && t.Operator == "-" && t.Num1.Min < t.Num2.Min:
+		return Target{}, fmt.Errorf("subtraction facts must not go below zero")
+	}
+	t.Commute = fact && (t.Operator == "+" || t.Operator == "×")
+	return t, nil
+}
+
+// String returns the target's key.
+func (t Target) String() string {
+	var op string
+	for k, v := range keyOperators {
+		if v == t.Operator {
+			op = k
+		}
+	}
+	switch {
+	case t.Regroup && t.Operator == "+":
+		return "+carry"
+	case t.Regroup:
+		return "-borrow"
+	case t.Num2 == Range{}:
+		return op + strconv.Itoa(t.Num1.Min)
+	case t.Num1 == Range{}:
+		return op + strconv.Itoa(t.Num2.Min)
+	}
+	return t.Num1.key() + op + t.Num2.key()
+}
+
+// Mode is the mode whose problems the target produces.
+func (t Target) Mode() string {
+	return operatorModes[t.Operator]
+}
+
+func (r Range) key() string {
+	if r.Min == r.Max {
+		return strconv.Itoa(r.Min)
+	}
+	return fmt.Sprintf("%d..%d", r.Min, r.Max)
+}
+
+// FocusMode is the mode a focused set is saved under: the targets' mode if
+// they share one, otherwise mixed.
+func FocusMode(targets []Target) string {
+	mode := ""
+	for _, t := range targets {
+		if mode != "" && t.Mode() != mode {
+			return "mixed"
+		}
+		mode = t.Mode()
+	}
+	return mode
+}
+
+// FocusSeed returns a seed generating problems aimed at targets.
+func FocusSeed(targets []Target, seed string) string {
+	keys := make([]string, len(targets))
+	for i, t := range targets {
+		keys[i] = t.String()
+	}
+	return focusPrefix + strings.Join(keys, ",") + ":" + seed
+}
+
+// ParseFocusSeed returns the targets of a focus seed. Seeds that merely look
+// like one are ordinary seeds.
+func ParseFocusSeed(seed string) ([]Target, bool) {
+	rest, ok := strings.CutPrefix(seed, focusPrefix)
+	if !ok {
+		return nil, false
+	}
+	keys, _, ok := strings.Cut(rest, ":")
+	if !ok {
+		return nil, false
+	}
+	var targets []Target
+	for _, key := range strings.Split(keys, ",") {
+		t, err := ParseTarget(key)
+		if err != nil {
+			return nil, false
+		}
+		targets = append(targets, t)
+	}
+	return targets, len(targets) <= MaxFocusTargets
+}
+
+// GenerateFocused spreads count problems evenly over targets, in random
+// order. targets must not be empty.
+func GenerateFocused(seed string, targets []Target, difficulty, count int, config *models.CustomConfig) []models.Problem {
+	rng := seededRand(seed)
+	order := make([]int, count)
+	for i := range order {
+		order[i] = i % len(targets)
+	}
+	rng.Shuffle(count, func(i, j int) { order[i], order[j] = order[j], order[i] })
+
+	problems := make([]models.Problem, count)
+	for i, t := range order {
+		problems[i] = generateTarget(rng, targets[t], difficulty, config, i)
+	}
+	return problems
+}
+
+func generateTarget(rng *rand.Rand, t Target, difficulty int, config *models.CustomConfig, id int) models.Problem {
+	low, high := getRangeForDifficulty(difficulty, t.Mode(), config)
+	if high < low || high < 2 {
+		low, high = getRangeForDifficulty(1, t.Mode(), nil)
+	}
+	pick := func(r Range) int {
+		if r == (Range{}) {
+			r = Range{Min: low, Max: high}
+		}
+		return rng.Intn(r.Max-r.Min+1) + r.Min
+	}
+
+	p := models.Problem{ID: id, Operator: t.Operator}
+	switch t.Operator {
+	case "+", "-":
+		for attempt := 0; ; attempt++ {
+			p.Num1, p.Num2 = pick(t.Num1), pick(t.Num2)
+			if t.Operator == "-" && p.Num1 < p.Num2 {
+				p.Num1, p.Num2 = p.Num2, p.Num1
+			}
+			if t.Operator == "+" {
+				p.Answer = p.Num1 + p.Num2
+			} else {
+				p.Answer = p.Num1 - p.Num2
+			}
+			if !t.Regroup || attempt == regroupAttempts || Regroups(p) {
+				break
+			}
+		}
+	case "×":
+		p.Num1, p.Num2 = pick(t.Num1), pick(t.Num2)
+		p.Answer = p.Num1 * p.Num2
+	case "÷":
+		divisor := pick(t.Num2)
+		// Quotients come from the dividend range, or stay small enough
+		// that dividends are within the difficulty's range
+		lo, hi := 2, high/divisor
+		if t.Num1 != (Range{}) {
+			lo, hi = (t.Num1.Min+divisor-1)/divisor, t.Num1.Max/divisor
+		}
+		lo, hi = max(lo, 1), max(hi, lo)
+		p.Answer = rng.Intn(hi-lo+1) + lo
+		p.Num1, p.Num2 = p.Answer*divisor, divisor
+	}
+	if t.Commute && rng.Intn(2) == 0 {
+		p.Num1, p.Num2 = p.Num2, p.Num1
+	}
+	return p
+}
+
+// Regroups reports whether an addition carries or a subtraction borrows in
+// any column.
+func Regroups(p models.Problem) bool {
+	switch p.Operator {
+	case "+":
+		return digitSum(p.Num1)+digitSum(p.Num2) != digitSum(p.Answer)
+	case "-":
+		// a - b borrows exactly when (a - b) + b carries
+		return digitSum(p.Answer)+digitSum(p.Num2) != digitSum(p.Num1)
+	}
+	return false
+}
+
+func digitSum(n int) int {
+	sum := 0
+	for ; n > 0; n /= 10 {
+		sum += n % 10
+	}
+	return sum
+}
@@ -15,12 +15,10 @@ func CreateSeed() string {
 
 var mixedModes = []string{"addition", "subtraction", "multiplication", "division"}
 
+// GenerateWithSeed generates count problems in mode. Focus seeds are treated
+// like any other seed; GenerateFocused generates their sets.
 func GenerateWithSeed(seed string, mode string, difficulty int, count int, config *models.CustomConfig) []models.Problem {
-	h := fnv.New64a()
-	h.Write([]byte(seed))
-	seedNum := int64(h.Sum64())
-	rng := rand.New(rand.NewSource(seedNum))
-
+	rng := seededRand(seed)
 	problems := make([]models.Problem, count)
 
 	if mode == "mixed" {
@@ -41,6 +39,12 @@ func GenerateWithSeed(seed string, mode string, difficulty int, count int, confi
 	return problems
 }
 
+func seededRand(seed string) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(seed))
+	return rand.New(rand.NewSource(int64(h.Sum64())))
+}
+
 func ConvertToQuestions(problems []models.Problem) []models.Question {
 	questions := make([]models.Question, len(problems))
 	for i, p := range problems {
@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"refine-v2/backend/internal/apierr"
+	"refine-v2/backend/internal/cache"
+	"refine-v2/backend/internal/database"
+	"refine-v2/backend/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Audit log action names.
+const (
+	auditSearchUsers    = "users.search"
+	auditViewSessions   = "users.view_sessions"
+	auditBanUser        = "users.ban"
+	auditUnbanUser      = "users.unban"
+	auditRenameUser     = "users.rename"
+	auditHideSession    = "sessions.hide"
+	auditRestoreSession = "sessions.restore"
+	auditExportEmails   = "email_signups.export"
+)
+
+func AdminSearchUsers(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := strings.TrimSpace(r.URL.Query().Get("q"))
+		limit, offset := parsePage(r)
+
+		if err := store.WriteAudit(r.Context(), newAudit(r, auditSearchUsers, "user", nil, map[string]any{"q": query})); err != nil {
+			serverError(w, r, "Failed to write audit log", err)
+			return
+		}
+
+		users, err := store.SearchUsers(r.Context(), query, limit, offset)
+		if err != nil {
+			serverError(w, r, "Failed to search users", err)
+			return
+		}
+
+		if users == nil {
+			users = []models.AdminUser{}
+		}
+
+		writeJSON(w, http.StatusOK, models.AdminUsersResponse{Users: users})
+	}
+}
+
+func AdminGetUserSessions(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := parseIDParam(w, r, "id")
+		if !ok {
+			return
+		}
+		limit, offset := parsePage(r)
+
+		if err := store.WriteAudit(r.Context(), newAudit(r, auditViewSessions, "user", &userID, nil)); err != nil {
+			serverError(w, r, "Failed to write audit log", err)
+			return
+		}
+
+		sessions, err := store.GetUserSessions(r.Context(), userID, limit, offset)
+		if err != nil {
+			serverError(w, r, "Failed to get sessions", err)
+			return
+		}
+
+		if sessions == nil {
+			sessions = []models.AdminGameSession{}
+		}
+
+		writeJSON(w, http.StatusOK, models.AdminSessionsResponse{Sessions: sessions})
+	}
+}
+
+func AdminBanUser(store database.Store) http.HandlerFunc {
+	return adminSetBanned(store, true)
+}
+
+func AdminUnbanUser(store database.Store) http.HandlerFunc {
+	return adminSetBanned(store, false)
+}
+
+func adminSetBanned(store database.Store, banned bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := parseIDParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		var req models.AdminReasonRequest
+		if banned {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, r, apierr.ErrInvalidBody)
+				return
+			}
+			if strings.TrimSpace(req.Reason) == "" {
+				writeError(w, r, apierr.Invalid("reason", "A reason is required"))
+				return
+			}
+		}
+
+		if claims := GetClaims(r); claims != nil && claims.UserID == userID {
+			writeError(w, r, apierr.New(http.StatusForbidden, apierr.CodeForbidden, "You cannot ban yourself"))
+			return
+		}
+
+		action := auditUnbanUser
+		if banned {
+			action = auditBanUser
+		}
+
+		found, err := store.SetUserBanned(r.Context(), userID, banned, req.Reason,
+			newAudit(r, action, "user", &userID, map[string]any{"reason": req.Reason}))
+		if err != nil {
+			serverError(w, r, "Failed to update user", err)
+			return
+		}
+		if !found {
+			writeError(w, r, errUserNotFound)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]string{"message": "User updated"})
+	}
+}
+
+func AdminRenameUser(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := parseIDParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		var req models.AdminUsernameRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, apierr.ErrInvalidBody)
+			return
+		}
+
+		req.Username = strings.TrimSpace(req.Username)
+		if len(req.Username) < 3 || len(req.Username) > 20 {
+			writeError(w, r, errInvalidUsername)
+			return
+		}
+
+		found, err := store.AdminUpdateUsername(r.Context(), userID, req.Username,
+			newAudit(r, auditRenameUser, "user", &userID, map[string]any{"username": req.Username, "reason": req.Reason}))
+		if err != nil {
+			if errors.Is(err, database.ErrUsernameTaken) {
+				writeError(w, r, err)
+				return
+			}
+			serverError(w, r, "Failed to update username", err)
+			return
+		}
+		if !found {
+			writeError(w, r, errUserNotFound)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]string{"message": "Username updated"})
+	}
+}
+
+func AdminHideSession(store database.Store) http.HandlerFunc {
+	return adminSetSessionHidden(store, true)
+}
+
+func AdminRestoreSession(store database.Store) http.HandlerFunc {
+	return adminSetSessionHidden(store, false)
+}
+
+func adminSetSessionHidden(store database.Store, hidden bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionID, ok := parseIDParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		var req models.AdminReasonRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeError(w, r, apierr.ErrInvalidBody)
+				return
+			}
+		}
+
+		action := auditRestoreSession
+		if hidden {
+			action = auditHideSession
+		}
+
+		found, err := store.SetSessionHidden(r.Context(), sessionID, hidden,
+			newAudit(r, action, "game_session", &sessionID, map[string]any{"reason": req.Reason}))
+		if err != nil {
+			serverError(w, r, "Failed to update session", err)
+			return
+		}
+		if !found {
+			writeError(w, r, apierr.New(http.StatusNotFound, apierr.CodeNotFound, "Session not found"))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]string{"message": "Session updated"})
+	}
+}
+
+// AdminExportEmails streams the email signup list as CSV.
+func AdminExportEmails(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := store.WriteAudit(r.Context(), newAudit(r, auditExportEmails, "email_signups", nil, nil)); err != nil {
+			serverError(w, r, "Failed to write audit log", err)
+			return
+		}
+
+		signups, err := store.ListEmailSignups(r.Context())
+		if err != nil {
+			serverError(w, r, "Failed to export emails", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="email_signups.csv"`)
+
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"email", "created_at"})
+		for _, s := range signups {
+			cw.Write([]string{s.Email, s.CreatedAt.UTC().Format(time.RFC3339)})
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			slog.WarnContext(r.Context(), "email export write failed", "error", err)
+		}
+	}
+}
+
+func AdminGetAuditLog(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, offset := parsePage(r)
+
+		entries, err := store.ListAuditLog(r.Context(), limit, offset)
+		if err != nil {
+			serverError(w, r, "Failed to get audit log", err)
+			return
+		}
+
+		if entries == nil {
+			entries = []models.AuditEntry{}
+		}
+
+		writeJSON(w, http.StatusOK, models.AuditLogResponse{Entries: entries})
+	}
+}
+
+// AdminCacheStats reports hit and miss counts for each cache.
+func AdminCacheStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"caches": cache.Snapshot()})
+}
+
+func newAudit(r *http.Request, action, targetType string, targetID *int64, details map[string]any) models.AuditEntry {
+	entry := models.AuditEntry{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+	}
+	if claims := GetClaims(r); claims != nil {
+		entry.AdminID = claims.UserID
+	}
+	if details != nil {
+		b, _ := json.Marshal(details)
+		entry.Details = string(b)
+	}
+	return entry
+}
+
+// parsePage reads limit/offset query params, defaulting to 50 and capping at 200.
+func parsePage(r *http.Request) (int, int) {
+	limit, offset := 50, 0
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = min(l, 200)
+	}
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o > 0 {
+		offset = o
+	}
+	return limit, offset
+}
+
+func parseIDParam(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, name), 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, r, apierr.Invalid("id", "Invalid id"))
+		return 0, false
+	}
+	return id, true
+}
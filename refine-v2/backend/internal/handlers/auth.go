@@ -1,16 +1,18 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/mail"
+	"refine-v2/backend/internal/apierr"
 	"refine-v2/backend/internal/auth"
 	"refine-v2/backend/internal/database"
+	"refine-v2/backend/internal/metrics"
 	"refine-v2/backend/internal/models"
+	"refine-v2/backend/internal/ratelimit"
 	"strings"
 
-	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -20,11 +22,11 @@ func SetSecureCookies(secure bool) {
 	secureCookies = secure
 }
 
-func Signup(store *database.Store) http.HandlerFunc {
+func Signup(store database.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req models.SignupRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			writeError(w, http.StatusBadRequest, "Invalid request body")
+			writeError(w, r, apierr.ErrInvalidBody)
 			return
 		}
 
@@ -32,41 +34,37 @@ func Signup(store *database.Store) http.HandlerFunc {
 		req.Username = strings.TrimSpace(req.Username)
 
 		if _, err := mail.ParseAddress(req.Email); err != nil {
-			writeError(w, http.StatusBadRequest, "Invalid email address")
+			writeError(w, r, apierr.Invalid("email", "Invalid email address"))
 			return
 		}
 		if len(req.Username) < 3 || len(req.Username) > 20 {
-			writeError(w, http.StatusBadRequest, "Username must be 3-20 characters")
+			writeError(w, r, errInvalidUsername)
 			return
 		}
 		if len(req.Password) < 8 {
-			writeError(w, http.StatusBadRequest, "Password must be at least 8 characters")
+			writeError(w, r, apierr.Invalid("password", "Password must be at least 8 characters"))
 			return
 		}
 
 		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "Failed to process password")
+			serverError(w, r, "Failed to process password", err)
 			return
 		}
 
-		user, err := store.CreateUser(req.Email, req.Username, string(hash))
+		user, err := store.CreateUser(r.Context(), req.Email, req.Username, string(hash))
 		if err != nil {
-			if pqErr, ok := err.(*pq.Error); ok && string(pqErr.Code) == "23505" {
-				if strings.Contains(pqErr.Constraint, "email") {
-					writeError(w, http.StatusConflict, "Email already registered")
-				} else {
-					writeError(w, http.StatusConflict, "Username already taken")
-				}
+			if errors.Is(err, database.ErrEmailTaken) || errors.Is(err, database.ErrUsernameTaken) {
+				writeError(w, r, err)
 				return
 			}
-			writeError(w, http.StatusInternalServerError, "Failed to create account")
+			serverError(w, r, "Failed to create account", err)
 			return
 		}
 
 		tokenStr, err := auth.GenerateToken(user.ID, user.Username)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "Failed to generate token")
+			serverError(w, r, "Failed to generate token", err)
 			return
 		}
 
@@ -75,38 +73,76 @@ func Signup(store *database.Store) http.HandlerFunc {
 	}
 }
 
-func Login(store *database.Store) http.HandlerFunc {
+// Login verifies credentials. Repeated failures for an email lock it out
+// progressively via lockout.
+func Login(store database.Store, lockout *ratelimit.Lockout) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req models.LoginRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			writeError(w, http.StatusBadRequest, "Invalid request body")
+			writeError(w, r, apierr.ErrInvalidBody)
 			return
 		}
 
 		req.Email = strings.TrimSpace(strings.ToLower(req.Email))
 
-		user, passwordHash, err := store.GetUserByEmail(req.Email)
+		lockKey := "email:" + req.Email
+		if retryAfter := lockout.Check(lockKey); retryAfter > 0 {
+			writeTooManyRequests(w, r, retryAfter)
+			return
+		}
+
+		user, passwordHash, err := store.GetUserByEmail(r.Context(), req.Email)
 		if err != nil {
-			if err == sql.ErrNoRows {
-				writeError(w, http.StatusUnauthorized, "Invalid email or password")
+			if errors.Is(err, database.ErrNotFound) {
+				lockout.Fail(lockKey)
+				metrics.Logins.WithLabelValues(metrics.LoginFailure).Inc()
+				writeError(w, r, errInvalidCredentials)
 				return
 			}
-			writeError(w, http.StatusInternalServerError, "Failed to look up user")
+			serverError(w, r, "Failed to look up user", err)
 			return
 		}
 
 		if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.Password)); err != nil {
-			writeError(w, http.StatusUnauthorized, "Invalid email or password")
+			lockout.Fail(lockKey)
+			metrics.Logins.WithLabelValues(metrics.LoginFailure).Inc()
+			writeError(w, r, errInvalidCredentials)
+			return
+		}
+		lockout.Succeed(lockKey)
+
+		_, banned, err := store.GetUserAccess(r.Context(), user.ID)
+		if err != nil {
+			serverError(w, r, "Failed to look up user", err)
+			return
+		}
+		if banned {
+			writeError(w, r, errAccountSuspended)
+			return
+		}
+
+		// Accounts with 2FA get a challenge token instead of a session cookie
+		if user.TwoFactorEnabled {
+			challenge, err := auth.GenerateTwoFactorToken(user.ID, user.Username)
+			if err != nil {
+				serverError(w, r, "Failed to generate token", err)
+				return
+			}
+			writeJSON(w, http.StatusOK, models.LoginChallengeResponse{
+				TwoFactorRequired: true,
+				ChallengeToken:    challenge,
+			})
 			return
 		}
 
 		tokenStr, err := auth.GenerateToken(user.ID, user.Username)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "Failed to generate token")
+			serverError(w, r, "Failed to generate token", err)
 			return
 		}
 
 		setTokenCookie(w, tokenStr)
+		metrics.Logins.WithLabelValues(metrics.LoginSuccess).Inc()
 		writeJSON(w, http.StatusOK, models.AuthResponse{User: *user})
 	}
 }
@@ -124,17 +160,17 @@ func Logout(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]string{"message": "Logged out"})
 }
 
-func GetCurrentUser(store *database.Store) http.HandlerFunc {
+func GetCurrentUser(store database.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		claims := GetClaims(r)
 		if claims == nil {
-			writeError(w, http.StatusUnauthorized, "Not authenticated")
+			writeError(w, r, apierr.ErrUnauthenticated)
 			return
 		}
 
-		user, err := store.GetUserByID(claims.UserID)
+		user, err := store.GetUserByID(r.Context(), claims.UserID)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "Failed to get user")
+			serverError(w, r, "Failed to get user", err)
 			return
 		}
 
@@ -147,7 +183,7 @@ func setTokenCookie(w http.ResponseWriter, token string) {
 		Name:     "token",
 		Value:    token,
 		Path:     "/",
-		MaxAge:   7 * 24 * 60 * 60,
+		MaxAge:   int(auth.SessionTTL().Seconds()),
 		HttpOnly: true,
 		Secure:   secureCookies,
 		SameSite: http.SameSiteLaxMode,
@@ -2,34 +2,133 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"refine-v2/backend/internal/apierr"
 	"refine-v2/backend/internal/auth"
+	"refine-v2/backend/internal/database"
+	"strings"
 )
 
 type contextKey string
 
-const claimsKey contextKey = "claims"
+const (
+	claimsKey contextKey = "claims"
+	roleKey   contextKey = "role"
+)
 
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("token")
-		if err != nil {
-			writeError(w, http.StatusUnauthorized, "Not authenticated")
-			return
-		}
+// AuthMiddleware authenticates requests by session cookie or by an
+// Authorization: Bearer header. Bearer values may be a session JWT or a
+// personal access token; personal access tokens are only accepted when the
+// route lists scopes and the token holds all of them.
+func AuthMiddleware(store database.Store, scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenStr := bearerToken(r)
+			if tokenStr == "" {
+				cookie, err := r.Cookie("token")
+				if err != nil {
+					writeError(w, r, apierr.ErrUnauthenticated)
+					return
+				}
+				tokenStr = cookie.Value
+			}
 
-		claims, err := auth.ValidateToken(cookie.Value)
-		if err != nil {
-			writeError(w, http.StatusUnauthorized, "Invalid or expired token")
-			return
-		}
+			var claims *auth.Claims
+			if auth.IsAPIToken(tokenStr) {
+				if len(scopes) == 0 {
+					writeError(w, r, apierr.New(http.StatusForbidden, apierr.CodeTokenNotAllowed, "Personal access tokens cannot be used for this endpoint"))
+					return
+				}
+
+				user, granted, err := store.AuthenticateAPIToken(r.Context(), auth.HashAPIToken(tokenStr))
+				if err != nil {
+					if errors.Is(err, database.ErrNotFound) {
+						writeError(w, r, errInvalidToken)
+						return
+					}
+					serverError(w, r, "Failed to verify token", err)
+					return
+				}
+				if !hasScopes(granted, scopes) {
+					writeError(w, r, apierr.New(http.StatusForbidden, apierr.CodeMissingScope, "Token is missing a required scope"))
+					return
+				}
+				claims = &auth.Claims{UserID: user.ID, Username: user.Username}
+			} else {
+				var err error
+				claims, err = auth.ValidateToken(tokenStr)
+				if err != nil {
+					writeError(w, r, errInvalidToken)
+					return
+				}
+			}
 
-		ctx := context.WithValue(r.Context(), claimsKey, claims)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+			// Bans and role changes take effect immediately, not at token expiry
+			role, banned, err := store.GetUserAccess(r.Context(), claims.UserID)
+			if err != nil {
+				if errors.Is(err, database.ErrNotFound) {
+					writeError(w, r, apierr.ErrUnauthenticated)
+					return
+				}
+				serverError(w, r, "Failed to verify account", err)
+				return
+			}
+			if banned {
+				writeError(w, r, errAccountSuspended)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), claimsKey, claims)
+			ctx = context.WithValue(ctx, roleKey, role)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
 }
 
 func GetClaims(r *http.Request) *auth.Claims {
 	claims, _ := r.Context().Value(claimsKey).(*auth.Claims)
 	return claims
 }
+
+// RequireRole must be mounted after AuthMiddleware.
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if GetRole(r) != role {
+				writeError(w, r, apierr.ErrForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func GetRole(r *http.Request) string {
+	role, _ := r.Context().Value(roleKey).(string)
+	return role
+}
+
+func bearerToken(r *http.Request) string {
+	h := r.Header.Get("Authorization")
+	if len(h) > 7 && strings.EqualFold(h[:7], "Bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	return ""
+}
+
+func hasScopes(granted, required []string) bool {
+	for _, req := range required {
+		found := false
+		for _, g := range granted {
+			if g == req {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"refine-v2/backend/internal/apierr"
+	"refine-v2/backend/internal/auth"
+	"refine-v2/backend/internal/database/memory"
+	"refine-v2/backend/internal/models"
+	"refine-v2/backend/internal/ratelimit"
+	"testing"
+	"time"
+)
+
+// testEnv wires the auth handlers to an in-memory store.
+type testEnv struct {
+	store   *memory.Store
+	lockout *ratelimit.Lockout
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	auth.SetSecret("test secret")
+	t.Cleanup(func() { auth.SetKeyring(nil) })
+	SetSecureCookies(false)
+
+	backend := ratelimit.NewMemory(time.Hour)
+	t.Cleanup(backend.Close)
+	return &testEnv{
+		store:   memory.New(),
+		lockout: ratelimit.NewLockout(backend, "login", 3, time.Minute, time.Hour),
+	}
+}
+
+// do sends body as JSON to h and returns the recorded response.
+func do(t *testing.T, h http.Handler, method string, body any, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, "/", &buf)
+	req.Header.Set("Content-Type", "application/json")
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decode %s: %v", w.Body, err)
+	}
+	return v
+}
+
+func tokenCookie(t *testing.T, w *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "token" && c.Value != "" {
+			return c
+		}
+	}
+	t.Fatalf("no session cookie in %v", w.Header())
+	return nil
+}
+
+func wantError(t *testing.T, w *httptest.ResponseRecorder, status int, code apierr.Code) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("status = %d, want %d: %s", w.Code, status, w.Body)
+	}
+	if got := decode[apierr.Error](t, w); got.Code != code {
+		t.Errorf("code = %q, want %q", got.Code, code)
+	}
+}
+
+func TestAuthFlow(t *testing.T) {
+	env := newTestEnv(t)
+	me := AuthMiddleware(env.store)(GetCurrentUser(env.store))
+
+	w := do(t, Signup(env.store), "POST", models.SignupRequest{Email: " Alice@Example.com", Username: "alice", Password: "password1"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("signup status = %d: %s", w.Code, w.Body)
+	}
+	signup := decode[models.AuthResponse](t, w)
+	if signup.User.Email != "alice@example.com" {
+		t.Errorf("email = %q, want it normalised", signup.User.Email)
+	}
+
+	w = do(t, me, "GET", nil, tokenCookie(t, w))
+	if w.Code != http.StatusOK || decode[models.AuthResponse](t, w).User.ID != signup.User.ID {
+		t.Fatalf("me with the signup cookie = %d: %s", w.Code, w.Body)
+	}
+
+	wantError(t, do(t, me, "GET", nil), http.StatusUnauthorized, apierr.CodeUnauthenticated)
+	wantError(t, do(t, me, "GET", nil, &http.Cookie{Name: "token", Value: "forged"}), http.StatusUnauthorized, apierr.CodeInvalidToken)
+
+	login := Login(env.store, env.lockout)
+	wantError(t, do(t, login, "POST", models.LoginRequest{Email: "alice@example.com", Password: "wrong password"}),
+		http.StatusUnauthorized, apierr.CodeInvalidCredentials)
+	wantError(t, do(t, login, "POST", models.LoginRequest{Email: "bob@example.com", Password: "password1"}),
+		http.StatusUnauthorized, apierr.CodeInvalidCredentials)
+
+	w = do(t, login, "POST", models.LoginRequest{Email: "ALICE@example.com", Password: "password1"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("login status = %d: %s", w.Code, w.Body)
+	}
+	if w := do(t, me, "GET", nil, tokenCookie(t, w)); w.Code != http.StatusOK {
+		t.Errorf("me with the login cookie = %d: %s", w.Code, w.Body)
+	}
+
+	w = do(t, http.HandlerFunc(Logout), "POST", nil)
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "token" && (c.Value != "" || c.MaxAge >= 0) {
+			t.Errorf("logout cookie = %+v, want it cleared", c)
+		}
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	env := newTestEnv(t)
+	if w := do(t, Signup(env.store), "POST", models.SignupRequest{Email: "alice@example.com", Username: "alice", Password: "password1"}); w.Code != http.StatusCreated {
+		t.Fatalf("signup status = %d: %s", w.Code, w.Body)
+	}
+
+	login := Login(env.store, env.lockout)
+	for range env.lockout.Threshold {
+		do(t, login, "POST", models.LoginRequest{Email: "alice@example.com", Password: "wrong password"})
+	}
+	// Locked out even with the right password
+	w := do(t, login, "POST", models.LoginRequest{Email: "alice@example.com", Password: "password1"})
+	wantError(t, w, http.StatusTooManyRequests, apierr.CodeRateLimited)
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("no Retry-After header")
+	}
+}
+
+func TestTwoFactorLogin(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := context.Background()
+	w := do(t, Signup(env.store), "POST", models.SignupRequest{Email: "alice@example.com", Username: "alice", Password: "password1"})
+	userID := decode[models.AuthResponse](t, w).User.ID
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.store.SetPendingTOTP(ctx, userID, secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.store.EnableTOTP(ctx, userID, []string{auth.HashRecoveryCode("abcde-12345")}); err != nil {
+		t.Fatal(err)
+	}
+
+	w = do(t, Login(env.store, env.lockout), "POST", models.LoginRequest{Email: "alice@example.com", Password: "password1"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("login status = %d: %s", w.Code, w.Body)
+	}
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "token" {
+			t.Fatal("session cookie set before the second factor")
+		}
+	}
+	challenge := decode[models.LoginChallengeResponse](t, w)
+	if !challenge.TwoFactorRequired || challenge.ChallengeToken == "" {
+		t.Fatalf("login response = %+v, want a challenge", challenge)
+	}
+
+	// The challenge is not a session
+	me := AuthMiddleware(env.store)(GetCurrentUser(env.store))
+	wantError(t, do(t, me, "GET", nil, &http.Cookie{Name: "token", Value: challenge.ChallengeToken}),
+		http.StatusUnauthorized, apierr.CodeInvalidToken)
+
+	second := LoginTwoFactor(env.store, env.lockout)
+	wantError(t, do(t, second, "POST", models.TwoFactorLoginRequest{ChallengeToken: challenge.ChallengeToken, RecoveryCode: "00000-00000"}),
+		http.StatusUnauthorized, apierr.CodeInvalidCode)
+
+	w = do(t, second, "POST", models.TwoFactorLoginRequest{ChallengeToken: challenge.ChallengeToken, RecoveryCode: "ABCDE-12345"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("second factor status = %d: %s", w.Code, w.Body)
+	}
+	if w := do(t, me, "GET", nil, tokenCookie(t, w)); w.Code != http.StatusOK {
+		t.Errorf("me after 2FA = %d: %s", w.Code, w.Body)
+	}
+
+	// Recovery codes are single use
+	wantError(t, do(t, second, "POST", models.TwoFactorLoginRequest{ChallengeToken: challenge.ChallengeToken, RecoveryCode: "abcde-12345"}),
+		http.StatusUnauthorized, apierr.CodeInvalidCode)
+}
+
+func TestSignupConflicts(t *testing.T) {
+	env := newTestEnv(t)
+	signup := Signup(env.store)
+	if w := do(t, signup, "POST", models.SignupRequest{Email: "alice@example.com", Username: "alice", Password: "password1"}); w.Code != http.StatusCreated {
+		t.Fatalf("signup status = %d: %s", w.Code, w.Body)
+	}
+
+	tests := []struct {
+		name string
+		req  models.SignupRequest
+		code apierr.Code
+	}{
+		{"email", models.SignupRequest{Email: "ALICE@example.com", Username: "alice2", Password: "password1"}, apierr.CodeEmailTaken},
+		{"username", models.SignupRequest{Email: "alice2@example.com", Username: "alice", Password: "password1"}, apierr.CodeUsernameTaken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantError(t, do(t, signup, "POST", tt.req), http.StatusConflict, tt.code)
+		})
+	}
+}
+
+func TestSignupValidation(t *testing.T) {
+	env := newTestEnv(t)
+	tests := []struct {
+		name string
+		req  models.SignupRequest
+	}{
+		{"email", models.SignupRequest{Email: "not an email", Username: "alice", Password: "password1"}},
+		{"username", models.SignupRequest{Email: "alice@example.com", Username: "al", Password: "password1"}},
+		{"password", models.SignupRequest{Email: "alice@example.com", Username: "alice", Password: "short"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(t, Signup(env.store), "POST", tt.req)
+			wantError(t, w, http.StatusBadRequest, apierr.CodeValidationFailed)
+			if got := decode[apierr.Error](t, w); len(got.Details) != 1 || got.Details[0].Field != tt.name {
+				t.Errorf("details = %+v, want the %s field", got.Details, tt.name)
+			}
+		})
+	}
+}
+
+func TestEmailSignupDuplicate(t *testing.T) {
+	env := newTestEnv(t)
+	h := EmailSignup(env.store)
+
+	w := do(t, h, "POST", models.EmailRequest{Email: "alice@example.com"})
+	if w.Code != http.StatusCreated || !decode[models.EmailResponse](t, w).Success {
+		t.Fatalf("first signup = %d: %s", w.Code, w.Body)
+	}
+	// A repeat is answered without revealing more than the list already does
+	w = do(t, h, "POST", models.EmailRequest{Email: " Alice@Example.com "})
+	if w.Code != http.StatusOK || decode[models.EmailResponse](t, w).Success {
+		t.Errorf("repeat signup = %d: %s, want 200 without success", w.Code, w.Body)
+	}
+}
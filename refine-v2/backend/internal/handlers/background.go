@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+)
+
+// Background runs work that outlives the request that started it, such as
+// imports. Its context is cancelled only when a shutdown runs out of time.
+type Background struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewBackground() *Background {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Background{ctx: ctx, cancel: cancel}
+}
+
+// Go runs fn in a new goroutine.
+func (b *Background) Go(fn func(ctx context.Context)) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		fn(b.ctx)
+	}()
+}
+
+// Shutdown waits for running work to finish. If ctx expires first, it cancels
+// the work, waits for it to return and reports ctx's error.
+func (b *Background) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		b.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
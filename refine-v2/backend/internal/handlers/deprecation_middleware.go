@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// legacyDeprecation is when the unversioned routes were deprecated in favour
+// of /api/v1, as an RFC 9745 Deprecation header value (2026-10-19 UTC).
+const legacyDeprecation = "@1792368000"
+
+// Deprecated marks responses from the unversioned routes as deprecated and
+// links to the /api/v1 route that replaces each one.
+func Deprecated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", legacyDeprecation)
+		w.Header().Add("Link", "<"+successorPath(r.URL.Path)+`>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// successorPath maps an unversioned path to its /api/v1 equivalent.
+func successorPath(path string) string {
+	if rest, ok := strings.CutPrefix(path, "/api"); ok {
+		return "/api/v1" + rest
+	}
+	return "/api/v1" + path
+}
@@ -2,42 +2,40 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
 	"net/http"
 	"net/mail"
+	"refine-v2/backend/internal/apierr"
 	"refine-v2/backend/internal/database"
 	"refine-v2/backend/internal/models"
 	"strings"
-
-	"github.com/lib/pq"
 )
 
-func EmailSignup(store *database.Store) http.HandlerFunc {
+func EmailSignup(store database.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req models.EmailRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
-			writeError(w, http.StatusBadRequest, "Invalid body")
+			writeError(w, r, apierr.ErrInvalidBody)
 			return
 		}
 
 		req.Email = strings.TrimSpace(strings.ToLower(req.Email))
 
 		if _, err := mail.ParseAddress(req.Email); err != nil {
-			writeError(w, http.StatusUnprocessableEntity, "Invalid email")
+			writeError(w, r, apierr.Invalid("email", "Invalid email"))
 			return
 		}
 
-		err := store.InsertEmail(req.Email)
+		err := store.InsertEmail(r.Context(), req.Email)
 		if err != nil {
-			if pqErr, ok := err.(*pq.Error); ok && string(pqErr.Code) == "23505" {
+			if errors.Is(err, database.ErrEmailTaken) {
 				writeJSON(w, http.StatusOK, models.EmailResponse{
 					Message: "Email already registered.",
 					Success: false,
 				})
 				return
 			}
-			log.Printf("db insert failed: %v", err)
-			writeError(w, http.StatusInternalServerError, "Failed to save email")
+			serverError(w, r, "Failed to save email", err)
 			return
 		}
 
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+
+	"refine-v2/backend/internal/apierr"
+)
+
+// Errors reported by more than one handler. Single-use errors are built
+// where they are sent.
+var (
+	errInvalidToken       = apierr.New(http.StatusUnauthorized, apierr.CodeInvalidToken, "Invalid or expired token")
+	errInvalidCredentials = apierr.New(http.StatusUnauthorized, apierr.CodeInvalidCredentials, "Invalid email or password")
+	errInvalidCode        = apierr.New(http.StatusUnauthorized, apierr.CodeInvalidCode, "Invalid authentication code")
+	errAccountSuspended   = apierr.New(http.StatusForbidden, apierr.CodeAccountSuspended, "Account suspended")
+	errTwoFactorEnabled   = apierr.New(http.StatusConflict, apierr.CodeTwoFactorEnabled, "Two-factor authentication is already enabled")
+	errUserNotFound       = apierr.New(http.StatusNotFound, apierr.CodeNotFound, "User not found")
+	errInvalidUsername    = apierr.Invalid("username", "Username must be 3-20 characters")
+)
@@ -0,0 +1,392 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"refine-v2/backend/internal/apierr"
+	"refine-v2/backend/internal/database"
+	"refine-v2/backend/internal/generator"
+	"refine-v2/backend/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportPageSize is how many sessions an export reads per query.
+const exportPageSize = 500
+
+// exportPageTimeout is how long an export may take to write each page. Exports
+// run without the handler timeout, and the write deadline is pushed back page
+// by page, so a large history streams in full while a stalled client is still
+// cut off.
+const exportPageTimeout = 30 * time.Second
+
+// ListGameSessions pages through the user's full history, newest first. Pass
+// next_cursor from one page as cursor to fetch the next.
+func ListGameSessions(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := GetClaims(r)
+		if claims == nil {
+			writeError(w, r, apierr.ErrUnauthenticated)
+			return
+		}
+
+		limit, _ := parsePage(r)
+		mode := r.URL.Query().Get("mode")
+		if mode != "" && !validModes[mode] {
+			writeError(w, r, apierr.Invalid("mode", "Invalid mode"))
+			return
+		}
+		var after *models.SessionCursor
+		if c := r.URL.Query().Get("cursor"); c != "" {
+			cursor, err := decodeCursor(c)
+			if err != nil {
+				writeError(w, r, apierr.Invalid("cursor", "Invalid cursor"))
+				return
+			}
+			after = &cursor
+		}
+
+		// One extra row tells whether there is another page
+		sessions, err := store.ListGameSessions(r.Context(), claims.UserID, mode, after, limit+1)
+		if err != nil {
+			serverError(w, r, "Failed to list sessions", err)
+			return
+		}
+
+		page := models.SessionsPage{Sessions: sessions}
+		if len(sessions) > limit {
+			page.Sessions = sessions[:limit]
+			last := page.Sessions[limit-1]
+			page.NextCursor = encodeCursor(models.SessionCursor{PlayedAt: last.PlayedAt, ID: last.ID})
+		}
+		if page.Sessions == nil {
+			page.Sessions = []models.GameSessionRecord{}
+		}
+		writeJSON(w, http.StatusOK, page)
+	}
+}
+
+// encodeCursor makes an opaque cursor from the last session on a page.
+func encodeCursor(c models.SessionCursor) string {
+	raw := strconv.FormatInt(c.PlayedAt.UnixNano(), 10) + "." + strconv.FormatInt(c.ID, 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (models.SessionCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return models.SessionCursor{}, err
+	}
+	nanos, id, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return models.SessionCursor{}, fmt.Errorf("malformed cursor")
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return models.SessionCursor{}, err
+	}
+	c := models.SessionCursor{PlayedAt: time.Unix(0, n).UTC()}
+	if c.ID, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return models.SessionCursor{}, err
+	}
+	return c, nil
+}
+
+// ExportSessions streams every session of the user as JSON (the default) or
+// CSV. CSV has one row per session, or with rows=problems one per problem
+// for the sessions that stored their answers. JSON always nests the problems.
+func ExportSessions(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := GetClaims(r)
+		if claims == nil {
+			writeError(w, r, apierr.ErrUnauthenticated)
+			return
+		}
+
+		q := r.URL.Query()
+		format := q.Get("format")
+		if format == "" {
+			format = "json"
+		}
+		rows := q.Get("rows")
+		if rows == "" {
+			rows = "sessions"
+		}
+		mode := q.Get("mode")
+
+		var details []models.FieldError
+		if format != "json" && format != "csv" {
+			details = append(details, models.FieldError{Field: "format", Message: "Format must be json or csv"})
+		}
+		if rows != "sessions" && rows != "problems" {
+			details = append(details, models.FieldError{Field: "rows", Message: "Rows must be sessions or problems"})
+		}
+		if mode != "" && !validModes[mode] {
+			details = append(details, models.FieldError{Field: "mode", Message: "Invalid mode"})
+		}
+		if len(details) > 0 {
+			writeError(w, r, apierr.Validation(details...))
+			return
+		}
+
+		name := "refine-sessions-" + time.Now().UTC().Format("20060102")
+		if rows == "problems" {
+			name = "refine-problems-" + time.Now().UTC().Format("20060102")
+		}
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, format))
+
+		// Detached from the request deadline; a client that goes away fails
+		// the next write instead
+		ctx := context.WithoutCancel(r.Context())
+		export := sessionExporter{store: store, userID: claims.UserID, mode: mode, rc: http.NewResponseController(w)}
+		var err error
+		switch {
+		case format == "json":
+			w.Header().Set("Content-Type", "application/json")
+			err = export.json(ctx, w)
+		case rows == "problems":
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			err = export.problemsCSV(ctx, w)
+		default:
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			err = export.sessionsCSV(ctx, w)
+		}
+		// The status line has gone out, so a failure can only cut the body short
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Export failed", "error", err)
+		}
+	}
+}
+
+// ExportAccount streams a zip archive of everything stored about the user:
+// profile, API tokens (without secrets) and the full session history.
+func ExportAccount(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := GetClaims(r)
+		if claims == nil {
+			writeError(w, r, apierr.ErrUnauthenticated)
+			return
+		}
+
+		user, err := store.GetUserByID(r.Context(), claims.UserID)
+		if err != nil {
+			serverError(w, r, "Failed to get user", err)
+			return
+		}
+		role, _, err := store.GetUserAccess(r.Context(), claims.UserID)
+		if err != nil {
+			serverError(w, r, "Failed to get user", err)
+			return
+		}
+		tokens, err := store.ListAPITokens(r.Context(), claims.UserID)
+		if err != nil {
+			serverError(w, r, "Failed to list tokens", err)
+			return
+		}
+		if tokens == nil {
+			tokens = []models.APIToken{}
+		}
+		now := time.Now().UTC()
+		profile := models.AccountExport{ExportedAt: now, User: *user, Role: role, APITokens: tokens}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition",
+			fmt.Sprintf(`attachment; filename="refine-account-%s.zip"`, now.Format("20060102")))
+
+		ctx := context.WithoutCancel(r.Context())
+		export := sessionExporter{store: store, userID: claims.UserID, rc: http.NewResponseController(w)}
+		export.extend()
+		zw := zip.NewWriter(w)
+		files := []struct {
+			name  string
+			write func(io.Writer) error
+		}{
+			{"README.txt", func(w io.Writer) error {
+				_, err := io.WriteString(w, accountReadme)
+				return err
+			}},
+			{"profile.json", func(w io.Writer) error {
+				enc := json.NewEncoder(w)
+				enc.SetIndent("", "  ")
+				return enc.Encode(profile)
+			}},
+			{"sessions.json", func(w io.Writer) error { return export.json(ctx, w) }},
+			{"sessions.csv", func(w io.Writer) error { return export.sessionsCSV(ctx, w) }},
+			{"problems.csv", func(w io.Writer) error { return export.problemsCSV(ctx, w) }},
+		}
+		for _, f := range files {
+			fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: now})
+			if err == nil {
+				err = f.write(fw)
+			}
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Account export failed", "file", f.name, "error", err)
+				return
+			}
+		}
+		if err := zw.Close(); err != nil {
+			slog.ErrorContext(r.Context(), "Account export failed", "error", err)
+		}
+	}
+}
+
+const accountReadme = `Refine account export
+
+profile.json   your account, role and personal access tokens (token
+               secrets are never stored, so they are not included)
+sessions.json  every game session, with the individual problems for
+               sessions that stored their answers
+sessions.csv   the same sessions, one row each
+problems.csv   one row per problem, for sessions that stored their answers
+
+Times are in UTC.
+`
+
+// sessionExporter reads a user's history page by page, so exports of any
+// size use bounded memory and each query stays within the store timeout.
+type sessionExporter struct {
+	store  database.Store
+	userID int64
+	mode   string
+	// rc flushes each page to the client and extends the write deadline;
+	// nil when the output is not an HTTP response
+	rc *http.ResponseController
+}
+
+// each calls fn for every session, newest first.
+func (e sessionExporter) each(ctx context.Context, fn func(models.SessionExport) error) error {
+	var after *models.SessionCursor
+	for {
+		e.extend()
+		sessions, err := e.store.ListGameSessions(ctx, e.userID, e.mode, after, exportPageSize)
+		if err != nil {
+			return err
+		}
+		for _, g := range sessions {
+			if err := fn(sessionExport(g)); err != nil {
+				return err
+			}
+		}
+		if e.rc != nil {
+			e.rc.Flush()
+		}
+		if len(sessions) < exportPageSize {
+			return nil
+		}
+		last := sessions[len(sessions)-1]
+		after = &models.SessionCursor{PlayedAt: last.PlayedAt, ID: last.ID}
+	}
+}
+
+// sessionExport regenerates a session's problems from its seed.
+func sessionExport(g models.GameSessionRecord) models.SessionExport {
+	export := models.SessionExport{GameSessionRecord: g}
+	if g.Seed != "" && len(g.Answers) > 0 {
+		var problems []models.Problem
+		if focus, ok := generator.ParseFocusSeed(g.Seed); ok {
+			problems = generator.GenerateFocused(g.Seed, focus, g.Difficulty, len(g.Answers), nil)
+		} else {
+			problems = generator.GenerateWithSeed(g.Seed, g.Mode, g.Difficulty, len(g.Answers), nil)
+		}
+		_, export.Results = markAnswers(problems, g.Answers)
+	}
+	return export
+}
+
+func (e sessionExporter) json(ctx context.Context, w io.Writer) error {
+	if _, err := fmt.Fprintf(w, `{"exported_at":%q,"sessions":[`, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	first := true
+	err := e.each(ctx, func(s models.SessionExport) error {
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+		data, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "]}\n")
+	return err
+}
+
+func (e sessionExporter) sessionsCSV(ctx context.Context, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "played_at", "mode", "difficulty", "time_limit", "score", "correct", "total", "seed", "imported", "practice"})
+	err := e.each(ctx, func(s models.SessionExport) error {
+		cw.Write([]string{
+			strconv.FormatInt(s.ID, 10),
+			s.PlayedAt.UTC().Format(time.RFC3339),
+			s.Mode,
+			strconv.Itoa(s.Difficulty),
+			strconv.Itoa(s.TimeLimit),
+			strconv.Itoa(s.Score),
+			strconv.Itoa(s.Correct),
+			strconv.Itoa(s.Total),
+			s.Seed,
+			strconv.FormatBool(s.Imported),
+			strconv.FormatBool(s.Practice),
+		})
+		cw.Flush()
+		return cw.Error()
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (e sessionExporter) problemsCSV(ctx context.Context, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"session_id", "played_at", "mode", "difficulty", "problem", "num1", "operator", "num2", "answer", "given", "correct"})
+	err := e.each(ctx, func(s models.SessionExport) error {
+		for i, p := range s.Results {
+			cw.Write([]string{
+				strconv.FormatInt(s.ID, 10),
+				s.PlayedAt.UTC().Format(time.RFC3339),
+				s.Mode,
+				strconv.Itoa(s.Difficulty),
+				strconv.Itoa(i + 1),
+				strconv.Itoa(p.Num1),
+				p.Operator,
+				strconv.Itoa(p.Num2),
+				strconv.Itoa(p.Answer),
+				strconv.Itoa(p.Given),
+				strconv.FormatBool(p.Correct),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// extend gives the next page exportPageTimeout to be written. Writers that
+// have no deadline, such as in tests, are left alone.
+func (e sessionExporter) extend() {
+	if e.rc != nil {
+		e.rc.SetWriteDeadline(time.Now().Add(exportPageTimeout))
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"refine-v2/backend/internal/database"
+	"refine-v2/backend/internal/database/migrate"
+	"refine-v2/backend/internal/models"
+	"sync/atomic"
+	"time"
+)
+
+// readyCheckTimeout bounds each readiness check so a hung database fails the
+// probe instead of stalling it.
+const readyCheckTimeout = 2 * time.Second
+
+// draining is set once shutdown starts so load balancers stop routing here
+// while in-flight requests finish.
+var draining atomic.Bool
+
+// SetDraining marks the server as shutting down; /readyz then reports 503.
+func SetDraining(d bool) {
+	draining.Store(d)
+}
+
+// Livez reports that the process is up and serving. It does not touch the
+// database, so a database outage does not get the process restarted.
+func Livez(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz reports whether the server should receive traffic: the database
+// answers and every known migration has been applied. migrator may be nil for
+// stores without a schema.
+func Readyz(store database.Store, migrator *migrate.Migrator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := models.ReadinessResponse{Status: "ready", Checks: map[string]string{}}
+		fail := func(check, msg string) {
+			resp.Status = "not ready"
+			resp.Checks[check] = msg
+		}
+
+		if draining.Load() {
+			fail("shutdown", "draining")
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), readyCheckTimeout)
+		defer cancel()
+
+		if err := store.Ping(ctx); err != nil {
+			slog.WarnContext(ctx, "readiness: database unreachable", "error", err)
+			fail("database", "unreachable")
+		} else {
+			resp.Checks["database"] = "ok"
+		}
+
+		if migrator != nil {
+			pending, err := migrator.Pending(ctx)
+			switch {
+			case err != nil:
+				slog.WarnContext(ctx, "readiness: migration status failed", "error", err)
+				fail("migrations", "unknown")
+			case pending > 0:
+				fail("migrations", fmt.Sprintf("%d pending", pending))
+			default:
+				resp.Checks["migrations"] = "ok"
+			}
+		}
+
+		status := http.StatusOK
+		if resp.Status != "ready" {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, status, resp)
+	}
+}
+
+// Version reports the running build.
+func Version(info models.BuildInfo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, info)
+	}
+}
@@ -2,7 +2,16 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"log/slog"
+	"mime"
 	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"refine-v2/backend/internal/apierr"
+	"refine-v2/backend/internal/models"
 )
 
 func writeJSON(w http.ResponseWriter, status int, v any) {
@@ -11,6 +20,65 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 	json.NewEncoder(w).Encode(v)
 }
 
-func writeError(w http.ResponseWriter, status int, msg string) {
-	writeJSON(w, status, map[string]string{"error": msg})
+// writeError sends err as an error response. Errors that are not an
+// *apierr.Error are treated as internal; use serverError for those so the
+// cause is logged. Clients that accept application/problem+json get an RFC
+// 7807 document, everyone else the ErrorResponse envelope.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var e *apierr.Error
+	if !errors.As(err, &e) {
+		e = apierr.Internal("Internal server error")
+	}
+	requestID := middleware.GetReqID(r.Context())
+
+	if acceptsProblem(r) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(e.Status)
+		json.NewEncoder(w).Encode(models.ProblemDetails{
+			Type:      "urn:refine:error:" + string(e.Code),
+			Title:     http.StatusText(e.Status),
+			Status:    e.Status,
+			Detail:    e.Message,
+			Instance:  r.URL.Path,
+			Code:      string(e.Code),
+			RequestID: requestID,
+			Errors:    e.Details,
+		})
+		return
+	}
+
+	writeJSON(w, e.Status, models.ErrorResponse{
+		Code:      string(e.Code),
+		Message:   e.Message,
+		Details:   e.Details,
+		RequestID: requestID,
+		Error:     e.Message,
+	})
+}
+
+// acceptsProblem reports whether the Accept header lists problem+json.
+func acceptsProblem(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		if t, _, err := mime.ParseMediaType(strings.TrimSpace(part)); err == nil && t == "application/problem+json" {
+			return true
+		}
+	}
+	return false
+}
+
+// serverError logs the underlying cause of a 500 against the request and
+// sends the client only the generic message.
+func serverError(w http.ResponseWriter, r *http.Request, msg string, err error) {
+	slog.ErrorContext(r.Context(), msg, "error", err, "method", r.Method, "path", r.URL.Path)
+	writeError(w, r, apierr.Internal(msg))
+}
+
+// NotFound and MethodNotAllowed replace the router's plain text responses so
+// every error has the same shape.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, apierr.New(http.StatusNotFound, apierr.CodeNotFound, "Not found"))
+}
+
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, apierr.New(http.StatusMethodNotAllowed, apierr.CodeMethodNotAllowed, "Method not allowed"))
 }
@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"refine-v2/backend/internal/apierr"
+	"refine-v2/backend/internal/database"
+	"refine-v2/backend/internal/models"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxImportBytes  = 5 << 20
+	maxImportRows   = 10000
+	importBatchSize = 100
+)
+
+// importColumns are the fields of an import row. score is optional and
+// defaults to 10 points per correct answer, as the server scores games.
+var importColumns = []string{"played_at", "mode", "difficulty", "time_limit", "score", "correct", "total"}
+
+// importTimeLayouts are accepted for played_at. Times without a zone are UTC.
+var importTimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// CreateImport starts importing session history from another practice tool.
+// The body is CSV with a header row naming the importColumns, in any order,
+// or JSON shaped like the export: {"sessions": [{...}, ...]}. Unknown
+// columns are ignored, so a Refine export can be imported as is.
+//
+// The file is checked up front and then saved in the background; the
+// response is the job to poll with GetImport. A user runs one import at a
+// time, so another is refused until the current job finishes.
+func CreateImport(store database.Store, background *Background) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := GetClaims(r)
+		if claims == nil {
+			writeError(w, r, apierr.ErrUnauthenticated)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = importFormat(r.Header.Get("Content-Type"))
+		}
+		if format != "csv" && format != "json" {
+			writeError(w, r, apierr.Invalid("format", "Send text/csv or application/json, or set format to csv or json"))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBytes))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, r, apierr.New(http.StatusRequestEntityTooLarge, apierr.CodeTooLarge,
+				fmt.Sprintf("Imports are limited to %d MB", maxImportBytes>>20)))
+			return
+		}
+		if err != nil {
+			writeError(w, r, apierr.ErrInvalidBody)
+			return
+		}
+
+		var records []map[string]string
+		if format == "csv" {
+			records, err = readImportCSV(body)
+		} else {
+			records, err = readImportJSON(body)
+		}
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if len(records) == 0 {
+			writeError(w, r, apierr.Invalid("file", "The file has no sessions"))
+			return
+		}
+
+		job := &models.ImportJob{
+			Status:    models.ImportPending,
+			Format:    format,
+			TotalRows: len(records),
+			Rows:      []models.ImportRowResult{},
+		}
+		var sessions []models.ImportedSession
+		now := time.Now()
+		for i, rec := range records {
+			session, details := checkImportRow(rec, now)
+			if len(details) > 0 {
+				job.Rows = append(job.Rows, models.ImportRowResult{Row: i + 1, Status: models.RowInvalid, Errors: details})
+				continue
+			}
+			session.Row = i + 1
+			sessions = append(sessions, session)
+		}
+		job.Processed, job.Invalid = len(job.Rows), len(job.Rows)
+		// Nothing to do in the background when every row is invalid
+		if len(sessions) == 0 {
+			job.Status = models.ImportCompleted
+			job.FinishedAt = &now
+		}
+
+		if err := store.CreateImportJob(r.Context(), claims.UserID, job); err != nil {
+			if errors.Is(err, database.ErrImportActive) {
+				writeError(w, r, err)
+				return
+			}
+			serverError(w, r, "Failed to start import", err)
+			return
+		}
+		if len(sessions) > 0 {
+			userID, started := claims.UserID, *job
+			started.Rows = slices.Clone(job.Rows)
+			background.Go(func(ctx context.Context) {
+				runImport(ctx, store, userID, &started, sessions)
+			})
+		}
+
+		w.Header().Set("Location", fmt.Sprintf("%s/%d", strings.TrimSuffix(r.URL.Path, "/"), job.ID))
+		writeJSON(w, http.StatusAccepted, job)
+	}
+}
+
+// GetImport reports an import job's progress and, once rows are processed,
+// the outcome of each.
+func GetImport(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := GetClaims(r)
+		if claims == nil {
+			writeError(w, r, apierr.ErrUnauthenticated)
+			return
+		}
+
+		jobID, ok := parseIDParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		job, err := store.GetImportJob(r.Context(), claims.UserID, jobID)
+		if errors.Is(err, database.ErrNotFound) {
+			writeError(w, r, apierr.New(http.StatusNotFound, apierr.CodeNotFound, "Import not found"))
+			return
+		}
+		if err != nil {
+			serverError(w, r, "Failed to get import", err)
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	}
+}
+
+// runImport saves the sessions of a job in batches, recording progress after
+// each. Sessions already saved stay saved if it fails part way, and since
+// duplicates are skipped the same file can simply be imported again.
+func runImport(ctx context.Context, store database.Store, userID int64, job *models.ImportJob, sessions []models.ImportedSession) {
+	// Record the outcome even when cancelled by shutdown
+	update := func() {
+		if err := store.UpdateImportJob(context.WithoutCancel(ctx), job); err != nil {
+			slog.ErrorContext(ctx, "Failed to update import", "import_id", job.ID, "error", err)
+		}
+	}
+	finish := func(status, msg string) {
+		now := time.Now()
+		job.Status, job.Error, job.FinishedAt = status, msg, &now
+		sort.Slice(job.Rows, func(i, j int) bool { return job.Rows[i].Row < job.Rows[j].Row })
+		update()
+		slog.InfoContext(ctx, "Import finished", "import_id", job.ID, "user_id", userID, "status", status,
+			"imported", job.Imported, "duplicates", job.Duplicates, "invalid", job.Invalid)
+	}
+
+	job.Status = models.ImportRunning
+	update()
+	for start := 0; start < len(sessions); start += importBatchSize {
+		batch := sessions[start:min(start+importBatchSize, len(sessions))]
+		ids, err := store.ImportGameSessions(ctx, userID, batch)
+		if err != nil {
+			slog.ErrorContext(ctx, "Import failed", "import_id", job.ID, "error", err)
+			finish(models.ImportFailed, "Import stopped before all rows were saved; importing the file again skips rows already saved")
+			return
+		}
+		for i, id := range ids {
+			row := models.ImportRowResult{Row: batch[i].Row, Status: models.RowImported, SessionID: id}
+			if id == 0 {
+				row.Status = models.RowDuplicate
+				job.Duplicates++
+			} else {
+				job.Imported++
+			}
+			job.Rows = append(job.Rows, row)
+		}
+		job.Processed += len(batch)
+		if job.Processed < job.TotalRows {
+			update()
+		}
+	}
+	finish(models.ImportCompleted, "")
+}
+
+// importFormat maps a Content-Type to an import format, or "".
+func importFormat(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return "csv"
+	case "application/json":
+		return "json"
+	}
+	return ""
+}
+
+// readImportCSV returns each data row keyed by its lower-cased header.
+func readImportCSV(body []byte) ([]map[string]string, error) {
+	cr := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))))
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, apierr.Invalid("file", "The file is empty")
+	}
+	if err != nil {
+		return nil, apierr.Invalid("file", "Invalid CSV: "+err.Error())
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	var missing []string
+	for _, name := range importColumns {
+		if _, ok := columns[name]; !ok && name != "score" {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, apierr.Invalid("file", "Missing columns: "+strings.Join(missing, ", "))
+	}
+
+	var records []map[string]string
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, apierr.Invalid("file", "Invalid CSV: "+err.Error())
+		}
+		if len(records) == maxImportRows {
+			return nil, tooManyImportRows()
+		}
+		rec := map[string]string{}
+		for _, name := range importColumns {
+			if i, ok := columns[name]; ok && i < len(row) {
+				rec[name] = strings.TrimSpace(row[i])
+			}
+		}
+		records = append(records, rec)
+	}
+}
+
+// readImportJSON returns each session object with its fields as text, so
+// both formats are validated the same way.
+func readImportJSON(body []byte) ([]map[string]string, error) {
+	var file struct {
+		Sessions []map[string]json.RawMessage `json:"sessions"`
+	}
+	if err := json.Unmarshal(body, &file); err != nil {
+		return nil, apierr.Invalid("file", `Expected {"sessions": [...]} with an object per session`)
+	}
+	if len(file.Sessions) > maxImportRows {
+		return nil, tooManyImportRows()
+	}
+
+	records := make([]map[string]string, len(file.Sessions))
+	for i, obj := range file.Sessions {
+		rec := map[string]string{}
+		for _, name := range importColumns {
+			raw, ok := obj[name]
+			if !ok || string(raw) == "null" {
+				continue
+			}
+			var s string
+			if json.Unmarshal(raw, &s) != nil {
+				s = string(raw)
+			}
+			rec[name] = strings.TrimSpace(s)
+		}
+		records[i] = rec
+	}
+	return records, nil
+}
+
+func tooManyImportRows() error {
+	return apierr.Invalid("file", fmt.Sprintf("Imports are limited to %d rows; split the file", maxImportRows))
+}
+
+// checkImportRow validates one row.
+func checkImportRow(rec map[string]string, now time.Time) (models.ImportedSession, []models.FieldError) {
+	var details []models.FieldError
+	invalid := func(field, msg string) {
+		details = append(details, models.FieldError{Field: field, Message: msg})
+	}
+	// number reports whether the field is a whole number of at least min
+	number := func(field string, min int) (int, bool) {
+		s := rec[field]
+		if s == "" {
+			invalid(field, "Missing "+field)
+			return 0, false
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			invalid(field, "Must be a whole number")
+			return 0, false
+		}
+		if n < min {
+			invalid(field, fmt.Sprintf("Must be at least %d", min))
+			return 0, false
+		}
+		return n, true
+	}
+
+	var session models.ImportedSession
+	session.Mode = strings.ToLower(rec["mode"])
+	if !validModes[session.Mode] {
+		invalid("mode", "Invalid mode")
+	}
+	var ok bool
+	if session.Difficulty, ok = number("difficulty", 1); ok && session.Difficulty > 3 {
+		invalid("difficulty", "Difficulty must be 1, 2, or 3")
+	}
+	session.TimeLimit, _ = number("time_limit", 1)
+	correct, correctOK := number("correct", 0)
+	total, totalOK := number("total", 0)
+	if correctOK && totalOK && correct > total {
+		invalid("correct", "Correct must not exceed total")
+	}
+	session.Correct, session.Total = correct, total
+	session.Score = correct * 10
+	if rec["score"] != "" {
+		session.Score, _ = number("score", 0)
+	}
+
+	switch playedAt, ok := parseImportTime(rec["played_at"]); {
+	case rec["played_at"] == "":
+		invalid("played_at", "Missing played_at")
+	case !ok:
+		invalid("played_at", "Use an RFC 3339 time or YYYY-MM-DD")
+	case playedAt.After(now):
+		invalid("played_at", "Must not be in the future")
+	default:
+		session.PlayedAt = playedAt
+	}
+	return session, details
+}
+
+func parseImportTime(s string) (time.Time, bool) {
+	for _, layout := range importTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
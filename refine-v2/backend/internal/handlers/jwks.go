@@ -0,0 +1,13 @@
+package handlers
+
+import (
+	"net/http"
+	"refine-v2/backend/internal/auth"
+)
+
+// JWKS publishes the public token verification keys so other services can
+// verify Refine tokens.
+func JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	writeJSON(w, http.StatusOK, auth.PublicJWKS())
+}
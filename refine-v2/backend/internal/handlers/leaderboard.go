@@ -2,16 +2,23 @@ package handlers
 
 import (
 	"net/http"
+	"refine-v2/backend/internal/apierr"
 	"refine-v2/backend/internal/database"
 	"refine-v2/backend/internal/models"
 	"strconv"
 )
 
-func GetLeaderboard(store *database.Store) http.HandlerFunc {
+var validPeriods = map[string]bool{
+	models.PeriodAll:  true,
+	models.PeriodWeek: true,
+	models.PeriodDay:  true,
+}
+
+func GetLeaderboard(store database.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		claims := GetClaims(r)
 		if claims == nil {
-			writeError(w, http.StatusUnauthorized, "Not authenticated")
+			writeError(w, r, apierr.ErrUnauthenticated)
 			return
 		}
 
@@ -20,31 +27,40 @@ func GetLeaderboard(store *database.Store) http.HandlerFunc {
 		timeLimitStr := r.URL.Query().Get("time_limit")
 
 		if !validModes[mode] {
-			writeError(w, http.StatusBadRequest, "Invalid mode")
+			writeError(w, r, apierr.Invalid("mode", "Invalid mode"))
 			return
 		}
 
 		difficulty, err := strconv.Atoi(diffStr)
 		if err != nil || difficulty < 1 || difficulty > 3 {
-			writeError(w, http.StatusBadRequest, "Difficulty must be 1, 2, or 3")
+			writeError(w, r, apierr.Invalid("difficulty", "Difficulty must be 1, 2, or 3"))
 			return
 		}
 
 		timeLimit, err := strconv.Atoi(timeLimitStr)
 		if err != nil || timeLimit <= 0 {
-			writeError(w, http.StatusBadRequest, "Invalid time_limit")
+			writeError(w, r, apierr.Invalid("time_limit", "Invalid time_limit"))
+			return
+		}
+
+		period := r.URL.Query().Get("period")
+		if period == "" {
+			period = models.PeriodAll
+		}
+		if !validPeriods[period] {
+			writeError(w, r, apierr.Invalid("period", "Period must be all, week, or day"))
 			return
 		}
 
-		global, err := store.GetGlobalLeaderboard(mode, difficulty, timeLimit)
+		global, err := store.GetGlobalLeaderboard(r.Context(), mode, difficulty, timeLimit, period)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "Failed to get leaderboard")
+			serverError(w, r, "Failed to get leaderboard", err)
 			return
 		}
 
-		personal, err := store.GetPersonalLeaderboard(claims.UserID, mode, difficulty, timeLimit)
+		personal, err := store.GetPersonalLeaderboard(r.Context(), claims.UserID, mode, difficulty, timeLimit)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "Failed to get personal scores")
+			serverError(w, r, "Failed to get personal scores", err)
 			return
 		}
 
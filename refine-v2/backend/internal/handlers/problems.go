@@ -1,24 +1,146 @@
 package handlers
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"refine-v2/backend/internal/apierr"
+	"refine-v2/backend/internal/cache"
 	"refine-v2/backend/internal/generator"
+	"refine-v2/backend/internal/metrics"
 	"refine-v2/backend/internal/models"
+	"refine-v2/backend/internal/tracing"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// problemSets caches generated problem sets so ValidateAnswers does not have
+// to regenerate them. Nil disables caching.
+var problemSets *cache.Loader[[]models.Problem]
+
+// SetProblemCache caches generated problem sets in c for ttl.
+func SetProblemCache(c cache.Cache, ttl time.Duration) {
+	problemSets = cache.NewLoader[[]models.Problem](c, "problem_sets", ttl)
+}
+
+// Problem set sizes for GenerateProblems.
+var (
+	defaultProblemCount = 50
+	maxProblemCount     = 200
+)
+
+// SetProblemLimits sets how many problems GenerateProblems returns when the
+// request gives no count, and the most it returns for any request.
+func SetProblemLimits(defaultCount, maxCount int) {
+	defaultProblemCount = defaultCount
+	maxProblemCount = maxCount
+}
+
+// seedKey signs the seeds of generated sets, so that SaveGameSessionV2 only
+// scores sets the server issued.
+var seedKey []byte
+
+// SetSeedKey sets the key that signs problem set seeds.
+func SetSeedKey(key []byte) {
+	seedKey = key
+}
+
+// signSeed appends a signature binding seed to the settings it was generated
+// with.
+func signSeed(seed, mode string, difficulty int, config *models.CustomConfig) string {
+	return seed + "." + seedSignature(seed, mode, difficulty, config)
+}
+
+// verifySeed reports whether seed came from signSeed with these settings.
+func verifySeed(seed, mode string, difficulty int, config *models.CustomConfig) bool {
+	i := strings.LastIndexByte(seed, '.')
+	if i < 0 {
+		return false
+	}
+	want := seedSignature(seed[:i], mode, difficulty, config)
+	return hmac.Equal([]byte(seed[i+1:]), []byte(want))
+}
+
+func seedSignature(seed, mode string, difficulty int, config *models.CustomConfig) string {
+	var min, max int
+	if config != nil {
+		min, max = config.Min, config.Max
+	}
+	mac := hmac.New(sha256.New, seedKey)
+	fmt.Fprintf(mac, "%s\x00%d\x00%d\x00%d\x00%s", mode, difficulty, min, max, seed)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
 func GenerateProblems(w http.ResponseWriter, r *http.Request) {
 	var req models.GenerateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid request body")
+		writeError(w, r, apierr.ErrInvalidBody)
 		return
 	}
+	if req.Strict {
+		if details := checkGenerateRequest(req); len(details) > 0 {
+			writeError(w, r, apierr.Validation(details...))
+			return
+		}
+	}
+
+	req = withProblemDefaults(req)
+	seed, questions := newProblemSet(r.Context(), req)
+	writeJSON(w, http.StatusOK, models.GenerateResponse{
+		Seed:     seed,
+		Problems: questions,
+	})
+}
+
+// GenerateProblemsV2 always validates strictly and echoes the settings used.
+func GenerateProblemsV2(w http.ResponseWriter, r *http.Request) {
+	var req models.GenerateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, apierr.ErrInvalidBody)
+		return
+	}
+	if details := checkGenerateRequest(req); len(details) > 0 {
+		writeError(w, r, apierr.Validation(details...))
+		return
+	}
+
+	req = withProblemDefaults(req)
+	seed, questions := newProblemSet(r.Context(), req)
+	writeJSON(w, http.StatusOK, models.GenerateResponseV2{
+		Seed:       seed,
+		Mode:       req.Mode,
+		Difficulty: req.Difficulty,
+		Config:     req.Config,
+		Focus:      req.Focus,
+		Problems:   questions,
+	})
+}
 
+// withProblemDefaults fills in omitted settings and clamps out of range ones.
+// Unknown focus targets are dropped, and a focused set takes the mode of its
+// targets.
+func withProblemDefaults(req models.GenerateRequest) models.GenerateRequest {
+	if len(req.Focus) > 0 {
+		targets := focusTargets(req.Focus)
+		req.Focus = nil
+		for _, t := range targets {
+			req.Focus = append(req.Focus, t.String())
+		}
+		if len(targets) > 0 {
+			req.Mode = generator.FocusMode(targets)
+		}
+	}
 	if req.Count <= 0 {
-		req.Count = 50
+		req.Count = defaultProblemCount
 	}
-	if req.Count > 200 {
-		req.Count = 200
+	if req.Count > maxProblemCount {
+		req.Count = maxProblemCount
 	}
 	if req.Difficulty <= 0 || req.Difficulty > 4 {
 		req.Difficulty = 1
@@ -26,13 +148,115 @@ func GenerateProblems(w http.ResponseWriter, r *http.Request) {
 	if req.Mode == "" {
 		req.Mode = "addition"
 	}
+	return req
+}
 
+// newProblemSet generates a problem set under a fresh, signed seed and caches
+// it for validation.
+func newProblemSet(ctx context.Context, req models.GenerateRequest) (string, []models.Question) {
 	seed := generator.CreateSeed()
-	problems := generator.GenerateWithSeed(seed, req.Mode, req.Difficulty, req.Count, req.Config)
-	questions := generator.ConvertToQuestions(problems)
+	focus := focusTargets(req.Focus)
+	if len(focus) > 0 {
+		seed = generator.FocusSeed(focus, seed)
+	}
+	seed = signSeed(seed, req.Mode, req.Difficulty, req.Config)
+	problems := generate(ctx, seed, focus, req.Mode, req.Difficulty, req.Count, req.Config)
+	if problemSets != nil {
+		problemSets.Set(ctx, problemSetKey(seed, req.Mode, req.Difficulty, req.Config), problems)
+	}
+	metrics.ProblemsGenerated.WithLabelValues(modeLabel(req.Mode)).Add(float64(len(problems)))
+	return seed, generator.ConvertToQuestions(problems)
+}
 
-	writeJSON(w, http.StatusOK, models.GenerateResponse{
-		Seed:     seed,
-		Problems: questions,
-	})
+// checkGenerateRequest lists the values GenerateProblems would otherwise
+// replace with defaults. Omitted fields are still defaulted.
+func checkGenerateRequest(req models.GenerateRequest) []models.FieldError {
+	var details []models.FieldError
+	invalid := func(field, msg string) {
+		details = append(details, models.FieldError{Field: field, Message: msg})
+	}
+
+	if req.Count < 0 || req.Count > maxProblemCount {
+		invalid("count", fmt.Sprintf("Count must be 1-%d", maxProblemCount))
+	}
+	if req.Difficulty < 0 || req.Difficulty > 4 {
+		invalid("difficulty", "Difficulty must be 1, 2, 3, or 4")
+	}
+	if req.Mode != "" && !validModes[req.Mode] {
+		invalid("mode", "Invalid mode")
+	}
+	if len(req.Focus) > generator.MaxFocusTargets {
+		invalid("focus", fmt.Sprintf("Focus on at most %d targets", generator.MaxFocusTargets))
+	}
+	for _, key := range req.Focus {
+		if _, err := generator.ParseTarget(key); err != nil {
+			invalid("focus", fmt.Sprintf("Invalid target %q: %v", key, err))
+		}
+	}
+	if msg := checkConfig(req.Config, req.Difficulty); msg != "" {
+		invalid("config", msg)
+	}
+	return details
+}
+
+// checkConfig describes what is wrong with a custom range for difficulty, or
+// returns "".
+func checkConfig(config *models.CustomConfig, difficulty int) string {
+	custom := config != nil && config.Min > 0 && config.Max > 0
+	switch {
+	case config != nil && !custom:
+		return "Custom range min and max must be positive"
+	case custom && config.Min > config.Max:
+		return "Custom range min must not exceed max"
+	case difficulty == 4 && !custom:
+		return "Difficulty 4 needs a custom range"
+	}
+	return ""
+}
+
+// focusTargets parses the valid keys, up to the most a set can aim at.
+func focusTargets(keys []string) []generator.Target {
+	var targets []generator.Target
+	for _, key := range keys {
+		if t, err := generator.ParseTarget(key); err == nil && len(targets) < generator.MaxFocusTargets {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// problemSet returns the first count problems for seed, from the cache when a
+// long enough set was generated earlier. Generation is sequential, so a
+// shorter set is always a prefix of a longer one from the same seed.
+func problemSet(ctx context.Context, seed string, focus []generator.Target, mode string, difficulty, count int, config *models.CustomConfig) []models.Problem {
+	if problemSets != nil {
+		cached, ok := problemSets.Lookup(ctx, problemSetKey(seed, mode, difficulty, config))
+		if ok && len(cached) >= count {
+			return cached[:count]
+		}
+	}
+	return generate(ctx, seed, focus, mode, difficulty, count, config)
+}
+
+// generate runs the generator under a span. A set with focus targets is aimed
+// at them instead of mode.
+func generate(ctx context.Context, seed string, focus []generator.Target, mode string, difficulty, count int, config *models.CustomConfig) []models.Problem {
+	_, span := tracing.Start(ctx, "generate problems",
+		attribute.String("problems.mode", mode),
+		attribute.Int("problems.difficulty", difficulty),
+		attribute.Int("problems.count", count),
+	)
+	defer span.End()
+	if len(focus) > 0 {
+		return generator.GenerateFocused(seed, focus, difficulty, count, config)
+	}
+	return generator.GenerateWithSeed(seed, mode, difficulty, count, config)
+}
+
+func problemSetKey(seed, mode string, difficulty int, config *models.CustomConfig) string {
+	var min, max int
+	if config != nil {
+		min, max = config.Min, config.Max
+	}
+	return fmt.Sprintf("problems:%s:%d:%d:%d:%s", mode, difficulty, min, max, seed)
 }
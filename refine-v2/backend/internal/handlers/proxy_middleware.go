@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// TrustedProxies sets RemoteAddr to the client address from X-Forwarded-For,
+// but only for requests that arrive from one of the trusted proxies. The
+// header is read right to left, skipping hops that are themselves trusted
+// proxies, so the client is the address the outermost trusted proxy saw and
+// anything the client put in the header itself is ignored. Requests from
+// anywhere else, or without a usable header, keep their RemoteAddr.
+func TrustedProxies(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	isTrusted := func(addr netip.Addr) bool {
+		addr = addr.Unmap()
+		return slices.ContainsFunc(trusted, func(p netip.Prefix) bool { return p.Contains(addr) })
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if peer, ok := remoteAddr(r); ok && isTrusted(peer) {
+				if client, ok := forwardedFor(r.Header.Values("X-Forwarded-For"), isTrusted); ok {
+					r.RemoteAddr = net.JoinHostPort(client.String(), "0")
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func remoteAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	return addr, err == nil
+}
+
+// forwardedFor returns the right-most X-Forwarded-For hop that isn't a
+// trusted proxy. A malformed hop stops the walk: whatever is left of it was
+// not appended by a trusted proxy.
+func forwardedFor(values []string, isTrusted func(netip.Addr) bool) (netip.Addr, bool) {
+	hops := strings.Split(strings.Join(values, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		if addr = addr.Unmap(); !isTrusted(addr) {
+			return addr, true
+		}
+	}
+	return netip.Addr{}, false
+}
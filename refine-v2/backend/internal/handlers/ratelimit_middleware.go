@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"refine-v2/backend/internal/apierr"
+	"refine-v2/backend/internal/ratelimit"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateKeyFunc extracts the key a policy is applied to. An empty key skips the check.
+type RateKeyFunc func(r *http.Request) string
+
+// RateLimit rejects requests with 429 once the bucket for the request's key is empty.
+func RateLimit(limiter *ratelimit.Limiter, policy ratelimit.Policy, keyFn RateKeyFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := keyFn(r)
+			if key != "" {
+				if ok, retryAfter := limiter.Allow(policy, key); !ok {
+					writeTooManyRequests(w, r, retryAfter)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// KeyByIP keys on the client address. main installs TrustedProxies so behind
+// Caddy this is the proxied client rather than Caddy.
+func KeyByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// KeyByEmail keys on the "email" field of a JSON body, leaving the body
+// intact for the handler.
+func KeyByEmail(r *http.Request) string {
+	return normalizeEmail(peekJSONField(r, "email"))
+}
+
+func peekJSONField(r *http.Request, field string) string {
+	if r.Body == nil {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return ""
+	}
+	var value string
+	json.Unmarshal(fields[field], &value)
+	return value
+}
+
+func normalizeEmail(email string) string {
+	return strings.TrimSpace(strings.ToLower(email))
+}
+
+func writeTooManyRequests(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	secs := int(math.Ceil(retryAfter.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	writeError(w, r, apierr.ErrRateLimited)
+}
@@ -2,11 +2,20 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"refine-v2/backend/internal/apierr"
 	"refine-v2/backend/internal/database"
+	"refine-v2/backend/internal/generator"
+	"refine-v2/backend/internal/metrics"
 	"refine-v2/backend/internal/models"
 )
 
+// maxAnswersPerSecond bounds how many answers a saved game can have for its
+// time limit, well above what anyone types.
+const maxAnswersPerSecond = 5
+
 var validModes = map[string]bool{
 	"addition":       true,
 	"subtraction":    true,
@@ -15,34 +24,133 @@ var validModes = map[string]bool{
 	"mixed":          true,
 }
 
-func SaveGameSession(store *database.Store) http.HandlerFunc {
+func SaveGameSession(store database.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		claims := GetClaims(r)
 		if claims == nil {
-			writeError(w, http.StatusUnauthorized, "Not authenticated")
+			writeError(w, r, apierr.ErrUnauthenticated)
 			return
 		}
 
 		var req models.SaveSessionRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			writeError(w, http.StatusBadRequest, "Invalid request body")
+			writeError(w, r, apierr.ErrInvalidBody)
 			return
 		}
 
 		if !validModes[req.Mode] {
-			writeError(w, http.StatusBadRequest, "Invalid mode")
+			writeError(w, r, apierr.Invalid("mode", "Invalid mode"))
 			return
 		}
 		if req.Difficulty < 1 || req.Difficulty > 3 {
-			writeError(w, http.StatusBadRequest, "Difficulty must be 1, 2, or 3")
+			writeError(w, r, apierr.Invalid("difficulty", "Difficulty must be 1, 2, or 3"))
 			return
 		}
 
-		if err := store.SaveGameSession(claims.UserID, req); err != nil {
-			writeError(w, http.StatusInternalServerError, "Failed to save session")
+		if err := store.SaveGameSession(r.Context(), claims.UserID, req); err != nil {
+			serverError(w, r, "Failed to save session", err)
 			return
 		}
+		metrics.SessionsSaved.WithLabelValues(req.Mode).Inc()
 
 		writeJSON(w, http.StatusCreated, map[string]string{"message": "Session saved"})
 	}
 }
+
+// SaveGameSessionV2 scores the game from its seed and answers before saving
+// it, so the recorded score cannot be made up by the client. Only seeds the
+// server issued for the game's mode, difficulty and custom range are
+// accepted, each once.
+func SaveGameSessionV2(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := GetClaims(r)
+		if claims == nil {
+			writeError(w, r, apierr.ErrUnauthenticated)
+			return
+		}
+
+		var req models.SaveSessionRequestV2
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, apierr.ErrInvalidBody)
+			return
+		}
+
+		var details []models.FieldError
+		invalid := func(field, msg string) {
+			details = append(details, models.FieldError{Field: field, Message: msg})
+		}
+		if req.Seed == "" {
+			invalid("seed", "Missing seed")
+		}
+		if !validModes[req.Mode] {
+			invalid("mode", "Invalid mode")
+		}
+		if req.Difficulty < 1 || req.Difficulty > 4 {
+			invalid("difficulty", "Difficulty must be 1, 2, 3, or 4")
+		} else if msg := checkConfig(req.Config, req.Difficulty); msg != "" {
+			invalid("config", msg)
+		}
+		if req.TimeLimit <= 0 {
+			invalid("time_limit", "Invalid time_limit")
+		}
+		switch {
+		case len(req.Answers) == 0:
+			invalid("answers", "No answers provided")
+		case len(req.Answers) > maxProblemCount:
+			invalid("answers", fmt.Sprintf("At most %d answers", maxProblemCount))
+		case req.TimeLimit > 0 && len(req.Answers) > maxAnswersPerSecond*req.TimeLimit:
+			invalid("answers", "Too many answers for the time limit")
+		}
+		if req.Seed != "" && validModes[req.Mode] && !verifySeed(req.Seed, req.Mode, req.Difficulty, req.Config) {
+			invalid("seed", "Seed was not issued for this mode, difficulty and range")
+		}
+		// Focused sets and custom ranges are practice: they are scored like
+		// any other set but kept off the global leaderboards
+		focus, focused := generator.ParseFocusSeed(req.Seed)
+		practice := focused || req.Config != nil
+		if mode := generator.FocusMode(focus); focused && req.Mode != mode {
+			invalid("mode", "This focused set was generated as "+mode)
+		}
+		if len(details) > 0 {
+			writeError(w, r, apierr.Validation(details...))
+			return
+		}
+
+		result := scoreAnswers(r.Context(), models.ValidateRequest{
+			Seed:       req.Seed,
+			Mode:       req.Mode,
+			Difficulty: req.Difficulty,
+			Config:     req.Config,
+			Answers:    req.Answers,
+		}, focus)
+		// The range is not stored, so the problems of a custom set cannot be
+		// regenerated from its seed later; keep only the seed, which still
+		// uses it up
+		answers := req.Answers
+		if req.Config != nil {
+			answers = nil
+		}
+		session := models.SaveSessionRequest{
+			Mode:       req.Mode,
+			Difficulty: req.Difficulty,
+			Score:      result.Score,
+			Correct:    result.Correct,
+			Total:      result.Total,
+			TimeLimit:  req.TimeLimit,
+			Seed:       req.Seed,
+			Answers:    answers,
+			Practice:   practice,
+		}
+		if err := store.SaveGameSession(r.Context(), claims.UserID, session); err != nil {
+			if errors.Is(err, database.ErrSeedUsed) {
+				writeError(w, r, err)
+				return
+			}
+			serverError(w, r, "Failed to save session", err)
+			return
+		}
+		metrics.SessionsSaved.WithLabelValues(req.Mode).Inc()
+
+		writeJSON(w, http.StatusCreated, result)
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"refine-v2/backend/internal/apierr"
+	"refine-v2/backend/internal/models"
+	"testing"
+)
+
+func TestSaveGameSessionV2CustomRange(t *testing.T) {
+	env := newTestEnv(t)
+	SetSeedKey([]byte("test seed key"))
+	w := do(t, Signup(env.store), "POST", models.SignupRequest{Email: "alice@example.com", Username: "alice", Password: "password1"})
+	cookie := tokenCookie(t, w)
+	userID := decode[models.AuthResponse](t, w).User.ID
+	save := AuthMiddleware(env.store)(SaveGameSessionV2(env.store))
+
+	config := &models.CustomConfig{Min: 1, Max: 5}
+	w = do(t, http.HandlerFunc(GenerateProblemsV2), "POST", models.GenerateRequest{Mode: "addition", Difficulty: 4, Count: 3, Config: config})
+	if w.Code != http.StatusOK {
+		t.Fatalf("generate status = %d: %s", w.Code, w.Body)
+	}
+	set := decode[models.GenerateResponseV2](t, w)
+	answers := make([]int, len(set.Problems))
+	for i, q := range set.Problems {
+		answers[i] = q.Num1 + q.Num2
+	}
+	req := models.SaveSessionRequestV2{Seed: set.Seed, Mode: "addition", Difficulty: 4, TimeLimit: 60, Answers: answers}
+
+	// The seed only verifies with the range it was generated with
+	for _, c := range []*models.CustomConfig{nil, {Min: 1, Max: 6}} {
+		req.Config = c
+		wantError(t, do(t, save, "POST", req, cookie), http.StatusBadRequest, apierr.CodeValidationFailed)
+	}
+
+	req.Config = config
+	w = do(t, save, "POST", req, cookie)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("save status = %d: %s", w.Code, w.Body)
+	}
+	if got := decode[models.ValidateResponseV2](t, w); got.Correct != len(answers) {
+		t.Errorf("scored %d of %d correct against the custom set", got.Correct, len(answers))
+	}
+
+	sessions, err := env.store.ListGameSessions(context.Background(), userID, "", nil, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || !sessions[0].Practice || sessions[0].Difficulty != 4 {
+		t.Errorf("saved %+v, want one difficulty 4 practice session", sessions)
+	}
+}
@@ -2,53 +2,54 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"refine-v2/backend/internal/apierr"
 	"refine-v2/backend/internal/database"
 	"refine-v2/backend/internal/models"
 	"strings"
 
-	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func ChangePassword(store *database.Store) http.HandlerFunc {
+func ChangePassword(store database.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		claims := GetClaims(r)
 		if claims == nil {
-			writeError(w, http.StatusUnauthorized, "Not authenticated")
+			writeError(w, r, apierr.ErrUnauthenticated)
 			return
 		}
 
 		var req models.ChangePasswordRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			writeError(w, http.StatusBadRequest, "Invalid request body")
+			writeError(w, r, apierr.ErrInvalidBody)
 			return
 		}
 
 		if len(req.NewPassword) < 8 {
-			writeError(w, http.StatusBadRequest, "New password must be at least 8 characters")
+			writeError(w, r, apierr.Invalid("new_password", "New password must be at least 8 characters"))
 			return
 		}
 
-		currentHash, err := store.GetPasswordHash(claims.UserID)
+		currentHash, err := store.GetPasswordHash(r.Context(), claims.UserID)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "Failed to verify password")
+			serverError(w, r, "Failed to verify password", err)
 			return
 		}
 
 		if err := bcrypt.CompareHashAndPassword([]byte(currentHash), []byte(req.CurrentPassword)); err != nil {
-			writeError(w, http.StatusUnauthorized, "Current password is incorrect")
+			writeError(w, r, apierr.New(http.StatusUnauthorized, apierr.CodeInvalidPassword, "Current password is incorrect"))
 			return
 		}
 
 		newHash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "Failed to process password")
+			serverError(w, r, "Failed to process password", err)
 			return
 		}
 
-		if err := store.UpdatePassword(claims.UserID, string(newHash)); err != nil {
-			writeError(w, http.StatusInternalServerError, "Failed to update password")
+		if err := store.UpdatePassword(r.Context(), claims.UserID, string(newHash)); err != nil {
+			serverError(w, r, "Failed to update password", err)
 			return
 		}
 
@@ -56,33 +57,33 @@ func ChangePassword(store *database.Store) http.HandlerFunc {
 	}
 }
 
-func ChangeUsername(store *database.Store) http.HandlerFunc {
+func ChangeUsername(store database.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		claims := GetClaims(r)
 		if claims == nil {
-			writeError(w, http.StatusUnauthorized, "Not authenticated")
+			writeError(w, r, apierr.ErrUnauthenticated)
 			return
 		}
 
 		var req models.ChangeUsernameRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			writeError(w, http.StatusBadRequest, "Invalid request body")
+			writeError(w, r, apierr.ErrInvalidBody)
 			return
 		}
 
 		req.Username = strings.TrimSpace(req.Username)
 		if len(req.Username) < 3 || len(req.Username) > 20 {
-			writeError(w, http.StatusBadRequest, "Username must be 3-20 characters")
+			writeError(w, r, errInvalidUsername)
 			return
 		}
 
-		err := store.UpdateUsername(claims.UserID, req.Username)
+		err := store.UpdateUsername(r.Context(), claims.UserID, req.Username)
 		if err != nil {
-			if pqErr, ok := err.(*pq.Error); ok && string(pqErr.Code) == "23505" {
-				writeError(w, http.StatusConflict, "Username already taken")
+			if errors.Is(err, database.ErrUsernameTaken) {
+				writeError(w, r, err)
 				return
 			}
-			writeError(w, http.StatusInternalServerError, "Failed to update username")
+			serverError(w, r, "Failed to update username", err)
 			return
 		}
 
@@ -90,16 +91,16 @@ func ChangeUsername(store *database.Store) http.HandlerFunc {
 	}
 }
 
-func DeleteAccount(store *database.Store) http.HandlerFunc {
+func DeleteAccount(store database.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		claims := GetClaims(r)
 		if claims == nil {
-			writeError(w, http.StatusUnauthorized, "Not authenticated")
+			writeError(w, r, apierr.ErrUnauthenticated)
 			return
 		}
 
-		if err := store.DeleteUser(claims.UserID); err != nil {
-			writeError(w, http.StatusInternalServerError, "Failed to delete account")
+		if err := store.DeleteUser(r.Context(), claims.UserID); err != nil {
+			serverError(w, r, "Failed to delete account", err)
 			return
 		}
 
@@ -1,17 +1,21 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"refine-v2/backend/internal/analytics"
+	"refine-v2/backend/internal/apierr"
 	"refine-v2/backend/internal/database"
 	"refine-v2/backend/internal/models"
 	"strconv"
+	"time"
 )
 
-func GetUserStats(store *database.Store) http.HandlerFunc {
+func GetUserStats(store database.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		claims := GetClaims(r)
 		if claims == nil {
-			writeError(w, http.StatusUnauthorized, "Not authenticated")
+			writeError(w, r, apierr.ErrUnauthenticated)
 			return
 		}
 
@@ -26,15 +30,15 @@ func GetUserStats(store *database.Store) http.HandlerFunc {
 		// Mode filter for recent games (optional)
 		modeFilter := r.URL.Query().Get("mode")
 
-		stats, err := store.GetUserStats(claims.UserID, difficulty)
+		stats, err := store.GetUserStats(r.Context(), claims.UserID, difficulty)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "Failed to get stats")
+			serverError(w, r, "Failed to get stats", err)
 			return
 		}
 
-		recent, err := store.GetRecentGames(claims.UserID, modeFilter)
+		recent, err := store.GetRecentGames(r.Context(), claims.UserID, modeFilter)
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, "Failed to get recent games")
+			serverError(w, r, "Failed to get recent games", err)
 			return
 		}
 
@@ -51,3 +55,195 @@ func GetUserStats(store *database.Store) http.HandlerFunc {
 		})
 	}
 }
+
+const (
+	maxTimeseriesPoints = 366
+	maxRollingWindow    = 90
+)
+
+// timeseriesDefaults are how many intervals GetTimeseries charts, and how
+// many each rolling average covers, when the request doesn't say.
+var timeseriesDefaults = map[string]struct{ points, window int }{
+	models.PeriodDay:  {points: 30, window: 7},
+	models.PeriodWeek: {points: 12, window: 4},
+}
+
+// GetTimeseries charts the user's progress at one mode and difficulty, and
+// optionally one time limit, per day or week in UTC. from and to are dates
+// and default to the last 30 days or 12 weeks; each is widened to the whole
+// day or week it falls in. Intervals without games are included so charts
+// have no gaps.
+func GetTimeseries(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := GetClaims(r)
+		if claims == nil {
+			writeError(w, r, apierr.ErrUnauthenticated)
+			return
+		}
+
+		q := r.URL.Query()
+		var details []models.FieldError
+		invalid := func(field, msg string) {
+			details = append(details, models.FieldError{Field: field, Message: msg})
+		}
+		date := func(field string) (time.Time, bool) {
+			s := q.Get(field)
+			if s == "" {
+				return time.Time{}, false
+			}
+			t, err := time.Parse(time.DateOnly, s)
+			if err != nil {
+				invalid(field, "Use YYYY-MM-DD")
+				return time.Time{}, false
+			}
+			return t, true
+		}
+
+		mode := q.Get("mode")
+		if !validModes[mode] {
+			invalid("mode", "Invalid mode")
+		}
+		difficulty, err := strconv.Atoi(q.Get("difficulty"))
+		if err != nil || difficulty < 1 || difficulty > 3 {
+			invalid("difficulty", "Difficulty must be 1, 2, or 3")
+		}
+		var timeLimit int
+		if s := q.Get("time_limit"); s != "" {
+			if timeLimit, err = strconv.Atoi(s); err != nil || timeLimit <= 0 {
+				invalid("time_limit", "Invalid time_limit")
+			}
+		}
+
+		interval := q.Get("interval")
+		if interval == "" {
+			interval = models.PeriodDay
+		}
+		defaults, ok := timeseriesDefaults[interval]
+		if !ok {
+			invalid("interval", "Interval must be day or week")
+			interval, defaults = models.PeriodDay, timeseriesDefaults[models.PeriodDay]
+		}
+		window := defaults.window
+		if s := q.Get("window"); s != "" {
+			if window, err = strconv.Atoi(s); err != nil || window < 1 || window > maxRollingWindow {
+				invalid("window", fmt.Sprintf("Window must be 1-%d", maxRollingWindow))
+			}
+		}
+
+		last, ok := date("to")
+		if !ok {
+			last = time.Now()
+		}
+		rng := analytics.NewRange(interval, last, last)
+		if first, ok := date("from"); ok {
+			rng = analytics.NewRange(interval, first, last)
+			if first.After(last) {
+				invalid("from", "From must not be after to")
+			}
+		} else {
+			rng.From = rng.Back(defaults.points - 1)
+		}
+		if rng.Len() > maxTimeseriesPoints {
+			invalid("from", fmt.Sprintf("Chart at most %d days or weeks at a time", maxTimeseriesPoints))
+		}
+		if len(details) > 0 {
+			writeError(w, r, apierr.Validation(details...))
+			return
+		}
+
+		filter := models.SessionFilter{Mode: mode, Difficulty: difficulty, TimeLimit: timeLimit}
+		start := rng.HistoryStart(window)
+		sessions, err := store.ListSessionsBetween(r.Context(), claims.UserID, filter, start, rng.To)
+		if err != nil {
+			serverError(w, r, "Failed to get sessions", err)
+			return
+		}
+		var best *int
+		score, found, err := store.GetBestScoreBefore(r.Context(), claims.UserID, filter, start)
+		if err != nil {
+			serverError(w, r, "Failed to get personal best", err)
+			return
+		}
+		if found {
+			best = &score
+		}
+
+		points, comparison := analytics.Timeseries(sessions, rng, window, best)
+		writeJSON(w, http.StatusOK, models.TimeseriesResponse{
+			Mode:       mode,
+			Difficulty: difficulty,
+			TimeLimit:  timeLimit,
+			Interval:   interval,
+			Window:     window,
+			From:       rng.From,
+			To:         rng.To,
+			Points:     points,
+			Comparison: comparison,
+		})
+	}
+}
+
+const (
+	defaultWeaknessDays = 90
+	maxWeaknessDays     = 365
+)
+
+// GetWeaknesses breaks down the user's recent problems by operand size, fact
+// family and fact, optionally for one mode and difficulty. Problems are
+// regenerated from the seeds of server-scored sessions, as in exports. The
+// practice list in the response can be passed as focus to GenerateProblems.
+func GetWeaknesses(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := GetClaims(r)
+		if claims == nil {
+			writeError(w, r, apierr.ErrUnauthenticated)
+			return
+		}
+
+		q := r.URL.Query()
+		var details []models.FieldError
+		invalid := func(field, msg string) {
+			details = append(details, models.FieldError{Field: field, Message: msg})
+		}
+		mode := q.Get("mode")
+		if mode != "" && !validModes[mode] {
+			invalid("mode", "Invalid mode")
+		}
+		var difficulty int
+		var err error
+		if s := q.Get("difficulty"); s != "" {
+			if difficulty, err = strconv.Atoi(s); err != nil || difficulty < 1 || difficulty > 3 {
+				invalid("difficulty", "Difficulty must be 1, 2, or 3")
+			}
+		}
+		days := defaultWeaknessDays
+		if s := q.Get("days"); s != "" {
+			if days, err = strconv.Atoi(s); err != nil || days < 1 || days > maxWeaknessDays {
+				invalid("days", fmt.Sprintf("Days must be 1-%d", maxWeaknessDays))
+			}
+		}
+		if len(details) > 0 {
+			writeError(w, r, apierr.Validation(details...))
+			return
+		}
+
+		to := time.Now()
+		from := to.AddDate(0, 0, -days)
+		filter := models.SessionFilter{Mode: mode, Difficulty: difficulty}
+		sessions, err := store.ListSessionsBetween(r.Context(), claims.UserID, filter, from, to)
+		if err != nil {
+			serverError(w, r, "Failed to get sessions", err)
+			return
+		}
+
+		exports := make([]models.SessionExport, len(sessions))
+		for i, g := range sessions {
+			exports[i] = sessionExport(g)
+		}
+		resp := analytics.Weaknesses(exports)
+		resp.Mode, resp.Difficulty = mode, difficulty
+		resp.From, resp.To = from, to
+		resp.GamesWithoutProblems = len(sessions) - resp.Games
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"refine-v2/backend/internal/apierr"
+	"refine-v2/backend/internal/auth"
+	"refine-v2/backend/internal/database"
+	"refine-v2/backend/internal/models"
+	"strings"
+	"time"
+)
+
+const (
+	maxAPITokensPerUser  = 20
+	defaultTokenLifetime = 90
+	maxTokenLifetime     = 365
+)
+
+func ListAPITokens(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := GetClaims(r)
+		if claims == nil {
+			writeError(w, r, apierr.ErrUnauthenticated)
+			return
+		}
+
+		tokens, err := store.ListAPITokens(r.Context(), claims.UserID)
+		if err != nil {
+			serverError(w, r, "Failed to list tokens", err)
+			return
+		}
+
+		if tokens == nil {
+			tokens = []models.APIToken{}
+		}
+
+		writeJSON(w, http.StatusOK, models.APITokensResponse{Tokens: tokens})
+	}
+}
+
+func CreateAPIToken(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := GetClaims(r)
+		if claims == nil {
+			writeError(w, r, apierr.ErrUnauthenticated)
+			return
+		}
+
+		var req models.CreateAPITokenRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, apierr.ErrInvalidBody)
+			return
+		}
+
+		req.Name = strings.TrimSpace(req.Name)
+		if len(req.Name) < 1 || len(req.Name) > 50 {
+			writeError(w, r, apierr.Invalid("name", "Name must be 1-50 characters"))
+			return
+		}
+		if len(req.Scopes) == 0 {
+			writeError(w, r, apierr.Invalid("scopes", "At least one scope is required"))
+			return
+		}
+		for _, scope := range req.Scopes {
+			if !auth.ValidScope(scope) {
+				writeError(w, r, apierr.Invalid("scopes", "Invalid scope: "+scope))
+				return
+			}
+		}
+		if req.ExpiresInDays == 0 {
+			req.ExpiresInDays = defaultTokenLifetime
+		}
+		if req.ExpiresInDays < 1 || req.ExpiresInDays > maxTokenLifetime {
+			writeError(w, r, apierr.Invalid("expires_in_days", "expires_in_days must be 1-365"))
+			return
+		}
+
+		existing, err := store.ListAPITokens(r.Context(), claims.UserID)
+		if err != nil {
+			serverError(w, r, "Failed to list tokens", err)
+			return
+		}
+		// Expired tokens stay listed until revoked but no longer count
+		active := 0
+		for _, t := range existing {
+			if t.ExpiresAt.After(time.Now()) {
+				active++
+			}
+		}
+		if active >= maxAPITokensPerUser {
+			writeError(w, r, apierr.New(http.StatusConflict, apierr.CodeTokenLimitReached, "Token limit reached, revoke an existing token first"))
+			return
+		}
+
+		tokenStr, hash, prefix, err := auth.GenerateAPIToken()
+		if err != nil {
+			serverError(w, r, "Failed to generate token", err)
+			return
+		}
+
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresInDays) * 24 * time.Hour)
+		token, err := store.CreateAPIToken(r.Context(), claims.UserID, req.Name, hash, prefix, req.Scopes, expiresAt)
+		if err != nil {
+			serverError(w, r, "Failed to create token", err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, models.CreateAPITokenResponse{
+			Token:    tokenStr,
+			APIToken: *token,
+		})
+	}
+}
+
+func RevokeAPIToken(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := GetClaims(r)
+		if claims == nil {
+			writeError(w, r, apierr.ErrUnauthenticated)
+			return
+		}
+
+		tokenID, ok := parseIDParam(w, r, "id")
+		if !ok {
+			return
+		}
+
+		found, err := store.DeleteAPIToken(r.Context(), claims.UserID, tokenID)
+		if err != nil {
+			serverError(w, r, "Failed to revoke token", err)
+			return
+		}
+		if !found {
+			writeError(w, r, apierr.New(http.StatusNotFound, apierr.CodeNotFound, "Token not found"))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]string{"message": "Token revoked"})
+	}
+}
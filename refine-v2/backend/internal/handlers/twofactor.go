@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"refine-v2/backend/internal/apierr"
+	"refine-v2/backend/internal/auth"
+	"refine-v2/backend/internal/database"
+	"refine-v2/backend/internal/metrics"
+	"refine-v2/backend/internal/models"
+	"refine-v2/backend/internal/ratelimit"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const totpIssuer = "Refine"
+
+// LoginTwoFactor completes a login for an account with 2FA enabled, using the
+// challenge token returned by Login plus a TOTP or recovery code.
+func LoginTwoFactor(store database.Store, lockout *ratelimit.Lockout) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req models.TwoFactorLoginRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, apierr.ErrInvalidBody)
+			return
+		}
+
+		challenge, err := auth.ValidateTwoFactorToken(req.ChallengeToken)
+		if err != nil {
+			writeError(w, r, apierr.New(http.StatusUnauthorized, apierr.CodeInvalidChallenge, "Invalid or expired login challenge"))
+			return
+		}
+
+		lockKey := "user:" + strconv.FormatInt(challenge.UserID, 10)
+		if retryAfter := lockout.Check(lockKey); retryAfter > 0 {
+			writeTooManyRequests(w, r, retryAfter)
+			return
+		}
+
+		ok, err := verifySecondFactor(r.Context(), store, challenge.UserID, req.Code, req.RecoveryCode)
+		if err != nil {
+			serverError(w, r, "Failed to verify code", err)
+			return
+		}
+		if !ok {
+			lockout.Fail(lockKey)
+			metrics.Logins.WithLabelValues(metrics.LoginFailure).Inc()
+			writeError(w, r, errInvalidCode)
+			return
+		}
+		lockout.Succeed(lockKey)
+
+		user, err := store.GetUserByID(r.Context(), challenge.UserID)
+		if err != nil {
+			serverError(w, r, "Failed to get user", err)
+			return
+		}
+
+		tokenStr, err := auth.GenerateToken(user.ID, user.Username)
+		if err != nil {
+			serverError(w, r, "Failed to generate token", err)
+			return
+		}
+
+		setTokenCookie(w, tokenStr)
+		metrics.Logins.WithLabelValues(metrics.LoginSuccess).Inc()
+		writeJSON(w, http.StatusOK, models.AuthResponse{User: *user})
+	}
+}
+
+// SetupTwoFactor generates a new pending TOTP secret. It is not active until
+// confirmed with EnableTwoFactor.
+func SetupTwoFactor(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := GetClaims(r)
+		if claims == nil {
+			writeError(w, r, apierr.ErrUnauthenticated)
+			return
+		}
+
+		_, enabled, err := store.GetTOTP(r.Context(), claims.UserID)
+		if err != nil {
+			serverError(w, r, "Failed to get 2FA status", err)
+			return
+		}
+		if enabled {
+			writeError(w, r, errTwoFactorEnabled)
+			return
+		}
+
+		user, err := store.GetUserByID(r.Context(), claims.UserID)
+		if err != nil {
+			serverError(w, r, "Failed to get user", err)
+			return
+		}
+
+		secret, err := auth.GenerateTOTPSecret()
+		if err != nil {
+			serverError(w, r, "Failed to generate secret", err)
+			return
+		}
+
+		if err := store.SetPendingTOTP(r.Context(), claims.UserID, secret); err != nil {
+			serverError(w, r, "Failed to save secret", err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, models.TwoFactorSetupResponse{
+			Secret:          secret,
+			ProvisioningURI: auth.TOTPProvisioningURI(totpIssuer, user.Email, secret),
+		})
+	}
+}
+
+// EnableTwoFactor confirms enrollment with a first valid code and returns the
+// recovery codes. They are shown only once.
+func EnableTwoFactor(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := GetClaims(r)
+		if claims == nil {
+			writeError(w, r, apierr.ErrUnauthenticated)
+			return
+		}
+
+		var req models.TwoFactorCodeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, apierr.ErrInvalidBody)
+			return
+		}
+
+		secret, enabled, err := store.GetTOTP(r.Context(), claims.UserID)
+		if err != nil {
+			serverError(w, r, "Failed to get 2FA status", err)
+			return
+		}
+		if enabled {
+			writeError(w, r, errTwoFactorEnabled)
+			return
+		}
+		if secret == "" {
+			writeError(w, r, apierr.New(http.StatusConflict, apierr.CodeTwoFactorNotStarted, "Two-factor setup has not been started"))
+			return
+		}
+
+		ok, err := useTOTP(r.Context(), store, claims.UserID, secret, req.Code)
+		if err != nil {
+			serverError(w, r, "Failed to verify code", err)
+			return
+		}
+		if !ok {
+			writeError(w, r, errInvalidCode)
+			return
+		}
+
+		codes, hashes, err := newRecoveryCodes()
+		if err != nil {
+			serverError(w, r, "Failed to generate recovery codes", err)
+			return
+		}
+
+		if err := store.EnableTOTP(r.Context(), claims.UserID, hashes); err != nil {
+			serverError(w, r, "Failed to enable two-factor authentication", err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+	}
+}
+
+// DisableTwoFactor turns 2FA off. It requires the account password plus a
+// current TOTP or recovery code.
+func DisableTwoFactor(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := GetClaims(r)
+		if claims == nil {
+			writeError(w, r, apierr.ErrUnauthenticated)
+			return
+		}
+
+		var req models.TwoFactorDisableRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, apierr.ErrInvalidBody)
+			return
+		}
+
+		if !checkPassword(w, r, store, claims.UserID, req.Password) {
+			return
+		}
+
+		ok, err := verifySecondFactor(r.Context(), store, claims.UserID, req.Code, req.RecoveryCode)
+		if err != nil {
+			serverError(w, r, "Failed to verify code", err)
+			return
+		}
+		if !ok {
+			writeError(w, r, errInvalidCode)
+			return
+		}
+
+		if err := store.DisableTOTP(r.Context(), claims.UserID); err != nil {
+			serverError(w, r, "Failed to disable two-factor authentication", err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]string{"message": "Two-factor authentication disabled"})
+	}
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after re-entering the password.
+func RegenerateRecoveryCodes(store database.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := GetClaims(r)
+		if claims == nil {
+			writeError(w, r, apierr.ErrUnauthenticated)
+			return
+		}
+
+		var req models.RecoveryCodesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, r, apierr.ErrInvalidBody)
+			return
+		}
+
+		_, enabled, err := store.GetTOTP(r.Context(), claims.UserID)
+		if err != nil {
+			serverError(w, r, "Failed to get 2FA status", err)
+			return
+		}
+		if !enabled {
+			writeError(w, r, apierr.New(http.StatusConflict, apierr.CodeTwoFactorNotEnabled, "Two-factor authentication is not enabled"))
+			return
+		}
+
+		if !checkPassword(w, r, store, claims.UserID, req.Password) {
+			return
+		}
+
+		codes, hashes, err := newRecoveryCodes()
+		if err != nil {
+			serverError(w, r, "Failed to generate recovery codes", err)
+			return
+		}
+
+		if err := store.ReplaceRecoveryCodes(r.Context(), claims.UserID, hashes); err != nil {
+			serverError(w, r, "Failed to save recovery codes", err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+	}
+}
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code.
+func verifySecondFactor(ctx context.Context, store database.Store, userID int64, code, recoveryCode string) (bool, error) {
+	secret, enabled, err := store.GetTOTP(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if !enabled {
+		return false, nil
+	}
+
+	if code != "" {
+		return useTOTP(ctx, store, userID, secret, code)
+	}
+	if recoveryCode != "" {
+		return store.ConsumeRecoveryCode(ctx, userID, auth.HashRecoveryCode(recoveryCode))
+	}
+	return false, nil
+}
+
+// useTOTP accepts a valid code whose time step has not been used yet.
+func useTOTP(ctx context.Context, store database.Store, userID int64, secret, code string) (bool, error) {
+	step, ok := auth.MatchTOTP(secret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return store.UseTOTPStep(ctx, userID, step)
+}
+
+// checkPassword re-authenticates the user, writing an error response on failure.
+func checkPassword(w http.ResponseWriter, r *http.Request, store database.Store, userID int64, password string) bool {
+	hash, err := store.GetPasswordHash(r.Context(), userID)
+	if err != nil {
+		serverError(w, r, "Failed to verify password", err)
+		return false
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		writeError(w, r, apierr.New(http.StatusUnauthorized, apierr.CodeInvalidPassword, "Password is incorrect"))
+		return false
+	}
+	return true
+}
+
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := auth.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, c := range codes {
+		hashes[i] = auth.HashRecoveryCode(c)
+	}
+	return codes, hashes, nil
+}
@@ -1,43 +1,108 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"refine-v2/backend/internal/apierr"
 	"refine-v2/backend/internal/generator"
+	"refine-v2/backend/internal/metrics"
 	"refine-v2/backend/internal/models"
+	"refine-v2/backend/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func ValidateAnswers(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeValidateRequest(w, r)
+	if !ok {
+		return
+	}
+	focus, _ := generator.ParseFocusSeed(req.Seed)
+	result := scoreAnswers(r.Context(), req, focus)
+	writeJSON(w, http.StatusOK, result.ValidateResponse)
+}
+
+// ValidateAnswersV2 also reports the correct answer for each problem.
+func ValidateAnswersV2(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeValidateRequest(w, r)
+	if !ok {
+		return
+	}
+	focus, _ := generator.ParseFocusSeed(req.Seed)
+	writeJSON(w, http.StatusOK, scoreAnswers(r.Context(), req, focus))
+}
+
+func decodeValidateRequest(w http.ResponseWriter, r *http.Request) (models.ValidateRequest, bool) {
 	var req models.ValidateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeError(w, http.StatusBadRequest, "Invalid request body")
-		return
+		writeError(w, r, apierr.ErrInvalidBody)
+		return req, false
 	}
 
 	if req.Seed == "" {
-		writeError(w, http.StatusBadRequest, "Missing seed")
-		return
+		writeError(w, r, apierr.Invalid("seed", "Missing seed"))
+		return req, false
 	}
 	if len(req.Answers) == 0 {
-		writeError(w, http.StatusBadRequest, "No answers provided")
-		return
+		writeError(w, r, apierr.Invalid("answers", "No answers provided"))
+		return req, false
 	}
+	return req, true
+}
+
+// scoreAnswers regenerates the problem set behind req, aimed at focus if it
+// was a focused set, and marks each answer.
+func scoreAnswers(ctx context.Context, req models.ValidateRequest, focus []generator.Target) models.ValidateResponseV2 {
+	ctx, span := tracing.Start(ctx, "validate answers",
+		attribute.String("problems.mode", req.Mode),
+		attribute.Int("problems.count", len(req.Answers)),
+	)
+	defer span.End()
 
 	problemCount := len(req.Answers)
-	problems := generator.GenerateWithSeed(req.Seed, req.Mode, req.Difficulty, problemCount, req.Config)
+	problems := problemSet(ctx, req.Seed, focus, req.Mode, req.Difficulty, problemCount, req.Config)
+
+	correct, results := markAnswers(problems, req.Answers)
+
+	span.SetAttributes(attribute.Int("problems.correct", correct))
+
+	score := correct * 10
+	metrics.Validations.WithLabelValues(modeLabel(req.Mode)).Inc()
+
+	return models.ValidateResponseV2{
+		ValidateResponse: models.ValidateResponse{
+			Correct: correct,
+			Total:   len(req.Answers),
+			Score:   score,
+		},
+		Results: results,
+	}
+}
 
+// markAnswers pairs each answer with its problem. Answers beyond the end of
+// problems are ignored.
+func markAnswers(problems []models.Problem, answers []int) (int, []models.ProblemResult) {
 	correct := 0
-	for i, userAnswer := range req.Answers {
-		if i < len(problems) && problems[i].Answer == userAnswer {
+	results := make([]models.ProblemResult, 0, min(len(problems), len(answers)))
+	for i, userAnswer := range answers {
+		if i >= len(problems) {
+			break
+		}
+		p := problems[i]
+		ok := p.Answer == userAnswer
+		if ok {
 			correct++
 		}
+		results = append(results, models.ProblemResult{
+			ID:       p.ID,
+			Num1:     p.Num1,
+			Operator: p.Operator,
+			Num2:     p.Num2,
+			Answer:   p.Answer,
+			Given:    userAnswer,
+			Correct:  ok,
+		})
 	}
-
-	score := correct * 10
-
-	writeJSON(w, http.StatusOK, models.ValidateResponse{
-		Correct: correct,
-		Total:   len(req.Answers),
-		Score:   score,
-	})
+	return correct, results
 }
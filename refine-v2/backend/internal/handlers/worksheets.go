@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"refine-v2/backend/internal/apierr"
+	"refine-v2/backend/internal/generator"
+	"refine-v2/backend/internal/metrics"
+	"refine-v2/backend/internal/models"
+	"refine-v2/backend/internal/worksheet"
+	"strconv"
+)
+
+const (
+	defaultWorksheetCount = 20
+	maxWorksheetColumns   = 5
+	maxSeedLength         = 100
+	maxTitleLength        = 80
+)
+
+var worksheetLayouts = map[string]bool{
+	string(worksheet.Horizontal): true,
+	string(worksheet.Vertical):   true,
+}
+
+var worksheetPapers = map[string]bool{
+	string(worksheet.Letter): true,
+	string(worksheet.A4):     true,
+}
+
+// GetWorksheet renders a printable worksheet as PDF (the default) or HTML.
+// The generator settings are the same as GenerateProblems, as query
+// parameters. Passing the seed printed on a sheet reproduces it exactly.
+func GetWorksheet(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var details []models.FieldError
+	invalid := func(field, msg string) {
+		details = append(details, models.FieldError{Field: field, Message: msg})
+	}
+	number := func(field string) int {
+		s := q.Get(field)
+		if s == "" {
+			return 0
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			invalid(field, "Must be a whole number")
+		}
+		return n
+	}
+
+	req := models.GenerateRequest{
+		Count:      number("count"),
+		Difficulty: number("difficulty"),
+		Mode:       q.Get("mode"),
+	}
+	if q.Has("min") || q.Has("max") {
+		req.Config = &models.CustomConfig{Min: number("min"), Max: number("max")}
+	}
+	details = append(details, checkGenerateRequest(req)...)
+	if req.Count == 0 {
+		req.Count = defaultWorksheetCount
+	}
+	req = withProblemDefaults(req)
+
+	opts := worksheet.Options{
+		Title:      q.Get("title"),
+		Seed:       q.Get("seed"),
+		Mode:       req.Mode,
+		Difficulty: req.Difficulty,
+		Config:     req.Config,
+		Columns:    number("columns"),
+		Layout:     worksheet.Layout(q.Get("layout")),
+		Paper:      worksheet.Paper(q.Get("paper")),
+	}
+	if opts.Columns < 0 || opts.Columns > maxWorksheetColumns {
+		invalid("columns", fmt.Sprintf("Columns must be 1-%d", maxWorksheetColumns))
+	}
+	if opts.Layout != "" && !worksheetLayouts[string(opts.Layout)] {
+		invalid("layout", "Layout must be horizontal or vertical")
+	}
+	if opts.Paper != "" && !worksheetPapers[string(opts.Paper)] {
+		invalid("paper", "Paper must be letter or a4")
+	}
+	if len(opts.Title) > maxTitleLength {
+		invalid("title", fmt.Sprintf("Title must be at most %d characters", maxTitleLength))
+	}
+	if len(opts.Seed) > maxSeedLength {
+		invalid("seed", fmt.Sprintf("Seed must be at most %d characters", maxSeedLength))
+	}
+	if key := q.Get("answer_key"); key != "" {
+		var err error
+		if opts.AnswerKey, err = strconv.ParseBool(key); err != nil {
+			invalid("answer_key", "Must be true or false")
+		}
+	}
+	format := q.Get("format")
+	if format == "" {
+		format = "pdf"
+	}
+	if format != "pdf" && format != "html" {
+		invalid("format", "Format must be pdf or html")
+	}
+	if len(details) > 0 {
+		writeError(w, r, apierr.Validation(details...))
+		return
+	}
+
+	if opts.Seed == "" {
+		opts.Seed = generator.CreateSeed()
+	}
+	problems := generate(r.Context(), opts.Seed, nil, req.Mode, req.Difficulty, req.Count, req.Config)
+	metrics.ProblemsGenerated.WithLabelValues(modeLabel(req.Mode)).Add(float64(len(problems)))
+	ws := worksheet.New(opts, problems)
+
+	// Render fully first so a failure can still be reported as JSON
+	var buf bytes.Buffer
+	var err error
+	contentType := "application/pdf"
+	if format == "html" {
+		contentType = "text/html; charset=utf-8"
+		err = ws.HTML(&buf)
+	} else {
+		err = ws.PDF(&buf)
+	}
+	if err != nil {
+		serverError(w, r, "Failed to render worksheet", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="worksheet.%s"`, format))
+	w.Header().Set("X-Worksheet-Seed", opts.Seed)
+	w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="canonical"`, worksheetURL(r, opts.Seed)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// worksheetURL is the request URL with the seed filled in, which regenerates
+// the same sheet.
+func worksheetURL(r *http.Request, seed string) string {
+	q := r.URL.Query()
+	q.Set("seed", seed)
+	u := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+	return u.String()
+}
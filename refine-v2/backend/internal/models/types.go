@@ -9,6 +9,12 @@ type GenerateRequest struct {
 	Difficulty int           `json:"difficulty"`
 	Count      int           `json:"count"`
 	Config     *CustomConfig `json:"config,omitempty"`
+	// Focus aims the set at generator targets, such as the weak spots from
+	// GetWeaknesses, in place of Mode.
+	Focus []string `json:"focus,omitempty"`
+	// Strict rejects out of range values instead of replacing them with
+	// defaults.
+	Strict bool `json:"strict,omitempty"`
 }
 
 type GenerateResponse struct {
@@ -66,16 +72,24 @@ type LoginRequest struct {
 }
 
 type User struct {
-	ID        int64     `json:"id"`
-	Email     string    `json:"email"`
-	Username  string    `json:"username"`
-	CreatedAt time.Time `json:"created_at"`
+	ID               int64     `json:"id"`
+	Email            string    `json:"email"`
+	Username         string    `json:"username"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+	CreatedAt        time.Time `json:"created_at"`
 }
 
 type AuthResponse struct {
 	User User `json:"user"`
 }
 
+// LoginChallengeResponse is returned by login instead of AuthResponse when
+// the account has 2FA enabled.
+type LoginChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+}
+
 type ChangePasswordRequest struct {
 	CurrentPassword string `json:"current_password"`
 	NewPassword     string `json:"new_password"`
@@ -85,6 +99,65 @@ type ChangeUsernameRequest struct {
 	Username string `json:"username"`
 }
 
+// --- Two-factor auth ---
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code,omitempty"`
+	RecoveryCode   string `json:"recovery_code,omitempty"`
+}
+
+type TwoFactorSetupResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type TwoFactorDisableRequest struct {
+	Password     string `json:"password"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+type RecoveryCodesRequest struct {
+	Password string `json:"password"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// --- Personal access tokens ---
+
+type APIToken struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type CreateAPITokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+// CreateAPITokenResponse is the only time the plain token is returned.
+type CreateAPITokenResponse struct {
+	Token    string   `json:"token"`
+	APIToken APIToken `json:"api_token"`
+}
+
+type APITokensResponse struct {
+	Tokens []APIToken `json:"tokens"`
+}
+
 // --- Email signup ---
 
 type EmailRequest struct {
@@ -105,6 +178,14 @@ type SaveSessionRequest struct {
 	Correct    int    `json:"correct"`
 	Total      int    `json:"total"`
 	TimeLimit  int    `json:"time_limit"`
+
+	// Seed and Answers are stored when the server scored the session, so its
+	// problems can be regenerated. Clients cannot set them directly.
+	Seed    string `json:"-"`
+	Answers []int  `json:"-"`
+	// Practice marks a focused set, which is kept off the global
+	// leaderboards.
+	Practice bool `json:"-"`
 }
 
 type GameSessionRecord struct {
@@ -116,10 +197,92 @@ type GameSessionRecord struct {
 	Total      int       `json:"total"`
 	TimeLimit  int       `json:"time_limit"`
 	PlayedAt   time.Time `json:"played_at"`
+	Seed       string    `json:"seed,omitempty"`
+	Answers    []int     `json:"answers,omitempty"`
+	// Imported sessions came from another practice tool and are not on the
+	// global leaderboards.
+	Imported bool `json:"imported,omitempty"`
+	// Practice sessions were focused sets and are not on the global
+	// leaderboards either.
+	Practice bool `json:"practice,omitempty"`
+}
+
+// SessionCursor is a position in a user's history, which is listed newest
+// first.
+type SessionCursor struct {
+	PlayedAt time.Time
+	ID       int64
+}
+
+type SessionsPage struct {
+	Sessions []GameSessionRecord `json:"sessions"`
+	// NextCursor fetches the following page; it is empty on the last one.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// --- Imports ---
+
+// Import job statuses.
+const (
+	ImportPending   = "pending"
+	ImportRunning   = "running"
+	ImportCompleted = "completed"
+	ImportFailed    = "failed"
+)
+
+// ImportStaleAfter is how long a job may stay pending or running. Jobs run in
+// the server process, so one that is older was cut off by a restart and no
+// longer holds up the user's next import.
+const ImportStaleAfter = time.Hour
+
+// Outcomes of an import row.
+const (
+	RowImported  = "imported"
+	RowDuplicate = "duplicate"
+	RowInvalid   = "invalid"
+)
+
+// ImportJob is a background import of session history. Counts grow as rows
+// are processed; Rows lists the outcome of every processed row.
+type ImportJob struct {
+	ID         int64             `json:"id"`
+	Status     string            `json:"status"`
+	Format     string            `json:"format"`
+	TotalRows  int               `json:"total_rows"`
+	Processed  int               `json:"processed"`
+	Imported   int               `json:"imported"`
+	Duplicates int               `json:"duplicates"`
+	Invalid    int               `json:"invalid"`
+	Error      string            `json:"error,omitempty"`
+	Rows       []ImportRowResult `json:"rows"`
+	CreatedAt  time.Time         `json:"created_at"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+// ImportRowResult is the outcome of one row, numbered from 1 in file order.
+type ImportRowResult struct {
+	Row       int          `json:"row"`
+	Status    string       `json:"status"`
+	SessionID int64        `json:"session_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// ImportedSession is a validated import row ready to save.
+type ImportedSession struct {
+	Row int
+	SaveSessionRequest
+	PlayedAt time.Time
 }
 
 // --- Leaderboard ---
 
+// Leaderboard periods, in UTC. Weeks start on Monday.
+const (
+	PeriodAll  = "all"
+	PeriodWeek = "week"
+	PeriodDay  = "day"
+)
+
 type LeaderboardEntry struct {
 	Rank      int       `json:"rank"`
 	Username  string    `json:"username,omitempty"`
@@ -150,3 +313,284 @@ type StatsResponse struct {
 	Stats       []ModeStat          `json:"stats"`
 	RecentGames []GameSessionRecord `json:"recent_games"`
 }
+
+// --- Progress ---
+
+// SessionFilter selects sessions by game settings; zero fields match any.
+type SessionFilter struct {
+	Mode       string
+	Difficulty int
+	TimeLimit  int
+}
+
+// ProgressSummary aggregates a set of sessions. Accuracy is averaged per game
+// like ModeStat; problems per minute is problems answered over time played.
+// Averages and the best score are null when there are no games.
+type ProgressSummary struct {
+	Games             int      `json:"games"`
+	AvgScore          *float64 `json:"avg_score"`
+	BestScore         *int     `json:"best_score"`
+	AvgAccuracy       *float64 `json:"avg_accuracy"`
+	ProblemsPerMinute *float64 `json:"problems_per_minute"`
+}
+
+// TimeseriesPoint summarises one day or week.
+type TimeseriesPoint struct {
+	Start time.Time `json:"start"`
+	ProgressSummary
+	// Rolling covers this interval and the window-1 before it.
+	Rolling ProgressSummary `json:"rolling"`
+	// PersonalBest is the best score ever at the end of this interval.
+	PersonalBest    *int `json:"personal_best"`
+	NewPersonalBest bool `json:"new_personal_best,omitempty"`
+}
+
+type PeriodSummary struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	ProgressSummary
+}
+
+// ProgressComparison compares the charted range with the one of the same
+// length just before it. Changes are current minus previous, and null when
+// either side has no games.
+type ProgressComparison struct {
+	Current                 PeriodSummary `json:"current"`
+	Previous                PeriodSummary `json:"previous"`
+	ScoreChange             *float64      `json:"score_change"`
+	AccuracyChange          *float64      `json:"accuracy_change"`
+	ProblemsPerMinuteChange *float64      `json:"problems_per_minute_change"`
+}
+
+type TimeseriesResponse struct {
+	Mode       string             `json:"mode"`
+	Difficulty int                `json:"difficulty"`
+	TimeLimit  int                `json:"time_limit,omitempty"`
+	Interval   string             `json:"interval"`
+	Window     int                `json:"window"`
+	From       time.Time          `json:"from"`
+	To         time.Time          `json:"to"`
+	Points     []TimeseriesPoint  `json:"points"`
+	Comparison ProgressComparison `json:"comparison"`
+}
+
+// --- Weaknesses ---
+
+// ProblemStat aggregates answers to a group of problems. Focus is the
+// generator target that practises the group. Answer times aren't recorded
+// per problem, so GameProblemsPerMinute is the pace of the games the
+// problems were answered in, weighted by problem.
+type ProblemStat struct {
+	Focus                 string   `json:"focus"`
+	Problems              int      `json:"problems"`
+	Correct               int      `json:"correct"`
+	Accuracy              *float64 `json:"accuracy"`
+	GameProblemsPerMinute *float64 `json:"game_problems_per_minute"`
+}
+
+// WeaknessMatrix buckets one operator's problems by the size of each
+// operand: Cells[i][j] holds the problems whose first operand is in Rows[i]
+// and second in Columns[j]. Only rows and columns with problems are listed.
+type WeaknessMatrix struct {
+	Operator string          `json:"operator"`
+	Axis     string          `json:"axis"`
+	Rows     []string        `json:"rows"`
+	Columns  []string        `json:"columns"`
+	Cells    [][]ProblemStat `json:"cells"`
+}
+
+// FactFamilyStat covers a family of facts, such as the ×7 table or
+// additions that carry.
+type FactFamilyStat struct {
+	Family string `json:"family"`
+	ProblemStat
+}
+
+// FactStat covers one fact in either operand order, e.g. 7×8 and 8×7.
+type FactStat struct {
+	Num1     int    `json:"num1"`
+	Operator string `json:"operator"`
+	Num2     int    `json:"num2"`
+	Answer   int    `json:"answer"`
+	ProblemStat
+}
+
+type WeaknessResponse struct {
+	Mode       string    `json:"mode,omitempty"`
+	Difficulty int       `json:"difficulty,omitempty"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	// Games have per-problem results; older sessions and imported ones
+	// only have totals and are counted in GamesWithoutProblems.
+	Games                int              `json:"games"`
+	GamesWithoutProblems int              `json:"games_without_problems"`
+	Problems             int              `json:"problems"`
+	Accuracy             *float64         `json:"accuracy"`
+	Matrices             []WeaknessMatrix `json:"matrices"`
+	Families             []FactFamilyStat `json:"families"`
+	WeakestFacts         []FactStat       `json:"weakest_facts"`
+	// Practice lists focus targets for a problem set aimed at the weakest
+	// cells, families and facts.
+	Practice []string `json:"practice"`
+}
+
+// --- Admin ---
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+type AdminUser struct {
+	ID          int64      `json:"id"`
+	Email       string     `json:"email"`
+	Username    string     `json:"username"`
+	Role        string     `json:"role"`
+	BannedAt    *time.Time `json:"banned_at"`
+	BanReason   string     `json:"ban_reason,omitempty"`
+	GamesPlayed int        `json:"games_played"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type AdminUsersResponse struct {
+	Users []AdminUser `json:"users"`
+}
+
+type AdminGameSession struct {
+	GameSessionRecord
+	LeaderboardHidden bool `json:"leaderboard_hidden"`
+}
+
+type AdminSessionsResponse struct {
+	Sessions []AdminGameSession `json:"sessions"`
+}
+
+type AdminReasonRequest struct {
+	Reason string `json:"reason"`
+}
+
+type AdminUsernameRequest struct {
+	Username string `json:"username"`
+	Reason   string `json:"reason"`
+}
+
+type EmailSignup struct {
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type AuditEntry struct {
+	ID         int64     `json:"id"`
+	AdminID    int64     `json:"admin_id"`
+	Action     string    `json:"action"`
+	TargetType string    `json:"target_type"`
+	TargetID   *int64    `json:"target_id"`
+	Details    string    `json:"details"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type AuditLogResponse struct {
+	Entries []AuditEntry `json:"entries"`
+}
+
+// --- API v2 ---
+
+// GenerateResponseV2 echoes the settings the problems were generated with,
+// after defaults, so clients can validate and save without tracking them.
+type GenerateResponseV2 struct {
+	Seed       string        `json:"seed"`
+	Mode       string        `json:"mode"`
+	Difficulty int           `json:"difficulty"`
+	Config     *CustomConfig `json:"config,omitempty"`
+	Focus      []string      `json:"focus,omitempty"`
+	Problems   []Question    `json:"problems"`
+}
+
+// ValidateResponseV2 adds a per-problem breakdown to ValidateResponse.
+type ValidateResponseV2 struct {
+	ValidateResponse
+	Results []ProblemResult `json:"results"`
+}
+
+type ProblemResult struct {
+	ID       int    `json:"id"`
+	Num1     int    `json:"num1"`
+	Operator string `json:"operator"`
+	Num2     int    `json:"num2"`
+	Answer   int    `json:"answer"`
+	Given    int    `json:"given"`
+	Correct  bool   `json:"correct"`
+}
+
+// SaveSessionRequestV2 records a game by its problem set and answers; the
+// server scores it rather than trusting client-reported totals.
+type SaveSessionRequestV2 struct {
+	Seed       string `json:"seed"`
+	Mode       string `json:"mode"`
+	Difficulty int    `json:"difficulty"`
+	// Config is the custom range the set was generated with, if any.
+	Config    *CustomConfig `json:"config,omitempty"`
+	TimeLimit int           `json:"time_limit"`
+	Answers   []int         `json:"answers"`
+}
+
+// --- Export ---
+
+// SessionExport is a session with its problems, for sessions that stored
+// their seed and answers.
+type SessionExport struct {
+	GameSessionRecord
+	Results []ProblemResult `json:"results,omitempty"`
+}
+
+// AccountExport is profile.json in the account archive.
+type AccountExport struct {
+	ExportedAt time.Time  `json:"exported_at"`
+	User       User       `json:"user"`
+	Role       string     `json:"role"`
+	APITokens  []APIToken `json:"api_tokens"`
+}
+
+// --- Health ---
+
+type ReadinessResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuiltAt   string `json:"built_at,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// --- Errors ---
+
+// ErrorResponse is the body of every error response.
+type ErrorResponse struct {
+	Code      string       `json:"code"`
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	// Error repeats Message for clients written before codes existed.
+	Error string `json:"error"`
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ProblemDetails is an RFC 7807 problem details object, sent instead of
+// ErrorResponse when the client accepts application/problem+json.
+type ProblemDetails struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
@@ -0,0 +1,112 @@
+// Package openapi holds the OpenAPI 3 description of the HTTP API. The
+// document in openapi.yaml is maintained by hand alongside the handlers and is
+// the source for the generated client in refine-v2/backend/client.
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+//go:embed openapi.yaml
+var document []byte
+
+// Spec is the loaded document plus a route table for matching requests to
+// operations.
+type Spec struct {
+	Doc    *openapi3.T
+	json   []byte
+	routes []route
+}
+
+type route struct {
+	method   string
+	segments []string // "{...}" matches any single segment
+	op       *openapi3.Operation
+}
+
+// Load parses and validates the embedded document.
+func Load() (*Spec, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(document)
+	if err != nil {
+		return nil, fmt.Errorf("parse openapi.yaml: %w", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid openapi.yaml: %w", err)
+	}
+	data, err := doc.MarshalJSON()
+	if err != nil {
+		return nil, fmt.Errorf("encode openapi.yaml: %w", err)
+	}
+
+	s := &Spec{Doc: doc, json: data}
+	for path, item := range doc.Paths.Map() {
+		for method, op := range item.Operations() {
+			s.routes = append(s.routes, route{
+				method:   method,
+				segments: strings.Split(strings.Trim(path, "/"), "/"),
+				op:       op,
+			})
+		}
+	}
+	return s, nil
+}
+
+// JSON returns the document encoded as JSON.
+func (s *Spec) JSON() []byte {
+	return s.json
+}
+
+// Operation finds the operation for a request, or nil if the document does
+// not describe it. The document lists the /api/v1 routes and the ones /api/v2
+// redefines; other /api/v2 paths, the unversioned /api paths and /emails are
+// looked up as their /api/v1 equivalents.
+func (s *Spec) Operation(method, path string) *openapi3.Operation {
+	if op := s.lookup(method, path); op != nil {
+		return op
+	}
+	switch {
+	case strings.HasPrefix(path, "/api/v1/"):
+		return nil
+	case strings.HasPrefix(path, "/api/v2/"):
+		return s.lookup(method, "/api/v1/"+strings.TrimPrefix(path, "/api/v2/"))
+	case strings.HasPrefix(path, "/api/"):
+		return s.lookup(method, "/api/v1/"+strings.TrimPrefix(path, "/api/"))
+	case path == "/emails":
+		return s.lookup(method, "/api/v1/emails")
+	}
+	return nil
+}
+
+func (s *Spec) lookup(method, path string) *openapi3.Operation {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for _, rt := range s.routes {
+		if rt.method == method && match(rt.segments, segments) {
+			return rt.op
+		}
+	}
+	return nil
+}
+
+func match(pattern, segments []string) bool {
+	if len(pattern) != len(segments) {
+		return false
+	}
+	for i, p := range pattern {
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+			if segments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if p != segments[i] {
+			return false
+		}
+	}
+	return true
+}
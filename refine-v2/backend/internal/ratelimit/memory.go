@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	per    time.Duration
+}
+
+type failure struct {
+	count   int
+	last    time.Time
+	expires time.Time
+}
+
+// Memory is an in-process Backend. State is lost on restart and is not
+// shared between instances.
+type Memory struct {
+	mu       sync.Mutex
+	buckets  map[string]*bucket
+	failures map[string]*failure
+	stop     chan struct{}
+}
+
+// NewMemory creates a Memory backend that sweeps idle entries every interval.
+func NewMemory(interval time.Duration) *Memory {
+	m := &Memory{
+		buckets:  make(map[string]*bucket),
+		failures: make(map[string]*failure),
+		stop:     make(chan struct{}),
+	}
+	go m.sweep(interval)
+	return m
+}
+
+// Close stops the background sweeper.
+func (m *Memory) Close() {
+	close(m.stop)
+}
+
+func (m *Memory) Take(key string, p Policy, now time.Time) (bool, time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rate := float64(p.Burst) / p.Per.Seconds() // tokens per second
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(p.Burst), last: now, per: p.Per}
+		m.buckets[key] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > float64(p.Burst) {
+		b.tokens = float64(p.Burst)
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	return false, wait
+}
+
+func (m *Memory) RecordFailure(key string, ttl time.Duration, now time.Time) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, ok := m.failures[key]
+	if !ok || now.After(f.expires) {
+		f = &failure{}
+		m.failures[key] = f
+	}
+	f.count++
+	f.last = now
+	f.expires = now.Add(ttl)
+	return f.count
+}
+
+func (m *Memory) Failures(key string, now time.Time) (int, time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, ok := m.failures[key]
+	if !ok || now.After(f.expires) {
+		return 0, time.Time{}
+	}
+	return f.count, f.last
+}
+
+func (m *Memory) ResetFailures(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.failures, key)
+}
+
+func (m *Memory) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case now := <-ticker.C:
+			m.mu.Lock()
+			for k, b := range m.buckets {
+				// A bucket idle for a full refill period is indistinguishable from a new one
+				if now.Sub(b.last) > b.per {
+					delete(m.buckets, k)
+				}
+			}
+			for k, f := range m.failures {
+				if now.After(f.expires) {
+					delete(m.failures, k)
+				}
+			}
+			m.mu.Unlock()
+		}
+	}
+}
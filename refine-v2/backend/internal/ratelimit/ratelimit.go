@@ -0,0 +1,110 @@
+// Package ratelimit implements token-bucket rate limiting and progressive
+// lockout of repeatedly failing keys (e.g. login attempts per email).
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// Policy describes a token bucket: Burst requests are allowed at once and
+// the bucket refills completely over Per.
+type Policy struct {
+	Name  string
+	Burst int
+	Per   time.Duration
+}
+
+// Backend stores bucket and failure state. Implementations must be safe for
+// concurrent use. Memory is the only implementation today; a shared backend
+// (e.g. Redis) can be added for multi-instance deployments.
+type Backend interface {
+	// Take removes one token from the bucket for key. If none is available
+	// it returns false and how long until one will be.
+	Take(key string, p Policy, now time.Time) (bool, time.Duration)
+
+	// RecordFailure increments the consecutive failure count for key and
+	// returns the new count. Counts are forgotten after ttl of inactivity.
+	RecordFailure(key string, ttl time.Duration, now time.Time) int
+
+	// Failures returns the current failure count and time of the last failure.
+	Failures(key string, now time.Time) (int, time.Time)
+
+	// ResetFailures clears the failure count for key.
+	ResetFailures(key string)
+}
+
+// Limiter applies policies against a backend.
+type Limiter struct {
+	backend Backend
+	now     func() time.Time
+}
+
+func NewLimiter(backend Backend) *Limiter {
+	return &Limiter{backend: backend, now: time.Now}
+}
+
+// Allow consumes a token for key under p.
+func (l *Limiter) Allow(p Policy, key string) (bool, time.Duration) {
+	return l.backend.Take(p.Name+":"+key, p, l.now())
+}
+
+// Lockout locks a key out after Threshold consecutive failures. Each further
+// failure doubles the lockout, starting at Base and capped at Max.
+type Lockout struct {
+	Name      string
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+
+	backend Backend
+	now     func() time.Time
+}
+
+func NewLockout(backend Backend, name string, threshold int, base, max time.Duration) *Lockout {
+	return &Lockout{
+		Name:      name,
+		Threshold: threshold,
+		Base:      base,
+		Max:       max,
+		backend:   backend,
+		now:       time.Now,
+	}
+}
+
+// Check returns how long key remains locked out, or zero if it is not.
+func (lo *Lockout) Check(key string) time.Duration {
+	now := lo.now()
+	count, last := lo.backend.Failures(lo.Name+":"+key, now)
+	if count < lo.Threshold {
+		return 0
+	}
+	until := last.Add(lo.duration(count))
+	if now.Before(until) {
+		return until.Sub(now)
+	}
+	return 0
+}
+
+// Fail records a failed attempt for key.
+func (lo *Lockout) Fail(key string) {
+	// Keep counts around long enough to cover the longest lockout
+	lo.backend.RecordFailure(lo.Name+":"+key, 2*lo.Max, lo.now())
+}
+
+// Succeed clears the failure history for key.
+func (lo *Lockout) Succeed(key string) {
+	lo.backend.ResetFailures(lo.Name + ":" + key)
+}
+
+func (lo *Lockout) duration(count int) time.Duration {
+	exp := count - lo.Threshold
+	if exp > 30 {
+		return lo.Max
+	}
+	d := time.Duration(float64(lo.Base) * math.Pow(2, float64(exp)))
+	if d > lo.Max {
+		return lo.Max
+	}
+	return d
+}
@@ -0,0 +1,152 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// clock is a manually advanced time source.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(t *testing.T) (*Limiter, *clock) {
+	t.Helper()
+	m := NewMemory(time.Hour)
+	t.Cleanup(m.Close)
+	c := &clock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := NewLimiter(m)
+	l.now = c.now
+	return l, c
+}
+
+func TestLimiterBurstAndRefill(t *testing.T) {
+	l, c := newTestLimiter(t)
+	p := Policy{Name: "test", Burst: 3, Per: 30 * time.Second} // one token every 10s
+
+	for i := range p.Burst {
+		if ok, _ := l.Allow(p, "k"); !ok {
+			t.Fatalf("request %d of the burst refused", i+1)
+		}
+	}
+	ok, wait := l.Allow(p, "k")
+	if ok {
+		t.Fatal("request beyond the burst allowed")
+	}
+	if wait != 10*time.Second {
+		t.Errorf("wait = %s, want 10s", wait)
+	}
+
+	c.advance(5 * time.Second)
+	if ok, wait := l.Allow(p, "k"); ok || wait != 5*time.Second {
+		t.Errorf("half a token later: ok = %v, wait = %s, want refused with 5s", ok, wait)
+	}
+	c.advance(5 * time.Second)
+	if ok, _ := l.Allow(p, "k"); !ok {
+		t.Error("refused after a token refilled")
+	}
+
+	// A long pause refills to the burst and no further
+	c.advance(time.Hour)
+	for i := range p.Burst {
+		if ok, _ := l.Allow(p, "k"); !ok {
+			t.Fatalf("request %d after refilling refused", i+1)
+		}
+	}
+	if ok, _ := l.Allow(p, "k"); ok {
+		t.Error("bucket refilled beyond its burst")
+	}
+}
+
+func TestLimiterKeysAndPoliciesAreSeparate(t *testing.T) {
+	l, _ := newTestLimiter(t)
+	a := Policy{Name: "a", Burst: 1, Per: time.Minute}
+	b := Policy{Name: "b", Burst: 1, Per: time.Minute}
+
+	if ok, _ := l.Allow(a, "k"); !ok {
+		t.Fatal("first request refused")
+	}
+	if ok, _ := l.Allow(a, "k"); ok {
+		t.Error("second request under the same policy and key allowed")
+	}
+	if ok, _ := l.Allow(a, "other"); !ok {
+		t.Error("another key shares the bucket")
+	}
+	if ok, _ := l.Allow(b, "k"); !ok {
+		t.Error("another policy shares the bucket")
+	}
+}
+
+func newTestLockout(t *testing.T) (*Lockout, *clock) {
+	t.Helper()
+	m := NewMemory(time.Hour)
+	t.Cleanup(m.Close)
+	c := &clock{t: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	lo := NewLockout(m, "login", 3, 30*time.Second, 2*time.Minute)
+	lo.now = c.now
+	return lo, c
+}
+
+func TestLockoutBackoff(t *testing.T) {
+	lo, c := newTestLockout(t)
+
+	for range 2 {
+		lo.Fail("k")
+	}
+	if d := lo.Check("k"); d != 0 {
+		t.Fatalf("locked out for %s below the threshold", d)
+	}
+
+	// Each failure from the threshold on doubles the lockout up to Max
+	for _, want := range []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 2 * time.Minute} {
+		lo.Fail("k")
+		if d := lo.Check("k"); d != want {
+			t.Errorf("lockout = %s, want %s", d, want)
+		}
+	}
+
+	c.advance(time.Minute)
+	if d := lo.Check("k"); d != time.Minute {
+		t.Errorf("lockout a minute later = %s, want 1m", d)
+	}
+	c.advance(time.Minute)
+	if d := lo.Check("k"); d != 0 {
+		t.Errorf("still locked out for %s after it ran out", d)
+	}
+}
+
+func TestLockoutSucceedResets(t *testing.T) {
+	lo, _ := newTestLockout(t)
+	for range 3 {
+		lo.Fail("k")
+	}
+	if lo.Check("k") == 0 {
+		t.Fatal("not locked out at the threshold")
+	}
+	if lo.Check("other") != 0 {
+		t.Error("another key is locked out")
+	}
+
+	lo.Succeed("k")
+	if d := lo.Check("k"); d != 0 {
+		t.Errorf("locked out for %s after success", d)
+	}
+	lo.Fail("k")
+	if d := lo.Check("k"); d != 0 {
+		t.Errorf("one failure after success locks out for %s", d)
+	}
+}
+
+func TestLockoutFailuresExpire(t *testing.T) {
+	lo, c := newTestLockout(t)
+	for range 2 {
+		lo.Fail("k")
+	}
+	// Counts are kept for twice the longest lockout
+	c.advance(2*lo.Max + time.Second)
+	lo.Fail("k")
+	if d := lo.Check("k"); d != 0 {
+		t.Errorf("old failures counted: locked out for %s", d)
+	}
+}
@@ -0,0 +1,114 @@
+// Package tracing sets up OpenTelemetry tracing. Spans are exported over
+// OTLP/HTTP when enabled; otherwise the global no-op provider is kept and
+// starting a span costs next to nothing. W3C trace context is propagated
+// either way, so a caller's trace ID survives a hop through an untraced
+// server.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentation = "refine-v2/backend"
+
+// Config selects the exporter. The OTLP endpoint, headers and sampler are
+// read by the SDK from the standard OTEL_EXPORTER_OTLP_* and
+// OTEL_TRACES_SAMPLER variables.
+type Config struct {
+	// Exporter is "otlp" or "none".
+	Exporter    string
+	ServiceName string
+	Version     string
+}
+
+// Setup installs the global propagator and, unless the exporter is "none",
+// a tracer provider. The returned function flushes pending spans.
+func Setup(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+
+	switch cfg.Exporter {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q (want otlp or none)", cfg.Exporter)
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("create OTLP exporter: %w", err)
+	}
+
+	attrs := []attribute.KeyValue{attribute.String("service.name", cfg.ServiceName)}
+	if cfg.Version != "" {
+		attrs = append(attrs, attribute.String("service.version", cfg.Version))
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attrs...))
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// Tracer returns the server's tracer from the global provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentation)
+}
+
+// Start starts an internal span with the given attributes.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err, if any, on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// QueryHook starts a client span for every store query. It satisfies
+// sqlstore.QueryHook.
+type QueryHook struct{}
+
+func (QueryHook) BeforeQuery(ctx context.Context, query string) context.Context {
+	query = strings.Join(strings.Fields(query), " ")
+	op := "query"
+	if i := strings.IndexByte(query, ' '); i > 0 {
+		op = strings.ToUpper(query[:i])
+	}
+	ctx, _ = Tracer().Start(ctx, "db "+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.operation.name", op),
+			attribute.String("db.query.text", query),
+		),
+	)
+	return ctx
+}
+
+func (QueryHook) AfterQuery(ctx context.Context, _ string, _ time.Duration, err error) {
+	End(trace.SpanFromContext(ctx), err)
+}
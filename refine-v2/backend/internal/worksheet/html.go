@@ -0,0 +1,43 @@
+package worksheet
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"strconv"
+)
+
+//go:embed worksheet.html
+var htmlSource string
+
+var htmlTemplate = template.Must(template.New("worksheet").Parse(htmlSource))
+
+// htmlProblem is a problem as the template draws it.
+type htmlProblem struct {
+	Number   int
+	Num1     string
+	Operator string
+	Num2     string
+	Answer   int
+	Stacked  bool
+}
+
+// HTML writes the worksheet as a standalone page styled for printing.
+func (ws *Worksheet) HTML(w io.Writer) error {
+	problems := make([]htmlProblem, len(ws.Problems))
+	for i, p := range ws.Problems {
+		problems[i] = htmlProblem{
+			Number:   i + 1,
+			Num1:     strconv.Itoa(p.Num1),
+			Operator: p.Operator,
+			Num2:     strconv.Itoa(p.Num2),
+			Answer:   p.Answer,
+			Stacked:  ws.stacked(p),
+		}
+	}
+	return htmlTemplate.Execute(w, map[string]any{
+		"Worksheet": ws,
+		"Problems":  problems,
+		"PageSize":  map[Paper]string{Letter: "letter", A4: "A4"}[ws.Paper],
+	})
+}
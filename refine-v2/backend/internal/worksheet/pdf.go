@@ -0,0 +1,246 @@
+package worksheet
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// PDF writes the worksheet as a PDF document.
+func (ws *Worksheet) PDF(w io.Writer) error {
+	size := pageSizes[ws.Paper]
+	pw := &pdfWriter{ws: ws, doc: &pdfDoc{width: size[0], height: size[1]}}
+	pw.problems()
+	if ws.AnswerKey {
+		pw.answerKey()
+	}
+
+	doc := pw.doc
+	for i, p := range doc.pages {
+		footer := fmt.Sprintf("Seed %s · Page %d of %d", ws.Seed, i+1, len(doc.pages))
+		doc.text(p, regular, 8, pdfMargin, doc.height-pdfMargin/2, footer)
+	}
+	return doc.writeTo(w)
+}
+
+const pdfMargin = 54.0
+
+// pdfWriter lays out a worksheet top to bottom, starting pages as needed.
+type pdfWriter struct {
+	ws   *Worksheet
+	doc  *pdfDoc
+	page *pdfPage
+	y    float64 // top of the next row
+}
+
+func (pw *pdfWriter) newPage(title string) {
+	pw.page = pw.doc.newPage()
+	pw.doc.text(pw.page, bold, 18, pdfMargin, pdfMargin+18, title)
+	pw.doc.text(pw.page, regular, 10, pdfMargin, pdfMargin+36, pw.ws.Description())
+	pw.y = pdfMargin + 60
+}
+
+// row starts a new page with title when a row of the given height would not
+// fit on this one.
+func (pw *pdfWriter) row(height float64, title string) {
+	if pw.y+height > pw.doc.height-pdfMargin {
+		pw.newPage(title)
+	}
+}
+
+func (pw *pdfWriter) problems() {
+	ws, doc := pw.ws, pw.doc
+	const fontSize = 14.0
+	contentWidth := doc.width - 2*pdfMargin
+	colWidth := contentWidth / float64(ws.Columns)
+	rowHeight := 42.0
+	if ws.Layout == Vertical {
+		rowHeight = 78
+	}
+
+	pw.newPage(ws.Title)
+	doc.text(pw.page, regular, 11, pdfMargin, pw.y, "Name")
+	doc.line(pw.page, 0.5, pdfMargin+34, pw.y+2, pdfMargin+contentWidth*0.6, pw.y+2)
+	doc.text(pw.page, regular, 11, pdfMargin+contentWidth*0.65, pw.y, "Date")
+	doc.line(pw.page, 0.5, pdfMargin+contentWidth*0.65+30, pw.y+2, pdfMargin+contentWidth, pw.y+2)
+	pw.y += 36
+
+	for i, p := range ws.Problems {
+		col := i % ws.Columns
+		if col == 0 {
+			if i > 0 {
+				pw.y += rowHeight
+			}
+			pw.row(rowHeight, ws.Title)
+		}
+		x := pdfMargin + float64(col)*colWidth
+		top := pw.y + fontSize
+		doc.text(pw.page, bold, 9, x, top, strconv.Itoa(i+1)+")")
+		x += 22
+
+		if !ws.stacked(p) {
+			s := fmt.Sprintf("%d %s %d =", p.Num1, p.Operator, p.Num2)
+			// Shrink large problems to fit narrow columns, leaving room to answer
+			size := fontSize
+			if avail := colWidth - 22 - 40; textWidth(s, size) > avail {
+				size *= avail / textWidth(s, size)
+			}
+			doc.text(pw.page, regular, size, x, top, s)
+			start := x + textWidth(s, size) + 4
+			if end := pdfMargin + float64(col+1)*colWidth - 12; start < end {
+				doc.line(pw.page, 0.5, start, top+2, end, top+2)
+			}
+			continue
+		}
+
+		num1, num2 := strconv.Itoa(p.Num1), strconv.Itoa(p.Num2)
+		right := x + textWidth(strings.Repeat("0", max(len(num1), len(num2))+2), fontSize)
+		doc.textRight(pw.page, regular, fontSize, right, top, num1)
+		doc.text(pw.page, regular, fontSize, x, top+fontSize+4, p.Operator)
+		doc.textRight(pw.page, regular, fontSize, right, top+fontSize+4, num2)
+		doc.line(pw.page, 1, x, top+fontSize+10, right, top+fontSize+10)
+	}
+}
+
+// answerKey lists every answer by problem number, starting on a new page.
+func (pw *pdfWriter) answerKey() {
+	const columns = 5
+	const fontSize = 11.0
+	const rowHeight = 20.0
+	title := pw.ws.Title + " · Answer key"
+	colWidth := (pw.doc.width - 2*pdfMargin) / columns
+
+	pw.newPage(title)
+	for i, p := range pw.ws.Problems {
+		col := i % columns
+		if col == 0 && i > 0 {
+			pw.y += rowHeight
+			pw.row(rowHeight, title)
+		}
+		x := pdfMargin + float64(col)*colWidth
+		pw.doc.text(pw.page, bold, fontSize, x, pw.y, strconv.Itoa(i+1)+")")
+		pw.doc.text(pw.page, regular, fontSize, x+24, pw.y, strconv.Itoa(p.Answer))
+	}
+}
+
+// pdfDoc is a minimal PDF 1.4 writer: text in the standard Helvetica fonts
+// and straight lines, which is all a worksheet needs. The standard fonts need
+// no embedding, and WinAnsiEncoding covers the × and ÷ operators.
+type pdfDoc struct {
+	width, height float64 // points
+	pages         []*pdfPage
+}
+
+type pdfPage struct {
+	content bytes.Buffer
+}
+
+type pdfFont string
+
+const (
+	regular pdfFont = "F1"
+	bold    pdfFont = "F2"
+)
+
+func (d *pdfDoc) newPage() *pdfPage {
+	p := &pdfPage{}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// text draws s with its baseline starting at x, y, measured from the top left
+// of the page like the rest of the layout code.
+func (d *pdfDoc) text(p *pdfPage, font pdfFont, size, x, y float64, s string) {
+	fmt.Fprintf(&p.content, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, d.height-y, pdfString(s))
+}
+
+// textRight draws s ending at x.
+func (d *pdfDoc) textRight(p *pdfPage, font pdfFont, size, x, y float64, s string) {
+	d.text(p, font, size, x-textWidth(s, size), y, s)
+}
+
+func (d *pdfDoc) line(p *pdfPage, width, x1, y1, x2, y2 float64) {
+	fmt.Fprintf(&p.content, "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, d.height-y1, x2, d.height-y2)
+}
+
+// writeTo writes the document. Objects 1-4 are the catalog, page tree and
+// fonts; each page then adds a page object and its content stream.
+func (d *pdfDoc) writeTo(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	var offsets []int
+	n := 0
+	write := func(format string, args ...any) {
+		c, _ := fmt.Fprintf(bw, format, args...)
+		n += c
+	}
+	object := func(body string) {
+		offsets = append(offsets, n)
+		write("%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	write("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, p := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			d.width, d.height, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.content.Len(), p.content.String()))
+	}
+
+	xref := n
+	write("xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		write("%010d 00000 n \n", off)
+	}
+	write("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return bw.Flush()
+}
+
+// pdfString encodes s as WinAnsi, which matches Latin-1 for the characters a
+// worksheet uses, escaping PDF string delimiters. Other characters become "?".
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// helveticaWidths are glyph widths in thousandths of an em for the characters
+// that are measured for alignment. Everything else is treated as a digit.
+var helveticaWidths = map[rune]float64{
+	' ': 278, '+': 584, '-': 333, '=': 584, '×': 584, '÷': 584, '(': 333, ')': 333, '.': 278, ',': 278,
+}
+
+func textWidth(s string, size float64) float64 {
+	var w float64
+	for _, r := range s {
+		if cw, ok := helveticaWidths[r]; ok {
+			w += cw
+		} else {
+			w += 556
+		}
+	}
+	return w * size / 1000
+}
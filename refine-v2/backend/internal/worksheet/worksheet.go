@@ -0,0 +1,97 @@
+// Package worksheet renders problem sets as printable worksheets, in HTML for
+// the browser's print dialog and as PDF. Every page shows the seed, so a
+// sheet can be regenerated, or graded against its answer key, later.
+package worksheet
+
+import (
+	"fmt"
+	"strings"
+
+	"refine-v2/backend/internal/models"
+)
+
+// Layout is how each problem is written.
+type Layout string
+
+const (
+	// Horizontal writes "12 + 34 = ____".
+	Horizontal Layout = "horizontal"
+	// Vertical stacks the operands for column arithmetic. Division stays
+	// horizontal.
+	Vertical Layout = "vertical"
+)
+
+// Paper is the page size.
+type Paper string
+
+const (
+	Letter Paper = "letter"
+	A4     Paper = "a4"
+)
+
+// pageSizes are in points.
+var pageSizes = map[Paper][2]float64{
+	Letter: {612, 792},
+	A4:     {595, 842},
+}
+
+// Options describe a worksheet. Mode, Difficulty, Config and Seed are the
+// generator inputs and are printed so the sheet can be reproduced.
+type Options struct {
+	Title      string
+	Seed       string
+	Mode       string
+	Difficulty int
+	Config     *models.CustomConfig
+	Columns    int
+	Layout     Layout
+	Paper      Paper
+	AnswerKey  bool
+}
+
+// Worksheet is a generated problem set ready to render.
+type Worksheet struct {
+	Options
+	Problems []models.Problem
+}
+
+// New returns a worksheet for problems, which must come from
+// GenerateWithSeed with the generator inputs in opts.
+func New(opts Options, problems []models.Problem) *Worksheet {
+	if opts.Title == "" {
+		opts.Title = "Math practice"
+	}
+	if opts.Columns <= 0 {
+		opts.Columns = 4
+	}
+	if opts.Layout == "" {
+		opts.Layout = Horizontal
+	}
+	if _, ok := pageSizes[opts.Paper]; !ok {
+		opts.Paper = Letter
+	}
+	return &Worksheet{Options: opts, Problems: problems}
+}
+
+// Description summarises the generator settings, e.g.
+// "Addition · Medium · 20 problems".
+func (ws *Worksheet) Description() string {
+	level := [...]string{1: "Easy", 2: "Medium", 3: "Hard"}
+	difficulty := ""
+	switch {
+	case ws.Config != nil && ws.Config.Min > 0 && ws.Config.Max > 0:
+		difficulty = fmt.Sprintf("Numbers %d to %d", ws.Config.Min, ws.Config.Max)
+	case ws.Difficulty >= 1 && ws.Difficulty <= 3:
+		difficulty = level[ws.Difficulty]
+	}
+	mode := ws.Mode
+	if mode != "" {
+		mode = strings.ToUpper(mode[:1]) + mode[1:]
+	}
+	return fmt.Sprintf("%s · %s · %d problems", mode, difficulty, len(ws.Problems))
+}
+
+// stacked reports whether p is drawn in columns.
+func (ws *Worksheet) stacked(p models.Problem) bool {
+	return ws.Layout == Vertical && p.Operator != "÷"
+}
@@ -41,7 +41,7 @@ export const api = {
     if (config.difficulty === 'easy') count = config.timeLimit * 2;
     else if (config.difficulty === 'custom') count = config.timeLimit * 4;
 
-    return request('/api/problems', {
+    return request('/api/v1/problems', {
       method: 'POST',
       body: JSON.stringify({
         mode: config.mode,
@@ -59,7 +59,7 @@ export const api = {
       .filter((a): a is number => a !== null)
       .map(Number);
 
-    return request('/api/validate', {
+    return request('/api/v1/validate', {
       method: 'POST',
       body: JSON.stringify({
         seed,
@@ -75,25 +75,25 @@ export const api = {
 
   // Auth
   signup(email: string, username: string, password: string): Promise<AuthResponse> {
-    return request('/api/auth/signup', {
+    return request('/api/v1/auth/signup', {
       method: 'POST',
       body: JSON.stringify({ email, username, password }),
     });
   },
 
   login(email: string, password: string): Promise<AuthResponse> {
-    return request('/api/auth/login', {
+    return request('/api/v1/auth/login', {
       method: 'POST',
       body: JSON.stringify({ email, password }),
     });
   },
 
   logout(): Promise<void> {
-    return request('/api/auth/logout', { method: 'POST' });
+    return request('/api/v1/auth/logout', { method: 'POST' });
   },
 
   getCurrentUser(): Promise<AuthResponse> {
-    return request('/api/auth/me');
+    return request('/api/v1/auth/me');
   },
 
   // Sessions
@@ -105,7 +105,7 @@ export const api = {
     total: number;
     time_limit: number;
   }): Promise<void> {
-    return request('/api/sessions', {
+    return request('/api/v1/sessions', {
       method: 'POST',
       body: JSON.stringify(data),
     });
@@ -115,29 +115,29 @@ export const api = {
   getUserStats(difficulty: number, mode?: string): Promise<StatsResponse> {
     const params = new URLSearchParams({ difficulty: String(difficulty) });
     if (mode) params.set('mode', mode);
-    return request(`/api/stats?${params}`);
+    return request(`/api/v1/stats?${params}`);
   },
 
   getLeaderboard(mode: string, difficulty: number, timeLimit: number): Promise<LeaderboardResponse> {
-    return request(`/api/leaderboard?mode=${mode}&difficulty=${difficulty}&time_limit=${timeLimit}`);
+    return request(`/api/v1/leaderboard?mode=${mode}&difficulty=${difficulty}&time_limit=${timeLimit}`);
   },
 
   // Account settings
   changePassword(currentPassword: string, newPassword: string): Promise<void> {
-    return request('/api/auth/password', {
+    return request('/api/v1/auth/password', {
       method: 'PUT',
       body: JSON.stringify({ current_password: currentPassword, new_password: newPassword }),
     });
   },
 
   changeUsername(username: string): Promise<void> {
-    return request('/api/auth/username', {
+    return request('/api/v1/auth/username', {
       method: 'PUT',
       body: JSON.stringify({ username }),
     });
   },
 
   deleteAccount(): Promise<void> {
-    return request('/api/auth/account', { method: 'DELETE' });
+    return request('/api/v1/auth/account', { method: 'DELETE' });
   },
 };